@@ -0,0 +1,56 @@
+// Package main serves a demo as CSTV broadcast, e.g. for testing live parsing without a game server
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/cstv"
+)
+
+// Run like this: go run broadcast_server.go -demo /path/to/demo.dem
+func main() {
+	fl := new(flag.FlagSet)
+
+	demoPath := fl.String("demo", "", "Demo file `path`")
+	addr := fl.String("addr", "localhost:8080", "Listen `address`")
+	token := fl.String("token", "demo", "Token the broadcast is served under")
+	realTime := fl.Bool("realtime", true, "Publish the fragments at game speed")
+	delay := fl.Duration("delay", 0, "Delay of /sync behind the newest fragment")
+
+	err := fl.Parse(os.Args[1:])
+	checkError(err)
+
+	f, err := os.Open(*demoPath)
+	checkError(err)
+
+	config := cstv.DefaultServerConfig
+	config.RealTime = *realTime
+	config.Delay = *delay
+
+	srv, err := cstv.NewServerWithConfig(f, config)
+	checkError(err)
+
+	err = f.Close()
+	checkError(err)
+
+	prefix := "/" + *token
+
+	http.Handle(prefix+"/", http.StripPrefix(prefix, srv))
+
+	fmt.Printf("Serving broadcast at http://%s%s\n", *addr, prefix)
+
+	server := &http.Server{Addr: *addr, ReadHeaderTimeout: 10 * time.Second}
+
+	log.Fatal(server.ListenAndServe())
+}
+
+func checkError(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -4,18 +4,24 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	demoinfocs "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
 	common "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/common"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/cstv"
 	events "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
 	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
 )
 
 // Run like this: go run broadcasts.go -url "http://localhost:8080/<token>"
+// or to also archive the broadcast: go run broadcasts.go -url "http://localhost:8080/<token>" -record fragments -out broadcast.dem
 func main() {
 	fl := new(flag.FlagSet)
 
 	urlPtr := fl.String("url", "", "CSTV Broadcast URL")
+	recordDir := fl.String("record", "", "Directory `path` to store the broadcast fragments in, enables recording")
+	outPath := fl.String("out", "broadcast.dem", "Demo file `path` the recording is written to at the end of the broadcast")
+	replay := fl.Bool("replay", false, "Replay the broadcast from the beginning and catch up with the live match")
 
 	err := fl.Parse(os.Args[1:])
 	if err != nil {
@@ -29,8 +35,30 @@ func main() {
 		return
 	}
 
-	p, err := demoinfocs.NewCSTVBroadcastParser(url)
-	checkError(err)
+	var (
+		p      demoinfocs.Parser
+		rec    *cstv.Recorder
+		config = demoinfocs.DefaultParserConfig
+	)
+
+	if *replay {
+		config.CSTVReaderConfig.StartFragment = cstv.StartSignup
+	}
+
+	if *recordDir != "" {
+		readerConfig := config.CSTVReaderConfig
+		readerConfig.Retry = cstv.TimeoutRetryPolicy(config.CSTVTimeout)
+
+		rec, err = cstv.NewRecorderWithConfig(url, *recordDir, readerConfig)
+		checkError(err)
+
+		config.Format = demoinfocs.DemoFormatCSTVBroadcast
+
+		p = demoinfocs.NewParserWithConfig(rec, config)
+	} else {
+		p, err = demoinfocs.NewCSTVBroadcastParserWithConfig(url, config)
+		checkError(err)
+	}
 
 	p.RegisterNetMessageHandler(func(m *msg.CDemoFileHeader) {
 		fmt.Println("Map:", m.GetMapName())
@@ -74,11 +102,39 @@ func main() {
 		fmt.Printf("Rank Update: %d went from rank %d to rank %d, change: %f\n", e.SteamID32, e.RankOld, e.RankNew, e.RankChange)
 	})
 
+	p.RegisterEventHandler(func(e events.BroadcastLive) {
+		fmt.Println("Caught up with the live match at tick", e.IngameTick)
+	})
+
+	// Warn when we fall behind the live match
+	p.RegisterEventHandler(func(e events.BroadcastLagChanged) {
+		if e.LagTime > 10*time.Second {
+			fmt.Printf("Falling behind: %v behind the live match (%d ticks)\n", e.LagTime, e.Lag)
+		}
+	})
+
 	// Parse to end
 	err = p.ParseToEnd()
+
+	if rec != nil {
+		writeRecording(rec, *outPath)
+	}
+
 	checkError(err)
 }
 
+func writeRecording(rec *cstv.Recorder, path string) {
+	f, err := os.Create(path)
+	checkError(err)
+
+	defer f.Close()
+
+	err = rec.Finalize(f)
+	checkError(err)
+
+	fmt.Println("Broadcast written to", path)
+}
+
 func formatPlayer(p *common.Player) string {
 	if p == nil {
 		return "?"
@@ -0,0 +1,53 @@
+// Package main cuts a tick or round range out of a demo into a standalone demo
+package main
+
+import (
+	"flag"
+	"os"
+
+	demoinfocs "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
+)
+
+// Run like this: go run clip_demo.go -demo /path/to/demo.dem -out clip.dem -rounds 12
+// or: go run clip_demo.go -demo /path/to/demo.dem -out clip.dem -start-tick 1000 -end-tick 5000
+func main() {
+	fl := new(flag.FlagSet)
+
+	demoPath := fl.String("demo", "", "Demo file `path`")
+	outPath := fl.String("out", "clip.dem", "Output file `path`")
+	startTick := fl.Int("start-tick", 0, "First ingame `tick` of the clip")
+	endTick := fl.Int("end-tick", 0, "Last ingame `tick` of the clip")
+	firstRound := fl.Int("rounds", 0, "First `round` of the clip (1-based), takes precedence over -start-tick & -end-tick")
+	lastRound := fl.Int("last-round", 0, "Last `round` of the clip, defaults to -rounds")
+
+	err := fl.Parse(os.Args[1:])
+	checkError(err)
+
+	f, err := os.Open(*demoPath)
+	checkError(err)
+
+	defer f.Close()
+
+	out, err := os.Create(*outPath)
+	checkError(err)
+
+	defer out.Close()
+
+	if *firstRound > 0 {
+		if *lastRound == 0 {
+			*lastRound = *firstRound
+		}
+
+		err = demoinfocs.ClipRounds(out, f, *firstRound, *lastRound)
+	} else {
+		err = demoinfocs.ClipTicks(out, f, *startTick, *endTick)
+	}
+
+	checkError(err)
+}
+
+func checkError(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
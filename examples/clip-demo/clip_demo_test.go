@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Just make sure the example runs
+func TestClipDemo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test")
+	}
+
+	os.Args = []string{"cmd", "-demo", "../../test/cs-demos/s2/s2.dem", "-out", filepath.Join(t.TempDir(), "clip.dem"), "-rounds", "2"}
+
+	main()
+}
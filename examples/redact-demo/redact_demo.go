@@ -0,0 +1,103 @@
+// Package main writes anonymised copies of demos with consistent pseudonyms
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	demoinfocs "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
+)
+
+// Run like this: go run redact_demo.go -out redacted -mapping pseudonyms.json /path/to/demo1.dem /path/to/demo2.dem
+func main() {
+	fl := new(flag.FlagSet)
+
+	outDir := fl.String("out", "redacted", "Output directory `path`")
+	mappingPath := fl.String("mapping", "", "JSON file `path` to load and store the pseudonyms, so they stay the same across runs")
+	keepChat := fl.Bool("keep-chat", false, "Keep chat messages")
+
+	err := fl.Parse(os.Args[1:])
+	checkError(err)
+
+	pseudonyms := demoinfocs.NewPseudonymMapping()
+
+	if *mappingPath != "" {
+		loadMapping(pseudonyms, *mappingPath)
+	}
+
+	err = os.MkdirAll(*outDir, 0o755)
+	checkError(err)
+
+	for _, demoPath := range fl.Args() {
+		redact(demoPath, filepath.Join(*outDir, filepath.Base(demoPath)), demoinfocs.RedactConfig{
+			Pseudonyms: pseudonyms,
+			KeepChat:   *keepChat,
+		})
+	}
+
+	if *mappingPath != "" {
+		storeMapping(pseudonyms, *mappingPath)
+	}
+}
+
+func redact(demoPath, outPath string, config demoinfocs.RedactConfig) {
+	f, err := os.Open(demoPath)
+	checkError(err)
+
+	defer f.Close()
+
+	out, err := os.Create(outPath)
+	checkError(err)
+
+	defer out.Close()
+
+	err = demoinfocs.Redact(out, f, config)
+	checkError(err)
+}
+
+// the mapping is stored by SteamID64, as string since JSON keys must be strings
+func loadMapping(pseudonyms *demoinfocs.PseudonymMapping, path string) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+
+	checkError(err)
+
+	var mapping map[string]demoinfocs.Pseudonym
+
+	err = json.Unmarshal(b, &mapping)
+	checkError(err)
+
+	for steamID, pseudonym := range mapping {
+		id, err := strconv.ParseUint(steamID, 10, 64)
+		checkError(err)
+
+		pseudonyms.Set(id, pseudonym)
+	}
+}
+
+func storeMapping(pseudonyms *demoinfocs.PseudonymMapping, path string) {
+	mapping := make(map[string]demoinfocs.Pseudonym)
+
+	for steamID, pseudonym := range pseudonyms.Pseudonyms() {
+		mapping[strconv.FormatUint(steamID, 10)] = pseudonym
+	}
+
+	b, err := json.MarshalIndent(mapping, "", "  ")
+	checkError(err)
+
+	err = os.WriteFile(path, b, 0o600)
+	checkError(err)
+}
+
+func checkError(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Just make sure the example runs
+func TestRedactDemo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test")
+	}
+
+	dir := t.TempDir()
+
+	os.Args = []string{"cmd", "-out", dir, "-mapping", filepath.Join(dir, "pseudonyms.json"), "../../test/cs-demos/s2/s2.dem"}
+
+	main()
+}
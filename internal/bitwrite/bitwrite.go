@@ -0,0 +1,100 @@
+// Package bitwrite provides a bit writer that's the inverse of the bitread package (bits are written LSB first).
+//
+// Intended for internal use only.
+package bitwrite
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// BitWriter writes bits LSB first into a byte slice.
+type BitWriter struct {
+	b []byte
+	n int // Number of bits written
+}
+
+// Bytes returns the written data, the last byte is padded with zeros.
+func (w *BitWriter) Bytes() []byte {
+	return w.b
+}
+
+// BitsWritten returns the amount of bits written so far.
+func (w *BitWriter) BitsWritten() int {
+	return w.n
+}
+
+// WriteBits writes the n lowest bits of v.
+func (w *BitWriter) WriteBits(v uint, n int) {
+	for i := 0; i < n; i++ {
+		if w.n%8 == 0 {
+			w.b = append(w.b, 0)
+		}
+
+		w.b[len(w.b)-1] |= byte((v>>i)&1) << (w.n % 8)
+		w.n++
+	}
+}
+
+// WriteBit writes a single bit.
+func (w *BitWriter) WriteBit(v bool) {
+	if v {
+		w.WriteBits(1, 1)
+	} else {
+		w.WriteBits(0, 1)
+	}
+}
+
+// WriteBytes writes whole bytes, even if the writer isn't byte aligned.
+func (w *BitWriter) WriteBytes(b []byte) {
+	if w.n%8 == 0 {
+		w.b = append(w.b, b...)
+		w.n += len(b) << 3
+
+		return
+	}
+
+	for _, x := range b {
+		w.WriteBits(uint(x), 8)
+	}
+}
+
+// WriteString writes a null terminated string, the inverse of bitread.BitReader.ReadString().
+func (w *BitWriter) WriteString(s string) {
+	w.WriteBytes([]byte(s))
+	w.WriteBits(0, 8)
+}
+
+// WriteVarInt writes a variable size unsigned int, the inverse of bitread.BitReader.ReadVarInt32() & ReadVarInt64().
+func (w *BitWriter) WriteVarInt(v uint64) {
+	w.WriteBytes(protowire.AppendVarint(nil, v))
+}
+
+// WriteUBitInt is the inverse of bitread.BitReader.ReadUBitInt().
+func (w *BitWriter) WriteUBitInt(v uint) {
+	switch {
+	case v < 1<<4:
+		w.WriteBits(v, 6)
+
+	case v < 1<<8:
+		w.WriteBits(v&15|16, 6)
+		w.WriteBits(v>>4, 4)
+
+	case v < 1<<12:
+		w.WriteBits(v&15|32, 6)
+		w.WriteBits(v>>4, 8)
+
+	default:
+		w.WriteBits(v&15|48, 6)
+		w.WriteBits(v>>4, 32-4)
+	}
+}
+
+// CopyBits writes the bits from start (inclusive) to end (exclusive) of b.
+func (w *BitWriter) CopyBits(b []byte, start, end int) {
+	for pos := start; pos < end; {
+		n := min(8-pos%8, end-pos)
+
+		w.WriteBits(uint(b[pos/8]>>(pos%8)), n)
+		pos += n
+	}
+}
@@ -0,0 +1,42 @@
+package bitwrite
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	bit "github.com/markus-wa/demoinfocs-golang/v5/internal/bitread"
+)
+
+func TestBitWriter_WriteUBitInt(t *testing.T) {
+	values := []uint{0, 15, 16, 255, 256, 4095, 4096, 1 << 20}
+
+	w := new(BitWriter)
+	for _, v := range values {
+		w.WriteUBitInt(v)
+		w.WriteBit(true)
+	}
+
+	r := bit.NewSmallBitReader(bytes.NewReader(append(w.Bytes(), 0, 0, 0, 0)))
+
+	for _, v := range values {
+		assert.Equal(t, v, r.ReadUBitInt())
+		assert.True(t, r.ReadBit())
+	}
+}
+
+func TestBitWriter_CopyBits(t *testing.T) {
+	src := new(BitWriter)
+	src.WriteBits(0b101, 3)
+	src.WriteBits(0x1ABCD, 17)
+
+	w := new(BitWriter)
+	w.WriteBit(true)
+	w.CopyBits(src.Bytes(), 3, 20)
+
+	r := bit.NewSmallBitReader(bytes.NewReader(append(w.Bytes(), 0, 0, 0, 0)))
+
+	assert.True(t, r.ReadBit())
+	assert.Equal(t, uint(0x1ABCD), r.ReadInt(17))
+}
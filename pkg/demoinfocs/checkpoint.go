@@ -0,0 +1,353 @@
+package demoinfocs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	bit "github.com/markus-wa/demoinfocs-golang/v5/internal/bitread"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+// Checkpoint errors
+var (
+	// ErrCheckpointsDisabled signals that Parser.Checkpoint() was called without ParserConfig.EnableCheckpoints.
+	ErrCheckpointsDisabled = errors.New("checkpoints are disabled, see ParserConfig.EnableCheckpoints (ErrCheckpointsDisabled)")
+
+	// ErrInvalidCheckpoint signals that the data passed to NewParserFromCheckpoint() isn't a checkpoint or is corrupt.
+	ErrInvalidCheckpoint = errors.New("invalid checkpoint (ErrInvalidCheckpoint)")
+
+	// ErrIncompatibleCheckpoint signals that a checkpoint was created by a different version of the library,
+	// for a different demo format or for a demo with a different header (e.g. a different game build).
+	ErrIncompatibleCheckpoint = errors.New("incompatible checkpoint (ErrIncompatibleCheckpoint)")
+)
+
+// CheckpointVersion is the version of the checkpoint format written by Parser.Checkpoint().
+// Checkpoints with a different version can't be loaded.
+const CheckpointVersion = 1
+
+const (
+	checkpointMagic          = "PBDEMCKP"
+	maxCheckpointFrameLength = 1 << 28
+)
+
+// checkpointFrame is a decompressed demo command in its protobuf encoding.
+type checkpointFrame struct {
+	cmd  msg.EDemoCommands
+	tick uint32
+	data []byte
+}
+
+// checkpointRecorder keeps the frames required to restore the parser state, see ParserConfig.EnableCheckpoints.
+type checkpointRecorder struct {
+	signon        []checkpointFrame // Everything before the first packet (send-tables, class-info, string-tables etc.)
+	signonDone    bool
+	sinceKeyframe []checkpointFrame // The last CDemoFullPacket and all frames after it
+	err           error
+}
+
+func (r *checkpointRecorder) record(cmd msg.EDemoCommands, tick uint32, buf []byte, m proto.Message, isCSTVBroadcast bool) {
+	if isCSTVBroadcast {
+		// broadcast packets aren't protobuf encoded, re-encode them so they can be replayed like a '.dem' file
+		var err error
+
+		buf, err = proto.Marshal(m)
+		if err != nil && r.err == nil {
+			r.err = errors.Wrap(err, "failed to encode frame for checkpoint")
+		}
+	}
+
+	f := checkpointFrame{cmd: cmd, tick: tick, data: buf}
+
+	isPacket := cmd == msg.EDemoCommands_DEM_Packet || cmd == msg.EDemoCommands_DEM_FullPacket
+	r.signonDone = r.signonDone || isPacket
+
+	switch {
+	case !r.signonDone:
+		r.signon = append(r.signon, f)
+
+	case cmd == msg.EDemoCommands_DEM_FullPacket:
+		r.sinceKeyframe = append(r.sinceKeyframe[:0], f)
+
+	default:
+		r.sinceKeyframe = append(r.sinceKeyframe, f)
+	}
+}
+
+/*
+Checkpoint writes the current state of the parser to w, so parsing can be resumed later via NewParserFromCheckpoint(),
+e.g. after a crash or restart.
+
+The entity & field state isn't serialised. Instead the checkpoint contains the frames needed to rebuild it:
+the signon data plus the last CDemoFullPacket and all frames after it, as they were read from the demo.
+NewParserFromCheckpoint() parses these frames again (without dispatching events), which rebuilds the entities,
+string tables, player infos and the game-state. So the size of a checkpoint and the time to restore it grow with the
+number of frames since the last CDemoFullPacket.
+ConVars, the stream position, the current frame and the demo header are stored as well.
+The checkpoint contains the CDemoFileHeader of the demo and CheckpointVersion, loading it for a different demo or
+with a different version of the library fails.
+
+Requires ParserConfig.EnableCheckpoints, otherwise ErrCheckpointsDisabled is returned.
+Must be called between frames (e.g. after ParseNextFrame()) and not from an event or net-message handler.
+*/
+func (p *parser) Checkpoint(w io.Writer) error {
+	if p.checkpoints == nil {
+		return ErrCheckpointsDisabled
+	}
+
+	p.msgDispatcher.SyncAllQueues()
+
+	if err := p.error(); err != nil {
+		return errors.Wrap(err, "can't create checkpoint after parsing failed")
+	}
+
+	if p.checkpoints.err != nil {
+		return p.checkpoints.err
+	}
+
+	if p.header == nil || !p.checkpoints.signonDone {
+		return errors.New("can't create checkpoint before the signon data has been parsed")
+	}
+
+	b := []byte(checkpointMagic)
+	b = binary.AppendUvarint(b, CheckpointVersion)
+	b = binary.AppendUvarint(b, uint64(p.config.Format))
+	b = appendDemoIndexHeader(b, newDemoIndexHeader(p.demoFileHeader))
+
+	b = binary.AppendVarint(b, p.streamOffset())
+	b = binary.AppendVarint(b, int64(p.currentFrame))
+	b = binary.AppendVarint(b, int64(p.header.PlaybackTime))
+	b = binary.AppendVarint(b, int64(p.header.PlaybackTicks))
+	b = binary.AppendVarint(b, int64(p.header.PlaybackFrames))
+
+	conVars := p.gameState.rules.conVars
+	b = binary.AppendUvarint(b, uint64(len(conVars)))
+
+	for k, v := range conVars {
+		b = appendIndexString(b, k)
+		b = appendIndexString(b, v)
+	}
+
+	b = appendCheckpointFrames(b, p.checkpoints.signon)
+	b = appendCheckpointFrames(b, p.checkpoints.sinceKeyframe)
+
+	_, err := w.Write(b)
+
+	return err
+}
+
+func appendCheckpointFrames(b []byte, frames []checkpointFrame) []byte {
+	b = binary.AppendUvarint(b, uint64(len(frames)))
+
+	for _, f := range frames {
+		b = binary.AppendUvarint(b, uint64(f.cmd))
+		b = binary.AppendUvarint(b, uint64(f.tick))
+		b = binary.AppendUvarint(b, uint64(len(f.data)))
+		b = append(b, f.data...)
+	}
+
+	return b
+}
+
+// checkpoint is the decoded data of Parser.Checkpoint().
+type checkpoint struct {
+	format         DemoFormat
+	header         DemoIndexHeader
+	offset         int64
+	frame          int
+	playbackTime   time.Duration
+	playbackTicks  int
+	playbackFrames int
+	conVars        map[string]string
+	frames         []checkpointFrame
+}
+
+func readCheckpoint(r io.Reader) (*checkpoint, error) {
+	cr := &binaryReader{r: bufio.NewReader(r), invalid: ErrInvalidCheckpoint}
+
+	magic := make([]byte, len(checkpointMagic))
+
+	_, err := io.ReadFull(cr.r, magic)
+	if err != nil || string(magic) != checkpointMagic {
+		return nil, ErrInvalidCheckpoint
+	}
+
+	version := cr.uvarint()
+	if cr.err == nil && version != CheckpointVersion {
+		return nil, errors.Wrapf(ErrIncompatibleCheckpoint, "unsupported checkpoint version %d", version)
+	}
+
+	cp := &checkpoint{
+		format:         DemoFormat(cr.uvarint()), //nolint:gosec
+		header:         cr.demoIndexHeader(),
+		offset:         cr.varint(),
+		frame:          int(cr.varint()),
+		playbackTime:   time.Duration(cr.varint()),
+		playbackTicks:  int(cr.varint()),
+		playbackFrames: int(cr.varint()),
+		conVars:        make(map[string]string),
+	}
+
+	nConVars := cr.uvarint()
+	for i := uint64(0); i < nConVars && cr.err == nil; i++ {
+		k := cr.string()
+		cp.conVars[k] = cr.string()
+	}
+
+	// signon data and frames since the last keyframe are replayed the same way
+	for range 2 {
+		n := cr.uvarint()
+
+		for i := uint64(0); i < n && cr.err == nil; i++ {
+			cp.frames = append(cp.frames, checkpointFrame{
+				cmd:  msg.EDemoCommands(cr.uvarint()), //nolint:gosec
+				tick: uint32(cr.uvarint()),            //nolint:gosec
+				data: cr.bytes(maxCheckpointFrameLength),
+			})
+		}
+	}
+
+	if cr.err != nil {
+		return nil, errors.Wrap(cr.err, "failed to read checkpoint")
+	}
+
+	return cp, nil
+}
+
+// replayData encodes the frames of the checkpoint as a '.dem' file.
+func (cp *checkpoint) replayData() []byte {
+	b := append([]byte("PBDEMS2\x00"), make([]byte, 8)...)
+
+	for _, f := range cp.frames {
+		b = protowire.AppendVarint(b, uint64(f.cmd))
+		b = protowire.AppendVarint(b, uint64(f.tick))
+		b = protowire.AppendVarint(b, uint64(len(f.data)))
+		b = append(b, f.data...)
+	}
+
+	return b
+}
+
+/*
+NewParserFromCheckpoint returns a new Parser that continues where the parser that created the checkpoint (see Parser.Checkpoint()) stopped.
+
+If stream implements io.ReadSeeker and config.Format is DemoFormatFile, the demo's CDemoFileHeader is compared to the
+checkpoint and the stream is moved to the stored position. Other streams (e.g. CSTV broadcasts) must already continue
+right after the last frame that was parsed before the checkpoint was created.
+
+Returns ErrInvalidCheckpoint if r doesn't contain a valid checkpoint and ErrIncompatibleCheckpoint if it was created
+by a different version of the library, for a different format or a different demo.
+*/
+func NewParserFromCheckpoint(r io.Reader, stream io.Reader, config ParserConfig) (Parser, error) {
+	cp, err := readCheckpoint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if cp.format != config.Format {
+		return nil, errors.Wrapf(ErrIncompatibleCheckpoint, "checkpoint was created for demo format %d, not %d", cp.format, config.Format)
+	}
+
+	p := NewParserWithConfig(stream, config).(*parser)
+
+	rs, seekable := stream.(io.ReadSeeker)
+	seekable = seekable && config.Format == DemoFormatFile
+
+	if seekable {
+		err = checkCheckpointDemo(rs, cp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = p.restoreCheckpoint(cp)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to restore checkpoint")
+	}
+
+	if seekable {
+		err = p.repositionStream(rs, cp.offset)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.streamBase = cp.offset
+	}
+
+	return p, nil
+}
+
+func checkCheckpointDemo(rs io.ReadSeeker, cp *checkpoint) error {
+	_, err := rs.Seek(0, io.SeekStart)
+	if err != nil {
+		return errors.Wrap(err, "failed to seek demo stream")
+	}
+
+	h, err := readDemoFileHeader(rs)
+	if err != nil {
+		return errors.Wrap(err, "failed to read demo header")
+	}
+
+	if newDemoIndexHeader(h) != cp.header {
+		return errors.Wrapf(ErrIncompatibleCheckpoint, "checkpoint was created for a different demo (build %d, patch %d)",
+			cp.header.BuildNum, cp.header.PatchVersion)
+	}
+
+	return nil
+}
+
+// restoreCheckpoint decodes the frames of the checkpoint as if they were a '.dem' file.
+// No event handlers can be registered yet, so no events are dispatched.
+func (p *parser) restoreCheckpoint(cp *checkpoint) (err error) {
+	defer func() {
+		if err == nil {
+			err = recoverFromUnexpectedEOF(recover())
+		}
+	}()
+
+	bitReader, format := p.bitReader, p.config.Format
+	p.bitReader = bit.NewLargeBitReader(bytes.NewReader(cp.replayData()))
+	p.config.Format = DemoFormatFile
+
+	defer func() {
+		p.bitReader, p.config.Format = bitReader, format
+	}()
+
+	_, err = p.parseHeader()
+	if err != nil {
+		return err
+	}
+
+	for range cp.frames {
+		if !p.parseFrame() {
+			break
+		}
+	}
+
+	p.msgDispatcher.SyncAllQueues()
+
+	if err = p.error(); err != nil {
+		return err
+	}
+
+	if newDemoIndexHeader(p.demoFileHeader) != cp.header {
+		return errors.Wrap(ErrInvalidCheckpoint, "CDemoFileHeader of the signon data doesn't match the checkpoint")
+	}
+
+	p.currentFrame = cp.frame
+	p.header.PlaybackTime = cp.playbackTime
+	p.header.PlaybackTicks = cp.playbackTicks
+	p.header.PlaybackFrames = cp.playbackFrames
+
+	for k, v := range cp.conVars {
+		p.gameState.rules.conVars[k] = v
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+)
+
+func testCheckpoint(t *testing.T, data []byte, frames int) (cp []byte, ticksAfter []int, p *parser) {
+	t.Helper()
+
+	cfg := DefaultParserConfig
+	cfg.EnableCheckpoints = true
+
+	p = NewParserWithConfig(bytes.NewReader(data), cfg).(*parser)
+
+	for range frames {
+		_, err := p.ParseNextFrame()
+		assert.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+
+	err := p.Checkpoint(&buf)
+	assert.NoError(t, err)
+
+	p.RegisterEventHandler(func(events.FrameDone) {
+		ticksAfter = append(ticksAfter, p.GameState().IngameTick())
+	})
+
+	err = p.ParseToEnd()
+	assert.NoError(t, err)
+
+	return buf.Bytes(), ticksAfter, p
+}
+
+func parseRestored(t *testing.T, p Parser) []int {
+	t.Helper()
+
+	var ticks []int
+
+	p.RegisterEventHandler(func(events.FrameDone) {
+		ticks = append(ticks, p.GameState().IngameTick())
+	})
+
+	err := p.ParseToEnd()
+	assert.NoError(t, err)
+
+	return ticks
+}
+
+func TestNewParserFromCheckpoint(t *testing.T) {
+	data := testSegmentedDemoData()
+
+	cp, expectedTicks, _ := testCheckpoint(t, data, 13)
+	assert.NotEmpty(t, expectedTicks)
+
+	cfg := DefaultParserConfig
+	cfg.EnableCheckpoints = true
+
+	p, err := NewParserFromCheckpoint(bytes.NewReader(cp), bytes.NewReader(data), cfg)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 13, p.CurrentFrame())
+	assert.Equal(t, 20, p.GameState().IngameTick())
+	assert.Equal(t, expectedTicks, parseRestored(t, p))
+}
+
+func TestNewParserFromCheckpoint_NotSeekable(t *testing.T) {
+	data := testSegmentedDemoData()
+
+	cfg := DefaultParserConfig
+	cfg.EnableCheckpoints = true
+
+	orig := NewParserWithConfig(bytes.NewReader(data), cfg).(*parser)
+
+	for range 8 {
+		_, err := orig.ParseNextFrame()
+		assert.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+
+	err := orig.Checkpoint(&buf)
+	assert.NoError(t, err)
+
+	offset := orig.streamOffset()
+	stream := struct{ io.Reader }{bytes.NewReader(data[offset:])}
+
+	expectedTicks := parseRestored(t, orig)
+
+	p, err := NewParserFromCheckpoint(&buf, stream, cfg)
+	assert.NoError(t, err)
+
+	assert.Equal(t, expectedTicks, parseRestored(t, p))
+	assert.Equal(t, orig.streamOffset(), p.(*parser).streamOffset())
+}
+
+func TestParser_Checkpoint_Disabled(t *testing.T) {
+	p := NewParser(bytes.NewReader(testSegmentedDemoData()))
+
+	assert.ErrorIs(t, p.Checkpoint(io.Discard), ErrCheckpointsDisabled)
+}
+
+func TestNewParserFromCheckpoint_Incompatible(t *testing.T) {
+	data := testSegmentedDemoData()
+	cp, _, _ := testCheckpoint(t, data, 8)
+
+	cfg := DefaultParserConfig
+
+	otherDemo := bytes.Replace(data, []byte("de_test"), []byte("de_tset"), 1)
+
+	_, err := NewParserFromCheckpoint(bytes.NewReader(cp), bytes.NewReader(otherDemo), cfg)
+	assert.ErrorIs(t, err, ErrIncompatibleCheckpoint)
+
+	otherVersion := bytes.Clone(cp)
+	otherVersion[len(checkpointMagic)] = CheckpointVersion + 1
+
+	_, err = NewParserFromCheckpoint(bytes.NewReader(otherVersion), bytes.NewReader(data), cfg)
+	assert.ErrorIs(t, err, ErrIncompatibleCheckpoint)
+
+	cfg.Format = DemoFormatCSTVBroadcast
+
+	_, err = NewParserFromCheckpoint(bytes.NewReader(cp), bytes.NewReader(data), cfg)
+	assert.ErrorIs(t, err, ErrIncompatibleCheckpoint)
+}
+
+func TestNewParserFromCheckpoint_Invalid(t *testing.T) {
+	_, err := NewParserFromCheckpoint(bytes.NewReader([]byte("PBDEMS2")), bytes.NewReader(testSegmentedDemoData()), DefaultParserConfig)
+	assert.ErrorIs(t, err, ErrInvalidCheckpoint)
+
+	cp, _, _ := testCheckpoint(t, testSegmentedDemoData(), 8)
+
+	_, err = NewParserFromCheckpoint(bytes.NewReader(cp[:len(cp)-1]), bytes.NewReader(testSegmentedDemoData()), DefaultParserConfig)
+	assert.Error(t, err)
+}
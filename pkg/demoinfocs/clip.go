@@ -0,0 +1,344 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/internal/bitread"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/demowriter"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+// ErrInvalidClipRange signals that the tick or round range passed to ClipTicks() or ClipRounds() is empty or outside of the demo.
+var ErrInvalidClipRange = errors.New("invalid clip range (ErrInvalidClipRange)")
+
+// clipStateMessages are the net-messages that are kept in the frames before the start of a clip.
+// Everything else (game events, user messages etc.) happened before the clip and is dropped.
+var clipStateMessages = map[int32]bool{
+	int32(msg.SVC_Messages_svc_ServerInfo):                 true,
+	int32(msg.SVC_Messages_svc_ClassInfo):                  true,
+	int32(msg.SVC_Messages_svc_CreateStringTable):          true,
+	int32(msg.SVC_Messages_svc_UpdateStringTable):          true,
+	int32(msg.SVC_Messages_svc_ClearAllStringTables):       true,
+	int32(msg.SVC_Messages_svc_PacketEntities):             true,
+	int32(msg.NET_Messages_net_Tick):                       true,
+	int32(msg.NET_Messages_net_SetConVar):                  true,
+	int32(msg.NET_Messages_net_SpawnGroup_Load):            true,
+	int32(msg.NET_Messages_net_SpawnGroup_ManifestUpdate):  true,
+	int32(msg.NET_Messages_net_SpawnGroup_SetCreationTick): true,
+	int32(msg.NET_Messages_net_SpawnGroup_Unload):          true,
+	int32(msg.NET_Messages_net_SpawnGroup_LoadCompleted):   true,
+}
+
+/*
+ClipTicks writes the ingame ticks from startTick to endTick (inclusive) of a '.dem' file as a standalone demo to w.
+
+The clip contains the CDemoFileHeader and signon data (send-tables, class-info, string-tables etc.) of the demo,
+followed by the closest preceding keyframe (CDemoFullPacket, see Parser.SeekToTick()),
+the frames between the keyframe and the start tick and all frames of the range.
+The keyframe and the frames before the start tick are written at the start tick and only contain the net-messages
+that make up the state (entities, string tables, ConVars etc.), so the state at the start tick is the same as
+in the demo. Their game events and other messages are dropped, like when seeking.
+
+If w implements io.WriteSeeker the clip also gets a valid CDemoFileInfo offset, see demowriter.Writer.Close().
+Returns ErrInvalidClipRange if the range is empty or starts after the end of the demo.
+*/
+func ClipTicks(w io.Writer, demo io.ReadSeeker, startTick, endTick int) error {
+	idx, err := buildDemoIndexFromStart(demo, false)
+	if err != nil {
+		return err
+	}
+
+	return clip(w, demo, idx, startTick, endTick)
+}
+
+/*
+ClipRounds writes the rounds firstRound to lastRound (1-based, inclusive) of a '.dem' file as a standalone demo to w.
+
+Rounds are counted via 'round_start' game events (see BuildDemoIndex()), so warmup rounds may be included in the count.
+A round starts at its 'round_start' event and ends right before the next one, so the clip includes the time after the round end.
+
+See ClipTicks() for details.
+*/
+func ClipRounds(w io.Writer, demo io.ReadSeeker, firstRound, lastRound int) error {
+	idx, err := buildDemoIndexFromStart(demo, true)
+	if err != nil {
+		return err
+	}
+
+	starts := idx.RoundStarts()
+
+	if firstRound < 1 || lastRound < firstRound || firstRound > len(starts) {
+		return errors.Wrapf(ErrInvalidClipRange, "rounds %d to %d, the demo has %d rounds", firstRound, lastRound, len(starts))
+	}
+
+	endTick := idx.LastTick
+	if lastRound < len(starts) {
+		endTick = starts[lastRound].Tick - 1
+	}
+
+	return clip(w, demo, idx, starts[firstRound-1].Tick, endTick)
+}
+
+func buildDemoIndexFromStart(demo io.ReadSeeker, rounds bool) (*DemoIndex, error) {
+	_, err := demo.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to seek demo stream")
+	}
+
+	idx, err := buildDemoIndex(demo, rounds)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to index demo")
+	}
+
+	return idx, nil
+}
+
+// clipper copies the frames of a clip from the demo to the demowriter.Writer.
+type clipper struct {
+	demo io.ReadSeeker
+	fr   *frameReader
+	dw   *demowriter.Writer
+}
+
+func clip(w io.Writer, demo io.ReadSeeker, idx *DemoIndex, startTick, endTick int) error {
+	if startTick > endTick || startTick > idx.LastTick {
+		return errors.Wrapf(ErrInvalidClipRange, "ticks %d to %d, the demo ends at tick %d", startTick, endTick, idx.LastTick)
+	}
+
+	_, err := demo.Seek(0, io.SeekStart)
+	if err != nil {
+		return errors.Wrap(err, "failed to seek demo stream")
+	}
+
+	fr, err := newDemoFrameReader(demo)
+	if err != nil {
+		return err
+	}
+
+	header := new(msg.CDemoFileHeader)
+
+	err = fr.readMessage(msg.EDemoCommands_DEM_FileHeader, header)
+	if err != nil {
+		return errors.Wrap(err, "failed to read CDemoFileHeader")
+	}
+
+	dw, err := demowriter.NewWriter(w, header)
+	if err != nil {
+		return err
+	}
+
+	c := &clipper{demo: demo, fr: fr, dw: dw}
+	kfIdx := idx.keyframeIndex()
+
+	err = c.copySignon(kfIdx.signon.offset)
+	if err != nil {
+		return err
+	}
+
+	kf := kfIdx.signon
+
+	for _, k := range kfIdx.keyframes {
+		if k.full && k.tick <= startTick {
+			kf = k
+		}
+	}
+
+	first, err := c.writeStartFrames(kf, int32(startTick)) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	err = c.copyFrames(first, endTick)
+	if err != nil {
+		return err
+	}
+
+	return dw.Close(nil)
+}
+
+// copySignon copies all frames up to the end of the signon data.
+func (c *clipper) copySignon(end int64) error {
+	for c.fr.pos < end {
+		h, err := c.fr.next()
+		if err != nil {
+			return errors.Wrap(unexpectedEOF(err), "failed to read signon data")
+		}
+
+		payload, err := c.fr.payload(h)
+		if err != nil {
+			return err
+		}
+
+		err = c.dw.WriteRawFrame(h.cmd, demowriter.SignonTick, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeStartFrames writes the keyframe and all frames before startTick at startTick, see ClipTicks().
+// Returns the header of the first frame of the clip.
+func (c *clipper) writeStartFrames(kf keyframe, startTick int32) (frameHeader, error) {
+	if c.fr.pos != kf.offset {
+		_, err := c.demo.Seek(kf.offset, io.SeekStart)
+		if err != nil {
+			return frameHeader{}, errors.Wrap(err, "failed to seek to keyframe")
+		}
+
+		c.fr = newFrameReader(c.demo, kf.offset)
+	}
+
+	for {
+		h, err := c.fr.next()
+		if err != nil {
+			return h, errors.Wrap(unexpectedEOF(err), "failed to read frame")
+		}
+
+		if h.cmd == msg.EDemoCommands_DEM_Stop {
+			return h, errors.Wrapf(ErrInvalidClipRange, "no frames after tick %d", startTick)
+		}
+
+		isKeyframe := h.offset == kf.offset && kf.full
+
+		if h.tick >= int(startTick) && !isKeyframe {
+			return h, nil
+		}
+
+		err = c.writeStartFrame(h, startTick)
+		if err != nil {
+			return h, err
+		}
+	}
+}
+
+// writeStartFrame writes a frame before the start of the clip at startTick, packets only keep the clipStateMessages.
+func (c *clipper) writeStartFrame(h frameHeader, startTick int32) error {
+	switch h.cmd {
+	case msg.EDemoCommands_DEM_FullPacket:
+		fullPacket := new(msg.CDemoFullPacket)
+
+		err := c.readFrame(h, fullPacket)
+		if err != nil {
+			return err
+		}
+
+		packet, err := clipStatePacket(h, fullPacket.GetPacket())
+		if err != nil {
+			return err
+		}
+
+		return c.dw.WriteFullPacket(startTick, fullPacket.GetStringTable(), packet)
+
+	case msg.EDemoCommands_DEM_Packet, msg.EDemoCommands_DEM_SignonPacket:
+		packet := new(msg.CDemoPacket)
+
+		err := c.readFrame(h, packet)
+		if err != nil {
+			return err
+		}
+
+		packet, err = clipStatePacket(h, packet)
+		if err != nil {
+			return err
+		}
+
+		return c.dw.WriteFrame(h.cmd, startTick, packet)
+
+	default:
+		payload, err := c.fr.payload(h)
+		if err != nil {
+			return err
+		}
+
+		return c.dw.WriteRawFrame(h.cmd, startTick, payload)
+	}
+}
+
+func (c *clipper) readFrame(h frameHeader, m proto.Message) error {
+	b, err := c.fr.payload(h)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(proto.Unmarshal(b, m), "failed to unmarshal %v at offset %d", h.cmd, h.offset)
+}
+
+// clipStatePacket returns a copy of the packet that only contains the clipStateMessages.
+func clipStatePacket(h frameHeader, packet *msg.CDemoPacket) (*msg.CDemoPacket, error) {
+	msgs, err := decodePacketMessages(packet.GetData())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode packet at offset %d", h.offset)
+	}
+
+	var state []demowriter.RawNetMessage
+
+	for _, m := range msgs {
+		if clipStateMessages[m.Type] {
+			state = append(state, m)
+		}
+	}
+
+	data, err := demowriter.EncodeRawPacketData(state...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &msg.CDemoPacket{Data: data}, nil
+}
+
+// copyFrames copies all frames from first until endTick (inclusive) or the end of the demo.
+func (c *clipper) copyFrames(h frameHeader, endTick int) error {
+	for h.cmd != msg.EDemoCommands_DEM_Stop && h.tick <= endTick {
+		payload, err := c.fr.payload(h)
+		if err != nil {
+			return err
+		}
+
+		err = c.dw.WriteRawFrame(h.cmd, int32(h.tick), payload) //nolint:gosec
+		if err != nil {
+			return err
+		}
+
+		h, err = c.fr.next()
+		if errors.Is(err, io.EOF) {
+			// truncated demo
+			return nil
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "failed to read frame")
+		}
+	}
+
+	return nil
+}
+
+// decodePacketMessages splits CDemoPacket.Data into its net-messages without decoding them, see handleDemoPacket().
+func decodePacketMessages(b []byte) (msgs []demowriter.RawNetMessage, err error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("failed to split packet into net-messages: %v", r)
+		}
+	}()
+
+	r := bitread.NewSmallBitReader(bytes.NewReader(b))
+
+	for len(b)*8-r.ActualPosition() > 7 {
+		t := int32(r.ReadUBitInt()) //nolint:gosec
+		size := r.ReadVarInt32()
+
+		msgs = append(msgs, demowriter.RawNetMessage{Type: t, Data: r.ReadBytes(int(size))})
+	}
+
+	return msgs, nil
+}
@@ -0,0 +1,194 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/demowriter"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+func testConVarMessage(name string, value int) demowriter.NetMessage {
+	return demowriter.NetMessage{
+		Type: int32(msg.NET_Messages_net_SetConVar),
+		Msg: &msg.CNETMsg_SetConVar{Convars: &msg.CMsg_CVars{Cvars: []*msg.CMsg_CVars_CVar{{
+			Name:  proto.String(name),
+			Value: proto.String(strconv.Itoa(value)),
+		}}}},
+	}
+}
+
+func testClipDemoData(t *testing.T) []byte {
+	t.Helper()
+
+	b := newTestDemoBuilder().signon()
+
+	for tick := int32(1); tick <= 10; tick++ {
+		if tick == 5 {
+			b.fullPacket(tick)
+
+			continue
+		}
+
+		b.packet(tick, testConVarMessage("tick", int(tick)))
+	}
+
+	return b.bytes()
+}
+
+func TestClipTicks(t *testing.T) {
+	var clip bytes.Buffer
+
+	err := ClipTicks(&clip, bytes.NewReader(testClipDemoData(t)), 8, 9)
+	assert.NoError(t, err)
+
+	p := NewParser(bytes.NewReader(clip.Bytes()))
+
+	conVarsByTick := make(map[int]string)
+
+	p.RegisterEventHandler(func(events.FrameDone) {
+		if tick := p.GameState().IngameTick(); tick > 0 {
+			conVarsByTick[tick] = p.GameState().Rules().ConVars()["tick"]
+		}
+	})
+
+	err = p.ParseToEnd()
+	assert.NoError(t, err)
+
+	// the keyframe at tick 5 and the frames of ticks 6 & 7 are written at the start tick
+	assert.Equal(t, map[int]string{8: "8", 9: "9"}, conVarsByTick)
+
+	idx, err := BuildDemoIndex(bytes.NewReader(clip.Bytes()))
+	assert.NoError(t, err)
+
+	if assert.Len(t, idx.FullPackets(), 1) {
+		assert.Equal(t, 8, idx.FullPackets()[0].Tick)
+	}
+
+	assert.Equal(t, 9, idx.LastTick)
+}
+
+func TestClipTicks_StartState(t *testing.T) {
+	b := newTestDemoBuilder().signon()
+
+	b.packet(1, testConVarMessage("a", 1))
+	b.fullPacket(2, testConVarMessage("a", 2))
+	b.packet(3, testConVarMessage("b", 3))
+	b.frame(msg.EDemoCommands_DEM_StringTables, 4, nil)
+	b.packet(5, testConVarMessage("a", 5))
+	b.packet(6, testConVarMessage("b", 6))
+	b.packet(7, testConVarMessage("a", 7))
+
+	data := b.bytes()
+
+	var clip bytes.Buffer
+
+	err := ClipTicks(&clip, bytes.NewReader(data), 6, 7)
+	assert.NoError(t, err)
+
+	reference := NewParser(bytes.NewReader(data))
+
+	err = reference.SeekToTick(6)
+	assert.NoError(t, err)
+
+	p := NewParser(bytes.NewReader(clip.Bytes()))
+
+	for p.GameState().IngameTick() < 6 {
+		ok, err := p.ParseNextFrame()
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	// all frames before the start tick are written at the start tick, the state is complete after the last of them
+	for i := 0; i < 4; i++ {
+		_, err = p.ParseNextFrame()
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 6, p.GameState().IngameTick())
+	assert.Equal(t, reference.GameState().Rules().ConVars(), p.GameState().Rules().ConVars())
+
+	type frame struct {
+		cmd  msg.EDemoCommands
+		tick int
+	}
+
+	var frames []frame
+
+	fr, err := newDemoFrameReader(bytes.NewReader(clip.Bytes()))
+	assert.NoError(t, err)
+
+	for {
+		h, err := fr.next()
+		if !assert.NoError(t, err) || h.cmd == msg.EDemoCommands_DEM_Stop {
+			break
+		}
+
+		if h.cmd != msg.EDemoCommands_DEM_FileHeader && h.cmd != msg.EDemoCommands_DEM_SignonPacket && h.cmd != msg.EDemoCommands_DEM_SyncTick {
+			frames = append(frames, frame{cmd: h.cmd, tick: h.tick})
+		}
+
+		assert.NoError(t, fr.skip(h))
+	}
+
+	assert.Equal(t, []frame{
+		{msg.EDemoCommands_DEM_FullPacket, 6},
+		{msg.EDemoCommands_DEM_Packet, 6},
+		{msg.EDemoCommands_DEM_StringTables, 6},
+		{msg.EDemoCommands_DEM_Packet, 6},
+		{msg.EDemoCommands_DEM_Packet, 6},
+		{msg.EDemoCommands_DEM_Packet, 7},
+	}, frames)
+}
+
+func TestClipTicks_InvalidRange(t *testing.T) {
+	data := testClipDemoData(t)
+
+	err := ClipTicks(new(bytes.Buffer), bytes.NewReader(data), 9, 8)
+	assert.ErrorIs(t, err, ErrInvalidClipRange)
+
+	err = ClipTicks(new(bytes.Buffer), bytes.NewReader(data), 100, 200)
+	assert.ErrorIs(t, err, ErrInvalidClipRange)
+}
+
+func TestClipRounds(t *testing.T) {
+	data := testRoundsDemoData()
+
+	var clip bytes.Buffer
+
+	err := ClipRounds(&clip, bytes.NewReader(data), 2, 2)
+	assert.NoError(t, err)
+
+	idx, err := BuildDemoIndex(bytes.NewReader(clip.Bytes()))
+	assert.NoError(t, err)
+
+	if assert.Len(t, idx.RoundStarts(), 1) {
+		assert.Equal(t, 40, idx.RoundStarts()[0].Tick)
+	}
+
+	// the round end & kills of round 1 are dropped
+	assert.Empty(t, idx.RoundEnds())
+
+	if assert.Len(t, idx.FullPackets(), 1) {
+		assert.Equal(t, 40, idx.FullPackets()[0].Tick)
+	}
+
+	clip.Reset()
+
+	err = ClipRounds(&clip, bytes.NewReader(data), 1, 1)
+	assert.NoError(t, err)
+
+	idx, err = BuildDemoIndex(bytes.NewReader(clip.Bytes()))
+	assert.NoError(t, err)
+	assert.Len(t, idx.RoundStarts(), 1)
+	assert.Len(t, idx.RoundEnds(), 1)
+	assert.Equal(t, 30, idx.LastTick)
+
+	err = ClipRounds(new(bytes.Buffer), bytes.NewReader(data), 3, 3)
+	assert.ErrorIs(t, err, ErrInvalidClipRange)
+}
@@ -2,12 +2,19 @@ package common
 
 import st "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/sendtables"
 
+// getInt and the other getters return zero values if the entity is nil or the property has no value,
+// e.g. because it was filtered out with ParserConfig.EntityPropertyPrefixes.
 func getInt(entity st.Entity, propName string) int {
 	if entity == nil {
 		return 0
 	}
 
-	return entity.PropertyValueMust(propName).Int()
+	value := entity.PropertyValueMust(propName)
+	if value.Any == nil {
+		return 0
+	}
+
+	return value.Int()
 }
 
 func getUInt64(entity st.Entity, propName string) uint64 {
@@ -15,7 +22,12 @@ func getUInt64(entity st.Entity, propName string) uint64 {
 		return 0
 	}
 
-	return entity.PropertyValueMust(propName).UInt64()
+	value := entity.PropertyValueMust(propName)
+	if value.Any == nil {
+		return 0
+	}
+
+	return value.UInt64()
 }
 
 func getFloat(entity st.Entity, propName string) float32 {
@@ -23,7 +35,12 @@ func getFloat(entity st.Entity, propName string) float32 {
 		return 0
 	}
 
-	return entity.PropertyValueMust(propName).Float()
+	value := entity.PropertyValueMust(propName)
+	if value.Any == nil {
+		return 0
+	}
+
+	return value.Float()
 }
 
 func getFloatIfExists(entity st.Entity, propName string) (float32, bool) {
@@ -49,7 +66,12 @@ func getString(entity st.Entity, propName string) string {
 		return ""
 	}
 
-	return entity.PropertyValueMust(propName).String()
+	value := entity.PropertyValueMust(propName)
+	if value.Any == nil {
+		return ""
+	}
+
+	return value.String()
 }
 
 func getBool(entity st.Entity, propName string) bool {
@@ -57,5 +79,10 @@ func getBool(entity st.Entity, propName string) bool {
 		return false
 	}
 
-	return entity.PropertyValueMust(propName).BoolVal()
+	value := entity.PropertyValueMust(propName)
+	if value.Any == nil {
+		return false
+	}
+
+	return value.BoolVal()
 }
@@ -53,3 +53,13 @@ func TestGetString_Nil(t *testing.T) {
 func TestGetBool_Nil(t *testing.T) {
 	assert.Empty(t, getBool(nil, "test"))
 }
+
+func TestGetters_NilValue(t *testing.T) {
+	entity := entityWithProperty("test", st.PropertyValue{Any: nil})
+
+	assert.Zero(t, getInt(entity, "test"))
+	assert.Zero(t, getUInt64(entity, "test"))
+	assert.Zero(t, getFloat(entity, "test"))
+	assert.Empty(t, getString(entity, "test"))
+	assert.False(t, getBool(entity, "test"))
+}
@@ -2,11 +2,15 @@ package cstv
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -27,50 +31,216 @@ type sync struct {
 	TokenRedirect    string  `json:"token_redirect"`
 }
 
+// fragmentHandler is called with the data of each fragment that was downloaded, see Recorder.
+type fragmentHandler func(frag int, kind fragmentKind, data []byte) error
+
+type fragmentKind string
+
+const (
+	fragmentStart fragmentKind = "start"
+	fragmentFull  fragmentKind = "full"
+	fragmentDelta fragmentKind = "delta"
+)
+
+// RequestDecorator is called for each request before it's sent, e.g. to add auth headers or tokens.
+type RequestDecorator func(*http.Request) error
+
+// StatusError is returned by requests that were answered with a status other than 200 OK.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d from %q", e.StatusCode, e.URL)
+}
+
+// RetryPolicy decides whether and when failed requests are retried.
+type RetryPolicy interface {
+	// Backoff returns how long to wait before retrying a request that failed attempt times in a row (starting at 1).
+	// err is the last error, a *StatusError if the server answered with a status other than 200 OK.
+	// ok is false if the request shouldn't be retried anymore.
+	Backoff(attempt int, err error) (d time.Duration, ok bool)
+}
+
+// ExponentialBackoff is a RetryPolicy that multiplies the backoff by Factor after each failed attempt.
+type ExponentialBackoff struct {
+	Initial    time.Duration // Backoff after the first failed attempt
+	Factor     float64
+	MaxRetries int           // 0 means no limit
+	MaxBackoff time.Duration // Requests aren't retried anymore once the backoff exceeds this, 0 means no limit
+}
+
+// Backoff implements RetryPolicy.
+func (b ExponentialBackoff) Backoff(attempt int, _ error) (time.Duration, bool) {
+	if b.MaxRetries > 0 && attempt > b.MaxRetries {
+		return 0, false
+	}
+
+	d := time.Duration(float64(b.Initial) * math.Pow(b.Factor, float64(attempt-1)))
+
+	if b.MaxBackoff > 0 && d > b.MaxBackoff {
+		return 0, false
+	}
+
+	return d, true
+}
+
+// noRetries is a RetryPolicy that never retries.
+type noRetries struct{}
+
+// Backoff implements RetryPolicy.
+func (noRetries) Backoff(int, error) (time.Duration, bool) {
+	return 0, false
+}
+
+// DefaultRetryPolicy is the RetryPolicy used if ReaderConfig.Retry is nil.
+var DefaultRetryPolicy RetryPolicy = ExponentialBackoff{
+	Initial:    time.Second,
+	Factor:     1.5,
+	MaxRetries: 5,
+	MaxBackoff: 10 * time.Second,
+}
+
+// ReaderConfig contains the configuration for a Reader.
+// The zero value is a valid configuration.
+type ReaderConfig struct {
+	// Client is used for all requests, e.g. for proxies or custom TLS settings.
+	// http.DefaultClient is used if nil.
+	Client *http.Client
+
+	// RequestDecorators are called for each request before it's sent.
+	RequestDecorators []RequestDecorator
+
+	// Retry decides whether and when failed requests are retried.
+	// If a delta fragment isn't available after the last retry the broadcast is considered finished, see NewReader().
+	// DefaultRetryPolicy is used if nil.
+	Retry RetryPolicy
+
+	// Context cancels requests and backoffs, Read() returns its error afterwards.
+	// context.Background() is used if nil.
+	Context context.Context
+
+	// SyncInterval is how often /sync is re-queried while reading, to keep Stats() up to date and follow token redirects.
+	// 0 (or a negative value) disables it, /sync is then only requested once by NewReader().
+	SyncInterval time.Duration
+
+	// StartFragment is the fragment the reader starts at, e.g. to resume after a restart.
+	// 0 starts at the fragment of /sync, StartSignup replays the whole broadcast from the signup fragment.
+	// The reader catches up with the live game as fast as the fragments are consumed, see Stats.Live.
+	StartFragment int
+
+	// StartTick starts at the newest fragment that starts at or before the tick, if StartFragment is 0.
+	// The fragments are searched via their full fragment, so the reader may start a bit earlier than necessary.
+	StartTick int
+}
+
+// StartSignup can be used as ReaderConfig.StartFragment to replay a broadcast from the beginning.
+const StartSignup = -1
+
+// Stats contains live statistics about a broadcast, see Reader.Stats().
+type Stats struct {
+	Fragment          int       // Number of the last received fragment
+	FragmentsReceived int       // Including the start and full fragment
+	BytesReceived     int64     // Total size of all received fragments
+	Retries           int       // Total number of failed requests that were retried
+	LastFragmentAt    time.Time // When the last fragment was received
+
+	// Values of the last /sync response
+
+	SyncedAt         time.Time     // When /sync was requested
+	MaxTick          int           // Newest tick of the broadcast
+	Tps              int           // Ticks per second
+	KeyframeInterval time.Duration // Length of a fragment
+	RtDelay          time.Duration // How far the fragment of the sync was behind the live game
+	RcvAge           time.Duration // How long ago the relay received the newest fragment
+
+	// Live is true once the reader caught up with the broadcast, i.e. the next fragment wasn't available yet
+	Live bool
+}
+
 type Reader struct {
-	baseUrl string
-	sync    sync
-	frag    int
-	buf     bytes.Buffer
-	timeout time.Duration
+	baseUrl    string // /sync is always requested from here
+	url        string // baseUrl joined with the token redirect
+	sync       sync
+	frag       int
+	buf        bytes.Buffer
+	config     ReaderConfig
+	onFragment fragmentHandler
+	stats      atomic.Pointer[Stats]
 }
 
-func (c *Reader) Read(p []byte) (n int, err error) {
-	n, err = c.buf.Read(p)
+// Stats returns live statistics about the broadcast, e.g. to monitor how far behind the live game the reader is.
+// It may be called concurrently with Read().
+func (c *Reader) Stats() Stats {
+	return *c.stats.Load()
+}
 
-	nFails := 0
-	backoff := time.Second
+func (c *Reader) updateStats(update func(*Stats)) {
+	stats := c.Stats()
+	update(&stats)
+	c.stats.Store(&stats)
+}
 
-	for n < len(p) && errors.Is(err, io.EOF) {
-		deltaUrl := c.baseUrl + fmt.Sprintf("/%d/delta", c.frag)
+func (c *Reader) receivedFragment(frag int, data []byte) {
+	c.updateStats(func(s *Stats) {
+		s.Fragment = frag
+		s.FragmentsReceived++
+		s.BytesReceived += int64(len(data))
+		s.LastFragmentAt = time.Now()
+	})
+}
 
-		deltaResp, err := http.Get(deltaUrl)
-		if err != nil {
-			return n, fmt.Errorf("failed to get %q: %w", deltaUrl, err)
-		}
+func (c *Reader) receivedSync(s sync, at time.Time) {
+	c.updateStats(func(stats *Stats) {
+		stats.SyncedAt = at
+		stats.MaxTick = s.MaxTick
+		stats.Tps = s.Tps
+		stats.KeyframeInterval = time.Duration(s.KeyframeInterval) * time.Second
+		stats.RtDelay = time.Duration(s.RtDelay * float64(time.Second))
+		stats.RcvAge = time.Duration(s.RcvAge * float64(time.Second))
+	})
+}
 
-		defer deltaResp.Body.Close()
+// syncDue returns whether /sync should be re-queried, see ReaderConfig.SyncInterval.
+func (c *Reader) syncDue() bool {
+	if c.config.SyncInterval <= 0 {
+		return false
+	}
 
-		if deltaResp.StatusCode != http.StatusOK {
-			time.Sleep(backoff)
+	return time.Since(c.Stats().SyncedAt) >= c.config.SyncInterval
+}
 
-			backoff = time.Duration(float64(backoff) * 1.5)
-			nFails++
+func (c *Reader) Read(p []byte) (n int, err error) {
+	n, err = c.buf.Read(p)
 
-			if nFails == 5 || backoff > c.timeout {
-				return n, fmt.Errorf("%w: end of CSTV stream", io.EOF)
-			}
+	// only wait for the next fragment if there's nothing to return yet, so buffered data is parsed right away
+	for n == 0 && errors.Is(err, io.EOF) {
+		if c.syncDue() {
+			// the old values are kept if this fails
+			_ = c.resync()
+		}
 
-			continue
+		delta, err := c.get(func() string { return fmt.Sprintf("%s/%d/delta", c.url, c.frag) }, true, c.caughtUp)
+		if isNotFound(err) {
+			return n, fmt.Errorf("%w: end of CSTV stream", io.EOF)
 		}
 
-		_, err = io.Copy(&c.buf, deltaResp.Body)
 		if err != nil {
-			return n, fmt.Errorf("failed to read response from %q: %w", deltaUrl, err)
+			return n, fmt.Errorf("failed to get delta fragment %d: %w", c.frag, err)
 		}
 
+		if c.onFragment != nil {
+			err = c.onFragment(c.frag, fragmentDelta, delta)
+			if err != nil {
+				return n, err
+			}
+		}
+
+		c.receivedFragment(c.frag, delta)
+		c.buf.Write(delta)
+
 		c.frag++
-		backoff = time.Second // reset backoff on success
 
 		n2, err := c.buf.Read(p[n:])
 		n += n2
@@ -83,72 +253,337 @@ func (c *Reader) Read(p []byte) (n int, err error) {
 	return n, err
 }
 
+// caughtUp marks the reader as live, see Stats.Live.
+func (c *Reader) caughtUp() {
+	if !c.Stats().Live {
+		c.updateStats(func(s *Stats) {
+			s.Live = true
+		})
+	}
+}
+
+// get requests the URL returned by url until it succeeds or the RetryPolicy gives up.
+// If resync is true, /sync is re-queried before retrying requests that didn't fail with 404 Not Found,
+// so a changed token redirect is followed.
+// notFound is called if a request failed with 404 Not Found, it may be nil.
+func (c *Reader) get(url func() string, resync bool, notFound func()) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		b, err := c.getOnce(url())
+		if err == nil {
+			return b, nil
+		}
+
+		if notFound != nil && isNotFound(err) {
+			notFound()
+		}
+
+		if c.config.Context.Err() != nil {
+			return nil, c.config.Context.Err()
+		}
+
+		backoff, ok := c.config.Retry.Backoff(attempt, err)
+		if !ok {
+			return nil, err
+		}
+
+		c.updateStats(func(s *Stats) {
+			s.Retries++
+		})
+
+		select {
+		case <-c.config.Context.Done():
+			return nil, c.config.Context.Err()
+
+		case <-time.After(backoff):
+		}
+
+		if resync && !isNotFound(err) {
+			// the next attempt uses the old URL if this fails
+			_ = c.resync()
+		}
+	}
+}
+
+func isNotFound(err error) bool {
+	var statusErr *StatusError
+
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+func (c *Reader) getOnce(url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(c.config.Context, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %q: %w", url, err)
+	}
+
+	for _, decorate := range c.config.RequestDecorators {
+		err = decorate(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decorate request for %q: %w", url, err)
+		}
+	}
+
+	resp, err := c.config.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %q: %w", url, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %q: %w", url, err)
+	}
+
+	return b, nil
+}
+
+// resync requests /sync once and follows the token redirect.
+func (c *Reader) resync() error {
+	at := time.Now()
+
+	b, err := c.getOnce(c.baseUrl + "/sync")
+	if err != nil {
+		return err
+	}
+
+	s, u, err := c.decodeSync(b)
+	if err != nil {
+		return err
+	}
+
+	c.url = u
+	c.receivedSync(s, at)
+
+	return nil
+}
+
+// decodeSync decodes a /sync response and returns it along with the URL of the fragments.
+func (c *Reader) decodeSync(b []byte) (sync, string, error) {
+	var s sync
+
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return s, "", fmt.Errorf("failed to decode response from %q: %w", c.baseUrl+"/sync", err)
+	}
+
+	u, err := url.JoinPath(c.baseUrl, s.TokenRedirect)
+	if err != nil {
+		return s, "", fmt.Errorf("failed to join base url and token redirect: %w", err)
+	}
+
+	return s, u, nil
+}
+
 // NewReader creates a new CSTV reader.
 // The timeout is the maximum time to retry for a response from the CSTV server,
 // using an exponential backoff mechanism, starting at 1s.
 // If the timeout is exceeded, the reader will return an io.EOF error.
+// A timeout <= 0 disables retries.
+// Other errors (e.g. a *StatusError for 403 Forbidden) are returned as they are.
+//
+// See also: NewReaderWithConfig()
 func NewReader(baseUrl string, timeout time.Duration) (*Reader, error) {
-	syncUrl := baseUrl + "/sync"
+	return NewReaderWithConfig(baseUrl, ReaderConfig{Retry: TimeoutRetryPolicy(timeout)})
+}
 
-	syncResp, err := http.Get(syncUrl)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get sync from %q: %w", syncUrl, err)
+// NewReaderWithConfig creates a new CSTV reader with a custom configuration.
+// The reader returns an io.EOF error once the next delta fragment isn't available (404 Not Found) after the last retry,
+// other errors of the last retry (e.g. a *StatusError for 403 Forbidden) are returned as they are.
+func NewReaderWithConfig(baseUrl string, config ReaderConfig) (*Reader, error) {
+	return newReader(baseUrl, config, nil)
+}
+
+// TimeoutRetryPolicy returns DefaultRetryPolicy with the maximum backoff set to timeout, see NewReader().
+// Requests aren't retried if timeout is <= 0.
+func TimeoutRetryPolicy(timeout time.Duration) RetryPolicy {
+	if timeout <= 0 {
+		return noRetries{}
 	}
 
-	defer syncResp.Body.Close()
+	policy := DefaultRetryPolicy.(ExponentialBackoff) //nolint:forcetypeassert
+	policy.MaxBackoff = timeout
 
-	b, err := io.ReadAll(syncResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response from %q: %w", syncUrl, err)
+	return policy
+}
+
+func newReader(baseUrl string, config ReaderConfig, onFragment fragmentHandler) (*Reader, error) {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
 	}
 
-	var s sync
+	if config.Retry == nil {
+		config.Retry = DefaultRetryPolicy
+	}
+
+	if config.Context == nil {
+		config.Context = context.Background()
+	}
+
+	r := &Reader{
+		baseUrl:    baseUrl,
+		config:     config,
+		onFragment: onFragment,
+	}
+
+	r.stats.Store(new(Stats))
+
+	syncedAt := time.Now()
 
-	err = json.Unmarshal(b, &s)
+	b, err := r.get(func() string { return baseUrl + "/sync" }, false, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode response from %q: %w", syncUrl, err)
+		return nil, fmt.Errorf("failed to get sync: %w", err)
 	}
 
-	baseUrl, err = url.JoinPath(baseUrl, s.TokenRedirect)
+	r.sync, r.url, err = r.decodeSync(b)
 	if err != nil {
-		return nil, fmt.Errorf("failed to join base url and token redirect: %w", err)
+		return nil, err
 	}
 
-	startUrl := fmt.Sprintf(baseUrl+"/%d/start", s.SignupFragment)
+	r.receivedSync(r.sync, syncedAt)
 
-	startResp, err := http.Get(startUrl)
+	start, err := r.get(func() string { return fmt.Sprintf("%s/%d/start", r.url, r.sync.SignupFragment) }, true, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get %q: %w", startUrl, err)
+		return nil, fmt.Errorf("failed to get start fragment: %w", err)
 	}
 
-	defer startResp.Body.Close()
+	full, err := r.startFragment()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get full fragment: %w", err)
+	}
 
-	var buf bytes.Buffer
+	if onFragment != nil {
+		err = onFragment(r.sync.SignupFragment, fragmentStart, start)
+		if err != nil {
+			return nil, err
+		}
 
-	_, err = io.Copy(&buf, startResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response from %q: %w", startUrl, err)
+		err = onFragment(r.sync.Fragment, fragmentFull, full)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	fullUrl := fmt.Sprintf(baseUrl+"/%d/full", s.Fragment)
+	r.receivedFragment(r.sync.SignupFragment, start)
+	r.receivedFragment(r.sync.Fragment, full)
 
-	fullResp, err := http.Get(fullUrl)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get %q: %w", fullUrl, err)
+	r.frag = r.sync.Fragment
+
+	r.buf.Write(start)
+	r.buf.Write(full)
+
+	return r, nil
+}
+
+// startFragment sets sync.Fragment to the fragment the reader starts at and returns its full fragment,
+// see ReaderConfig.StartFragment and ReaderConfig.StartTick.
+func (c *Reader) startFragment() ([]byte, error) {
+	switch {
+	case c.config.StartFragment == StartSignup:
+		c.sync.Fragment = c.sync.SignupFragment
+
+		full, err := c.get(func() string { return c.fullURL(c.sync.Fragment) }, true, nil)
+		if isNotFound(err) {
+			// the start fragment already contains the full state
+			return nil, nil
+		}
+
+		return full, err
+
+	case c.config.StartFragment > 0:
+		c.sync.Fragment = c.config.StartFragment
+
+	case c.config.StartTick > 0:
+		return c.fragmentAtTick(c.config.StartTick)
 	}
 
-	defer fullResp.Body.Close()
+	return c.get(func() string { return c.fullURL(c.sync.Fragment) }, true, nil)
+}
 
-	_, err = io.Copy(&buf, fullResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response from %q: %w", fullUrl, err)
+func (c *Reader) fullURL(frag int) string {
+	return fmt.Sprintf("%s/%d/full", c.url, frag)
+}
+
+// maxFragmentAtTickRequests limits the full fragments requested by fragmentAtTick().
+// The search is a bisection, so this is enough for any realistic broadcast.
+const maxFragmentAtTickRequests = 32
+
+// fragmentAtTick searches the newest fragment that starts at or before tick
+// by bisecting the fragments between the signup fragment and the fragment of /sync (or an estimate for newer ticks).
+// Fragments without a full fragment are skipped. It falls back to the signup fragment.
+func (c *Reader) fragmentAtTick(tick int) ([]byte, error) {
+	var (
+		best     = -1
+		bestFull []byte
+		requests int
+	)
+
+	lo, hi := c.sync.SignupFragment, c.sync.Fragment
+
+	if ticksPerFragment := c.sync.Tps * c.sync.KeyframeInterval; ticksPerFragment > 0 && tick > c.sync.Tick {
+		// newer fragments than the one of /sync are estimated, up to the newest tick of the broadcast
+		hi += (min(tick, c.sync.MaxTick)-c.sync.Tick)/ticksPerFragment + 1
+	}
+
+	for lo <= hi && requests < maxFragmentAtTickRequests {
+		mid := lo + (hi-lo)/2
+
+		// use the closest fragment at or before mid that has a full fragment
+		frag := mid
+
+		var (
+			full []byte
+			err  error
+		)
+
+		for ; frag >= lo && requests < maxFragmentAtTickRequests; frag-- {
+			requests++
+
+			full, err = c.getOnce(c.fullURL(frag))
+			if !isNotFound(err) {
+				break
+			}
+		}
+
+		if err != nil && !isNotFound(err) {
+			return nil, err
+		}
+
+		switch {
+		case err != nil:
+			// no full fragment in lo..mid
+			lo = mid + 1
+
+		case fragmentTick(full) > tick:
+			hi = frag - 1
+
+		default:
+			best, bestFull = frag, full
+			lo = mid + 1
+		}
+	}
+
+	if best < 0 {
+		c.sync.Fragment = c.sync.SignupFragment
+
+		return nil, nil
+	}
+
+	c.sync.Fragment = best
+
+	return bestFull, nil
+}
+
+// fragmentTick returns the tick of the first frame of a fragment, -1 if it's empty.
+func fragmentTick(fragment []byte) int {
+	_, n := binary.Uvarint(fragment)
+	if n <= 0 || len(fragment) < n+4 {
+		return -1
 	}
 
-	return &Reader{
-		baseUrl: baseUrl,
-		sync:    s,
-		buf:     buf,
-		frag:    s.Fragment,
-		timeout: timeout,
-	}, nil
+	return int(binary.LittleEndian.Uint32(fragment[n:])) //nolint:gosec
 }
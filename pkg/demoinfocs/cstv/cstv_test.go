@@ -0,0 +1,428 @@
+package cstv_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	demoinfocs "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/cstv"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+)
+
+var noBackoff = cstv.ExponentialBackoff{MaxRetries: 2}
+
+func parseTicks(t *testing.T, r io.Reader) []string {
+	t.Helper()
+
+	config := demoinfocs.DefaultParserConfig
+	config.Format = demoinfocs.DemoFormatCSTVBroadcast
+
+	p := demoinfocs.NewParserWithConfig(r, config)
+
+	var ticks []string
+
+	p.RegisterEventHandler(func(events.ConVarsUpdated) {
+		ticks = append(ticks, p.GameState().Rules().ConVars()["tick"])
+	})
+
+	assert.NoError(t, p.ParseToEnd())
+
+	return ticks
+}
+
+func TestReader_RequestDecorators(t *testing.T) {
+	srv, err := cstv.NewServerWithConfig(bytes.NewReader(testServerDemo(t)), cstv.ServerConfig{KeyframeInterval: 5 * time.Second, Tps: 1})
+	assert.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		srv.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	_, err = cstv.NewReaderWithConfig(ts.URL, cstv.ReaderConfig{Retry: noBackoff})
+
+	var statusErr *cstv.StatusError
+
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+
+	r, err := cstv.NewReaderWithConfig(ts.URL, cstv.ReaderConfig{
+		Client: ts.Client(),
+		RequestDecorators: []cstv.RequestDecorator{func(req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer secret")
+
+			return nil
+		}},
+		Retry: noBackoff,
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, parseTicks(t, r), 21)
+}
+
+func TestReader_Read_StatusError(t *testing.T) {
+	srv, err := cstv.NewServerWithConfig(bytes.NewReader(testServerDemo(t)), cstv.ServerConfig{KeyframeInterval: 5 * time.Second, Tps: 1})
+	assert.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the token expires in the middle of the broadcast
+		if r.URL.Path == "/3/delta" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		srv.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	r, err := cstv.NewReaderWithConfig(ts.URL, cstv.ReaderConfig{Retry: noBackoff})
+	assert.NoError(t, err)
+
+	_, err = io.Copy(io.Discard, r)
+
+	var statusErr *cstv.StatusError
+
+	assert.NotErrorIs(t, err, io.EOF)
+
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+	}
+}
+
+func TestReader_TokenRedirect(t *testing.T) {
+	srv, err := cstv.NewServerWithConfig(bytes.NewReader(testServerDemo(t)), cstv.ServerConfig{KeyframeInterval: 5 * time.Second, Tps: 1})
+	assert.NoError(t, err)
+
+	var token atomic.Value
+
+	token.Store("a")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := token.Load().(string)
+
+		if r.URL.Path == "/sync" {
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, r)
+
+			var sync map[string]any
+
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sync))
+
+			sync["token_redirect"] = current
+
+			assert.NoError(t, json.NewEncoder(w).Encode(sync))
+
+			return
+		}
+
+		path, ok := strings.CutPrefix(r.URL.Path, "/"+current)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		// the relay moves after the second delta fragment
+		if path == "/2/delta" {
+			token.Store("b")
+		}
+
+		r.URL.Path = path
+		srv.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	r, err := cstv.NewReaderWithConfig(ts.URL, cstv.ReaderConfig{Retry: noBackoff})
+	assert.NoError(t, err)
+
+	expected := []string{"0"}
+	for tick := 1; tick <= 20; tick++ {
+		expected = append(expected, strconv.Itoa(tick))
+	}
+
+	assert.Equal(t, expected, parseTicks(t, r))
+	assert.Equal(t, "b", token.Load())
+}
+
+func TestReader_Context(t *testing.T) {
+	var requests atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := cstv.NewReaderWithConfig(ts.URL, cstv.ReaderConfig{
+		Retry:   cstv.ExponentialBackoff{Initial: time.Hour},
+		Context: ctx,
+	})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	policy := cstv.ExponentialBackoff{Initial: time.Second, Factor: 2, MaxRetries: 3, MaxBackoff: 3 * time.Second}
+
+	d, ok := policy.Backoff(1, nil)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, d)
+
+	d, ok = policy.Backoff(2, nil)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, d)
+
+	_, ok = policy.Backoff(3, nil) // exceeds MaxBackoff
+	assert.False(t, ok)
+
+	_, ok = cstv.ExponentialBackoff{Factor: 1, MaxRetries: 3}.Backoff(4, nil)
+	assert.False(t, ok)
+}
+
+func TestTimeoutRetryPolicy(t *testing.T) {
+	d, ok := cstv.TimeoutRetryPolicy(10*time.Second).Backoff(1, nil)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, d)
+
+	_, ok = cstv.TimeoutRetryPolicy(0).Backoff(1, nil)
+	assert.False(t, ok)
+
+	_, ok = cstv.TimeoutRetryPolicy(-time.Second).Backoff(1, nil)
+	assert.False(t, ok)
+}
+
+func TestNewReader_NoTimeout(t *testing.T) {
+	srv := newTestServer(t, cstv.ServerConfig{KeyframeInterval: 5 * time.Second, Tps: 1})
+	defer srv.Close()
+
+	r, err := cstv.NewReader(srv.URL, 0)
+	assert.NoError(t, err)
+
+	start := time.Now()
+
+	_, err = io.Copy(io.Discard, r)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Less(t, time.Since(start), time.Second) // the missing 6th delta fragment isn't retried
+	assert.Zero(t, r.Stats().Retries)
+}
+
+func TestReader_Stats(t *testing.T) {
+	srv := newTestServer(t, cstv.ServerConfig{KeyframeInterval: 5 * time.Second, Tps: 1})
+	defer srv.Close()
+
+	r, err := cstv.NewReaderWithConfig(srv.URL, cstv.ReaderConfig{Retry: noBackoff})
+	assert.NoError(t, err)
+
+	stats := r.Stats()
+	assert.Equal(t, 1, stats.Fragment)
+	assert.Equal(t, 2, stats.FragmentsReceived) // start + full
+	assert.Equal(t, 20, stats.MaxTick)
+	assert.Equal(t, 1, stats.Tps)
+	assert.Equal(t, 5*time.Second, stats.KeyframeInterval)
+	assert.False(t, stats.SyncedAt.IsZero())
+
+	_, err = io.Copy(io.Discard, r)
+	assert.ErrorIs(t, err, io.EOF)
+
+	stats = r.Stats()
+	assert.Equal(t, 5, stats.Fragment)
+	assert.Equal(t, 7, stats.FragmentsReceived)
+	assert.Equal(t, 2, stats.Retries) // the missing 6th delta fragment
+	assert.Positive(t, stats.BytesReceived)
+}
+
+func TestReader_SyncInterval(t *testing.T) {
+	// a keyframe interval of 0 must not cause a /sync request per fragment
+	srv := newTestServer(t, cstv.ServerConfig{Tps: 1})
+	defer srv.Close()
+
+	readAll := func(syncInterval time.Duration) (syncs int) {
+		r, err := cstv.NewReaderWithConfig(srv.URL, cstv.ReaderConfig{
+			Retry:        noBackoff,
+			SyncInterval: syncInterval,
+			RequestDecorators: []cstv.RequestDecorator{func(req *http.Request) error {
+				if strings.HasSuffix(req.URL.Path, "/sync") {
+					syncs++
+				}
+
+				return nil
+			}},
+		})
+		assert.NoError(t, err)
+
+		_, err = io.Copy(io.Discard, r)
+		assert.ErrorIs(t, err, io.EOF)
+
+		return syncs
+	}
+
+	assert.Equal(t, 1, readAll(0))
+	assert.Greater(t, readAll(time.Nanosecond), 1)
+}
+
+func TestParser_BroadcastEvents(t *testing.T) {
+	srv := newTestServer(t, cstv.ServerConfig{KeyframeInterval: 5 * time.Second, Tps: 1})
+	defer srv.Close()
+
+	config := demoinfocs.DefaultParserConfig
+	config.CSTVReaderConfig = cstv.ReaderConfig{Retry: noBackoff}
+
+	p, err := demoinfocs.NewCSTVBroadcastParserWithConfig(srv.URL, config)
+	assert.NoError(t, err)
+
+	var (
+		fragments []int
+		lags      []events.BroadcastLagChanged
+	)
+
+	p.RegisterEventHandler(func(e events.BroadcastFragmentReceived) {
+		fragments = append(fragments, e.Fragment)
+	})
+
+	p.RegisterEventHandler(func(e events.BroadcastLagChanged) {
+		lags = append(lags, e)
+	})
+
+	assert.NoError(t, p.ParseToEnd())
+
+	if assert.NotEmpty(t, fragments) {
+		assert.Equal(t, 5, fragments[len(fragments)-1])
+	}
+
+	if assert.NotEmpty(t, lags) {
+		assert.Equal(t, events.BroadcastLagChanged{IngameTick: 0, MaxTick: 20, Lag: 20, LagTime: 20 * time.Second}, lags[0])
+
+		for _, lag := range lags {
+			assert.Equal(t, lag.MaxTick-lag.IngameTick, lag.Lag)
+		}
+	}
+}
+
+func ticksFrom(first int) []string {
+	ticks := []string{"0"}
+	for tick := first; tick <= 20; tick++ {
+		ticks = append(ticks, strconv.Itoa(tick))
+	}
+
+	return ticks
+}
+
+func TestReader_StartTick(t *testing.T) {
+	srv := newTestServer(t, cstv.ServerConfig{KeyframeInterval: 5 * time.Second, Tps: 1})
+	defer srv.Close()
+
+	// fragment 3 starts with the CDemoFullPacket at tick 10, fragment 4 at tick 15 doesn't have a full fragment
+	r, err := cstv.NewReaderWithConfig(srv.URL, cstv.ReaderConfig{Retry: noBackoff, StartTick: 17})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, r.Stats().Fragment)
+
+	assert.Equal(t, ticksFrom(10), parseTicks(t, r))
+
+	r, err = cstv.NewReaderWithConfig(srv.URL, cstv.ReaderConfig{Retry: noBackoff, StartTick: 7})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, r.Stats().Fragment)
+}
+
+func TestReader_StartTick_Requests(t *testing.T) {
+	srv, err := cstv.NewServerWithConfig(bytes.NewReader(testFullPacketsDemo(t, 200)), cstv.ServerConfig{KeyframeInterval: time.Second, Tps: 1})
+	assert.NoError(t, err)
+
+	var fullRequests atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/full") {
+			fullRequests.Add(1)
+		}
+
+		srv.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	r, err := cstv.NewReaderWithConfig(ts.URL, cstv.ReaderConfig{Retry: noBackoff, StartTick: 150})
+	assert.NoError(t, err)
+
+	ticks := parseTicks(t, r)
+	if assert.Len(t, ticks, 52) {
+		assert.Equal(t, "150", ticks[1])
+	}
+
+	assert.LessOrEqual(t, fullRequests.Load(), int32(10)) // bisecting ~200 fragments
+}
+
+func TestReader_StartFragment(t *testing.T) {
+	srv := newTestServer(t, cstv.ServerConfig{KeyframeInterval: 5 * time.Second, Tps: 1})
+	defer srv.Close()
+
+	r, err := cstv.NewReaderWithConfig(srv.URL, cstv.ReaderConfig{Retry: noBackoff, StartFragment: 3})
+	assert.NoError(t, err)
+
+	assert.Equal(t, ticksFrom(10), parseTicks(t, r))
+
+	_, err = cstv.NewReaderWithConfig(srv.URL, cstv.ReaderConfig{Retry: noBackoff, StartFragment: 4})
+
+	var statusErr *cstv.StatusError
+
+	assert.ErrorAs(t, err, &statusErr) // no full fragment
+}
+
+func TestReader_CatchUp(t *testing.T) {
+	// 20 ticks per second and fragments of 5 ticks
+	srv := newTestServer(t, cstv.ServerConfig{KeyframeInterval: 250 * time.Millisecond, Tps: 20, RealTime: true})
+	defer srv.Close()
+
+	time.Sleep(600 * time.Millisecond)
+
+	config := demoinfocs.DefaultParserConfig
+	config.CSTVReaderConfig = cstv.ReaderConfig{
+		Retry:         cstv.ExponentialBackoff{Initial: 50 * time.Millisecond, Factor: 1, MaxRetries: 20},
+		StartFragment: cstv.StartSignup,
+	}
+
+	p, err := demoinfocs.NewCSTVBroadcastParserWithConfig(srv.URL, config)
+	assert.NoError(t, err)
+
+	var (
+		ticks []string
+		live  []events.BroadcastLive
+	)
+
+	p.RegisterEventHandler(func(events.ConVarsUpdated) {
+		ticks = append(ticks, p.GameState().Rules().ConVars()["tick"])
+	})
+
+	p.RegisterEventHandler(func(e events.BroadcastLive) {
+		live = append(live, e)
+	})
+
+	assert.NoError(t, p.ParseToEnd())
+
+	assert.Equal(t, ticksFrom(1), ticks)
+
+	if assert.Len(t, live, 1) {
+		// about 12 ticks were broadcast before the reader started
+		assert.Greater(t, live[0].IngameTick, 5)
+		assert.Less(t, live[0].IngameTick, 20)
+	}
+}
@@ -0,0 +1,412 @@
+package cstv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/internal/bitread"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/demowriter"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+const syncFileName = "sync.json"
+
+// Recorder is a Reader that stores all fragments of the broadcast in a directory while they're read,
+// so they can be converted into a regular '.dem' file via Finalize() or FinalizeRecording().
+//
+// It can be used like a Reader, e.g. with demoinfocs.NewParserWithConfig() and demoinfocs.DemoFormatCSTVBroadcast.
+type Recorder struct {
+	*Reader
+	dir string
+}
+
+// NewRecorder creates a new CSTV reader that stores all fragments in dir, see NewReader().
+// The directory is created if it doesn't exist, it should be empty or contain fragments of the same broadcast.
+func NewRecorder(baseUrl string, timeout time.Duration, dir string) (*Recorder, error) {
+	return NewRecorderWithConfig(baseUrl, dir, ReaderConfig{Retry: TimeoutRetryPolicy(timeout)})
+}
+
+// NewRecorderWithConfig is like NewRecorder() but with a custom configuration, see NewReaderWithConfig().
+func NewRecorderWithConfig(baseUrl, dir string, config ReaderConfig) (*Recorder, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	rec := &Recorder{dir: dir}
+
+	rec.Reader, err = newReader(baseUrl, config, rec.storeFragment)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(rec.sync)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sync: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, syncFileName), b, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store sync: %w", err)
+	}
+
+	return rec, nil
+}
+
+func fragmentFileName(frag int, kind fragmentKind) string {
+	return fmt.Sprintf("%d_%s.bin", frag, kind)
+}
+
+// storeFragment writes a fragment to a temporary file first, so the directory never contains partial fragments.
+func (r *Recorder) storeFragment(frag int, kind fragmentKind, data []byte) error {
+	path := filepath.Join(r.dir, fragmentFileName(frag, kind))
+
+	err := os.WriteFile(path+".tmp", data, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to store fragment %d/%s: %w", frag, kind, err)
+	}
+
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return fmt.Errorf("failed to store fragment %d/%s: %w", frag, kind, err)
+	}
+
+	return nil
+}
+
+// Dir returns the directory the fragments are stored in.
+func (r *Recorder) Dir() string {
+	return r.dir
+}
+
+// Finalize writes the fragments recorded so far as a '.dem' file to w, see FinalizeRecording().
+// It must not be called concurrently with Read().
+func (r *Recorder) Finalize(w io.Writer) error {
+	return FinalizeRecording(w, r.dir)
+}
+
+/*
+FinalizeRecording converts the fragments of a Recorder directory into a regular PBDEMS2 demo and writes it to w.
+This also works for recordings that were interrupted (e.g. by a crash), the demo then ends at the last stored fragment.
+
+The start fragment is written as signon data, followed by the full fragment and all delta fragments in order.
+If the broadcast doesn't start with a CDemoFileHeader, one is created from the map name of the broadcast
+and the network protocol of its svc_ServerInfo (as PatchVersion, which the parser uses as the network protocol).
+Other values such as the build number, the server name and the server start tick aren't part of the broadcast
+and are missing from the created header.
+The CDemoFileInfo contains the playback values of the recorded ticks, if w implements io.WriteSeeker
+its offset is also stored in the file header, see demowriter.Writer.Close().
+*/
+func FinalizeRecording(w io.Writer, dir string) error {
+	b, err := os.ReadFile(filepath.Join(dir, syncFileName))
+	if err != nil {
+		return fmt.Errorf("failed to read sync: %w", err)
+	}
+
+	var s sync
+
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return fmt.Errorf("failed to decode sync: %w", err)
+	}
+
+	deltas, err := recordedDeltas(dir, s.Fragment)
+	if err != nil {
+		return err
+	}
+
+	start, err := os.ReadFile(filepath.Join(dir, fragmentFileName(s.SignupFragment, fragmentStart)))
+	if err != nil {
+		return fmt.Errorf("failed to read start fragment: %w", err)
+	}
+
+	full, err := os.ReadFile(filepath.Join(dir, fragmentFileName(s.Fragment, fragmentFull)))
+	if err != nil {
+		return fmt.Errorf("failed to read full fragment: %w", err)
+	}
+
+	config := demowriter.DefaultConfig
+
+	if s.Tps > 0 {
+		config.TickRate = float64(s.Tps)
+	}
+
+	c := &fragmentConverter{
+		w:      w,
+		config: config,
+		header: &msg.CDemoFileHeader{
+			DemoFileStamp: proto.String("PBDEMS_2"),
+			MapName:       proto.String(s.Map),
+			ClientName:    proto.String("CSTV Broadcast"),
+			GameDirectory: proto.String("csgo"),
+		},
+	}
+
+	if protocol := signonProtocol(start); protocol > 0 {
+		c.header.PatchVersion = proto.Int32(protocol)
+	}
+
+	err = c.convert(start)
+	if err != nil {
+		return fmt.Errorf("failed to convert start fragment: %w", err)
+	}
+
+	err = c.endSignon()
+	if err != nil {
+		return err
+	}
+
+	err = c.convert(full)
+	if err != nil {
+		return fmt.Errorf("failed to convert full fragment: %w", err)
+	}
+
+	for _, frag := range deltas {
+		if c.stopped {
+			break
+		}
+
+		delta, err := os.ReadFile(filepath.Join(dir, fragmentFileName(frag, fragmentDelta)))
+		if err != nil {
+			return fmt.Errorf("failed to read delta fragment %d: %w", frag, err)
+		}
+
+		err = c.convert(delta)
+		if err != nil {
+			return fmt.Errorf("failed to convert delta fragment %d: %w", frag, err)
+		}
+	}
+
+	return c.dw.Close(nil)
+}
+
+// recordedDeltas returns the consecutive delta fragments starting at first.
+func recordedDeltas(dir string, first int) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording directory: %w", err)
+	}
+
+	var frags []int
+
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), "_"+string(fragmentDelta)+".bin")
+		if !ok {
+			continue
+		}
+
+		frag, err := strconv.Atoi(name)
+		if err == nil && frag >= first {
+			frags = append(frags, frag)
+		}
+	}
+
+	slices.Sort(frags)
+
+	// a missing fragment would break the entity deltas
+	for i, frag := range frags {
+		if frag != first+i {
+			return frags[:i], nil
+		}
+	}
+
+	return frags, nil
+}
+
+// fragmentConverter converts broadcast frames into '.dem' frames, see parser.parseFrame().
+type fragmentConverter struct {
+	w        io.Writer
+	config   demowriter.Config
+	header   *msg.CDemoFileHeader // Used if the broadcast doesn't start with a CDemoFileHeader
+	dw       *demowriter.Writer
+	syncTick bool // Whether the broadcast contained a DEM_SyncTick
+	stopped  bool
+}
+
+func (c *fragmentConverter) ensureWriter() error {
+	if c.dw != nil {
+		return nil
+	}
+
+	var err error
+
+	c.dw, err = demowriter.NewWriterWithConfig(c.w, c.header, c.config)
+
+	return err
+}
+
+// endSignon writes a DEM_SyncTick after the start fragment if the broadcast didn't contain one.
+func (c *fragmentConverter) endSignon() error {
+	err := c.ensureWriter()
+	if err != nil || c.syncTick {
+		return err
+	}
+
+	return c.dw.WriteSyncTick()
+}
+
+// convert writes all frames of a fragment.
+func (c *fragmentConverter) convert(fragment []byte) error {
+	var err error
+
+	c.stopped, err = readFragment(fragment, c.writeFrame)
+
+	return err
+}
+
+// readFragment calls f with the decompressed payload of each frame of a fragment until its end or a DEM_Stop,
+// it returns true in the latter case.
+// Broadcast frames consist of a varint command, a 32 bit tick, a padding byte, a 32 bit size and the payload.
+func readFragment(fragment []byte, f func(cmd msg.EDemoCommands, tick int32, payload []byte) error) (bool, error) {
+	r := bytes.NewReader(fragment)
+
+	for r.Len() > 0 {
+		rawCmd, err := binary.ReadUvarint(r)
+		if err != nil {
+			return false, err
+		}
+
+		cmd := msg.EDemoCommands(rawCmd) //nolint:gosec
+
+		var header [5]byte
+
+		_, err = io.ReadFull(r, header[:])
+		if err != nil {
+			return false, err
+		}
+
+		tick := int32(binary.LittleEndian.Uint32(header[:4])) //nolint:gosec
+
+		if cmd == msg.EDemoCommands_DEM_Stop {
+			return true, nil
+		}
+
+		var size uint32
+
+		err = binary.Read(r, binary.LittleEndian, &size)
+		if err != nil {
+			return false, err
+		}
+
+		payload := make([]byte, size)
+
+		_, err = io.ReadFull(r, payload)
+		if err != nil {
+			return false, err
+		}
+
+		if cmd&msg.EDemoCommands_DEM_IsCompressed != 0 {
+			cmd &= ^msg.EDemoCommands_DEM_IsCompressed
+
+			payload, err = snappy.Decode(nil, payload)
+			if err != nil {
+				return false, fmt.Errorf("failed to decompress frame: %w", err)
+			}
+		}
+
+		err = f(cmd, tick, payload)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// signonProtocol returns the network protocol of the svc_ServerInfo in the start fragment, or 0 if there is none.
+// Errors are ignored, they're returned when the fragment is converted.
+func signonProtocol(start []byte) int32 {
+	var protocol int32
+
+	_, _ = readFragment(start, func(cmd msg.EDemoCommands, _ int32, payload []byte) error {
+		if protocol == 0 && (cmd == msg.EDemoCommands_DEM_SignonPacket || cmd == msg.EDemoCommands_DEM_Packet) {
+			protocol = serverInfoProtocol(payload)
+		}
+
+		return nil
+	})
+
+	return protocol
+}
+
+// serverInfoProtocol returns the network protocol of the svc_ServerInfo in the net-messages of a packet, or 0 if there is none.
+func serverInfoProtocol(data []byte) (protocol int32) {
+	defer func() {
+		// a corrupt packet just doesn't contain the protocol
+		if recover() != nil {
+			protocol = 0
+		}
+	}()
+
+	r := bitread.NewSmallBitReader(bytes.NewReader(data))
+
+	for len(data)*8-r.ActualPosition() > 7 {
+		t := r.ReadUBitInt()
+		b := r.ReadBytes(int(r.ReadVarInt32()))
+
+		if t == uint(msg.SVC_Messages_svc_ServerInfo) {
+			info := new(msg.CSVCMsg_ServerInfo)
+
+			if proto.Unmarshal(b, info) != nil {
+				return 0
+			}
+
+			return info.GetProtocol()
+		}
+	}
+
+	return 0
+}
+
+func (c *fragmentConverter) writeFrame(cmd msg.EDemoCommands, tick int32, payload []byte) error {
+	if cmd == msg.EDemoCommands_DEM_FileHeader && c.dw == nil {
+		header := new(msg.CDemoFileHeader)
+
+		err := proto.Unmarshal(payload, header)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal CDemoFileHeader: %w", err)
+		}
+
+		c.header = header
+
+		return c.ensureWriter()
+	}
+
+	err := c.ensureWriter()
+	if err != nil {
+		return err
+	}
+
+	switch cmd {
+	case msg.EDemoCommands_DEM_FileHeader:
+		return nil // a header was already written
+
+	case msg.EDemoCommands_DEM_SyncTick:
+		c.syncTick = true
+
+	case msg.EDemoCommands_DEM_Packet, msg.EDemoCommands_DEM_SignonPacket:
+		// broadcast packets aren't protobuf encoded
+		return c.dw.WriteFrame(cmd, tick, &msg.CDemoPacket{Data: payload})
+
+	case msg.EDemoCommands_DEM_SpawnGroups:
+		if len(payload) == 0 {
+			return nil
+		}
+
+		return c.dw.WriteFrame(cmd, tick, &msg.CDemoSpawnGroups{Msgs: [][]byte{payload[1:]}})
+	}
+
+	return c.dw.WriteRawFrame(cmd, tick, payload)
+}
@@ -0,0 +1,180 @@
+package cstv_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	demoinfocs "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/cstv"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/demowriter"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+// broadcastFrame encodes a frame in the broadcast format, packets are stored without CDemoPacket.
+func broadcastFrame(t *testing.T, cmd msg.EDemoCommands, tick int, payload []byte) []byte {
+	t.Helper()
+
+	b := protowire.AppendVarint(nil, uint64(cmd))
+	b = binary.LittleEndian.AppendUint32(b, uint32(tick))
+	b = append(b, 0)
+
+	if cmd == msg.EDemoCommands_DEM_Stop {
+		return b
+	}
+
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(payload)))
+
+	return append(b, payload...)
+}
+
+func broadcastPacket(t *testing.T, cmd msg.EDemoCommands, tick int) []byte {
+	t.Helper()
+
+	data, err := demowriter.EncodePacketData(demowriter.NetMessage{
+		Type: int32(msg.NET_Messages_net_SetConVar),
+		Msg: &msg.CNETMsg_SetConVar{Convars: &msg.CMsg_CVars{Cvars: []*msg.CMsg_CVars_CVar{{
+			Name:  proto.String("tick"),
+			Value: proto.String(strconv.Itoa(tick)),
+		}}}},
+	})
+	assert.NoError(t, err)
+
+	return broadcastFrame(t, cmd, tick, data)
+}
+
+// broadcastSignon encodes the start fragment, an svc_ServerInfo and the ConVar of broadcastPacket().
+func broadcastSignon(t *testing.T) []byte {
+	t.Helper()
+
+	data, err := demowriter.EncodePacketData(demowriter.NetMessage{
+		Type: int32(msg.SVC_Messages_svc_ServerInfo),
+		Msg:  &msg.CSVCMsg_ServerInfo{Protocol: proto.Int32(14113)},
+	}, demowriter.NetMessage{
+		Type: int32(msg.NET_Messages_net_SetConVar),
+		Msg: &msg.CNETMsg_SetConVar{Convars: &msg.CMsg_CVars{Cvars: []*msg.CMsg_CVars_CVar{{
+			Name:  proto.String("tick"),
+			Value: proto.String("0"),
+		}}}},
+	})
+	assert.NoError(t, err)
+
+	return broadcastFrame(t, msg.EDemoCommands_DEM_SignonPacket, 0, data)
+}
+
+func newTestBroadcast(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	fullPacket, err := proto.Marshal(&msg.CDemoFullPacket{StringTable: &msg.CDemoStringTables{}, Packet: &msg.CDemoPacket{}})
+	assert.NoError(t, err)
+
+	fragments := map[string][]byte{
+		"/sync":    []byte(`{"tick": 10, "fragment": 5, "signup_fragment": 1, "tps": 64, "map": "de_test", "protocol": 5}`),
+		"/1/start": broadcastSignon(t),
+		"/5/full":  broadcastFrame(t, msg.EDemoCommands_DEM_FullPacket, 10, fullPacket),
+		"/5/delta": broadcastPacket(t, msg.EDemoCommands_DEM_Packet, 11),
+		"/6/delta": append(broadcastPacket(t, msg.EDemoCommands_DEM_Packet, 12), broadcastPacket(t, msg.EDemoCommands_DEM_Packet, 13)...),
+		"/7/delta": append(broadcastPacket(t, msg.EDemoCommands_DEM_Packet, 14), broadcastFrame(t, msg.EDemoCommands_DEM_Stop, 14, nil)...),
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := fragments[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_, _ = w.Write(b)
+	}))
+}
+
+func TestRecorder(t *testing.T) {
+	srv := newTestBroadcast(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+
+	rec, err := cstv.NewRecorder(srv.URL, time.Millisecond, dir)
+	assert.NoError(t, err)
+
+	// the reader returns a wrapped io.EOF at the end of the broadcast
+	_, err = io.Copy(io.Discard, rec)
+	assert.ErrorIs(t, err, io.EOF)
+
+	for _, name := range []string{"sync.json", "1_start.bin", "5_full.bin", "5_delta.bin", "6_delta.bin", "7_delta.bin"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+
+	demoPath := filepath.Join(dir, "broadcast.dem")
+
+	f, err := os.Create(demoPath)
+	assert.NoError(t, err)
+
+	err = rec.Finalize(f)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	f, err = os.Open(demoPath)
+	assert.NoError(t, err)
+
+	defer f.Close()
+
+	info, err := demoinfocs.ReadDemoInfo(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "de_test", info.MapName)
+	assert.Equal(t, 14113, info.NetworkProtocol) // from the svc_ServerInfo of the start fragment
+	assert.Equal(t, 14, info.PlaybackTicks)
+	assert.NotNil(t, info.FileInfo)
+
+	_, err = f.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+
+	p := demoinfocs.NewParser(f)
+
+	var ticks []string
+
+	p.RegisterEventHandler(func(events.ConVarsUpdated) {
+		ticks = append(ticks, p.GameState().Rules().ConVars()["tick"])
+	})
+
+	assert.NoError(t, p.ParseToEnd())
+	assert.Equal(t, []string{"0", "11", "12", "13", "14"}, ticks)
+}
+
+func TestFinalizeRecording_MissingFragment(t *testing.T) {
+	srv := newTestBroadcast(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+
+	rec, err := cstv.NewRecorder(srv.URL, time.Millisecond, dir)
+	assert.NoError(t, err)
+
+	// the reader returns a wrapped io.EOF at the end of the broadcast
+	_, err = io.Copy(io.Discard, rec)
+	assert.ErrorIs(t, err, io.EOF)
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "6_delta.bin")))
+
+	var buf bytes.Buffer
+
+	err = cstv.FinalizeRecording(&buf, dir)
+	assert.NoError(t, err)
+
+	idx, err := demoinfocs.BuildDemoIndex(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, 11, idx.LastTick) // the demo ends before the missing fragment
+}
@@ -0,0 +1,460 @@
+package cstv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+const (
+	serverFirstFragment  = 1
+	serverSignupFragment = serverFirstFragment // like the game, the start fragment has the number of the first fragment
+	defaultServerTps     = 64
+	serverProtocol       = 5 // broadcast protocol version of CS2
+)
+
+// ServerConfig contains the configuration for a Server.
+type ServerConfig struct {
+	// KeyframeInterval is the maximum length of a fragment, like tv_broadcast_keyframe_interval.
+	KeyframeInterval time.Duration
+
+	// Tps is the tick rate reported in /sync and used for real-time pacing.
+	// 0 uses the tick rate from the CDemoFileInfo of the demo, or 64 if it has none.
+	Tps int
+
+	// Delay is how far the fragment in /sync lags behind the newest published fragment, like tv_delay.
+	// Only used with RealTime.
+	Delay time.Duration
+
+	// RealTime publishes the fragments at game speed, starting when the Server is created.
+	// Otherwise all fragments are available immediately and /sync points to the first fragment.
+	RealTime bool
+}
+
+// DefaultServerConfig is the default Server configuration used by NewServer().
+var DefaultServerConfig = ServerConfig{
+	KeyframeInterval: 3 * time.Second,
+}
+
+/*
+Server is an http.Handler that serves a '.dem' file as CSTV broadcast, e.g. for testing with NewReader() or as relay.
+
+It serves /sync, /{n}/start, /{n}/full and /{n}/delta like the game does. The signon data of the demo is the start fragment,
+the ingame frames are split into fragments at each CDemoFullPacket and after ServerConfig.KeyframeInterval.
+Since a '.dem' only contains a CDemoFullPacket every now and then, /{n}/full is only available for fragments that start with one
+(and for the first fragment, for which it's empty as the signon data already contains the full state).
+/sync only points to fragments with a full fragment.
+
+Use http.StripPrefix() to serve the broadcast under a token path.
+*/
+type Server struct {
+	config    ServerConfig
+	mux       *http.ServeMux
+	start     []byte
+	fragments []serverFragment
+	mapName   string
+	started   time.Time
+}
+
+type serverFragment struct {
+	startTick int
+	endTick   int
+	full      []byte // nil if the fragment doesn't start with a CDemoFullPacket
+	delta     []byte
+}
+
+// NewServer reads the whole demo into memory and returns a Server for it.
+// It uses DefaultServerConfig.
+//
+// See also: NewServerWithConfig()
+func NewServer(demo io.Reader) (*Server, error) {
+	return NewServerWithConfig(demo, DefaultServerConfig)
+}
+
+// NewServerWithConfig is like NewServer() but with a custom configuration.
+func NewServerWithConfig(demo io.Reader, config ServerConfig) (*Server, error) {
+	r := bufio.NewReader(demo)
+
+	var stamp [16]byte // filestamp + file-info offset + spawn-groups offset
+
+	_, err := io.ReadFull(r, stamp[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read demo header: %w", err)
+	}
+
+	if string(stamp[:8]) != "PBDEMS2\x00" {
+		return nil, fmt.Errorf("invalid demo file stamp %q, only CS2 demos can be served", stamp[:8])
+	}
+
+	s := &Server{
+		config:  config,
+		mux:     http.NewServeMux(),
+		started: time.Now(),
+	}
+
+	frames, info, err := s.readDemo(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("demo doesn't contain any ingame frames")
+	}
+
+	if s.config.Tps <= 0 {
+		s.config.Tps = defaultServerTps
+
+		if info.GetPlaybackTime() > 0 {
+			s.config.Tps = int(float32(info.GetPlaybackTicks())/info.GetPlaybackTime() + 0.5)
+		}
+	}
+
+	s.splitFragments(frames)
+
+	s.mux.HandleFunc("GET /sync", s.handleSync)
+	s.mux.HandleFunc("GET /{frag}/start", s.handleStart)
+	s.mux.HandleFunc("GET /{frag}/full", s.handleFull)
+	s.mux.HandleFunc("GET /{frag}/delta", s.handleDelta)
+
+	return s, nil
+}
+
+type serverFrame struct {
+	cmd     msg.EDemoCommands
+	tick    int
+	payload []byte // in the broadcast format
+}
+
+// readDemo reads the signon data into the start fragment and returns the ingame frames.
+func (s *Server) readDemo(r *bufio.Reader) ([]serverFrame, *msg.CDemoFileInfo, error) {
+	var (
+		frames []serverFrame
+		info   = new(msg.CDemoFileInfo)
+		signon = true
+	)
+
+	for {
+		cmd, tick, payload, err := readDemoFrame(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// truncated demo
+			return frames, info, nil
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch cmd {
+		case msg.EDemoCommands_DEM_FileHeader:
+			header := new(msg.CDemoFileHeader)
+
+			err = proto.Unmarshal(payload, header)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to unmarshal CDemoFileHeader: %w", err)
+			}
+
+			s.mapName = header.GetMapName()
+
+			continue // the game doesn't broadcast the header, see sync.Map
+
+		case msg.EDemoCommands_DEM_FileInfo:
+			err = proto.Unmarshal(payload, info)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to unmarshal CDemoFileInfo: %w", err)
+			}
+
+			return frames, info, nil
+
+		case msg.EDemoCommands_DEM_Stop:
+			frames = append(frames, serverFrame{cmd: cmd, tick: tick})
+
+			continue // the CDemoFileInfo follows
+		}
+
+		payloads, err := broadcastPayloads(cmd, payload)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, p := range payloads {
+			if signon {
+				s.start = appendBroadcastFrame(s.start, cmd, 0, p)
+			} else {
+				frames = append(frames, serverFrame{cmd: cmd, tick: tick, payload: p})
+			}
+		}
+
+		if cmd == msg.EDemoCommands_DEM_SyncTick {
+			signon = false
+		}
+	}
+}
+
+// readDemoFrame reads a frame of a '.dem' file and decompresses its payload.
+func readDemoFrame(r *bufio.Reader) (cmd msg.EDemoCommands, tick int, payload []byte, err error) {
+	c, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	cmd = msg.EDemoCommands(c) //nolint:gosec
+
+	t, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to read frame tick: %w", io.ErrUnexpectedEOF)
+	}
+
+	tick = max(int(int32(t)), 0) //nolint:gosec // -1 for signon frames
+
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to read frame size: %w", io.ErrUnexpectedEOF)
+	}
+
+	payload = make([]byte, size)
+
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to read frame payload: %w", io.ErrUnexpectedEOF)
+	}
+
+	if cmd&msg.EDemoCommands_DEM_IsCompressed != 0 {
+		cmd &= ^msg.EDemoCommands_DEM_IsCompressed
+
+		payload, err = snappy.Decode(nil, payload)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("failed to decompress frame: %w", err)
+		}
+	}
+
+	return cmd, tick, payload, nil
+}
+
+// broadcastPayloads converts the payload of a '.dem' frame into the payloads of one or more broadcast frames.
+// Packets aren't protobuf encoded in broadcasts and each spawn group is sent in its own frame.
+func broadcastPayloads(cmd msg.EDemoCommands, payload []byte) ([][]byte, error) {
+	switch cmd {
+	case msg.EDemoCommands_DEM_Packet, msg.EDemoCommands_DEM_SignonPacket:
+		packet := new(msg.CDemoPacket)
+
+		err := proto.Unmarshal(payload, packet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %v: %w", cmd, err)
+		}
+
+		return [][]byte{packet.GetData()}, nil
+
+	case msg.EDemoCommands_DEM_SpawnGroups:
+		groups := new(msg.CDemoSpawnGroups)
+
+		err := proto.Unmarshal(payload, groups)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %v: %w", cmd, err)
+		}
+
+		res := make([][]byte, 0, len(groups.GetMsgs()))
+
+		for i, m := range groups.GetMsgs() {
+			res = append(res, append(protowire.AppendVarint(nil, uint64(i)), m...)) //nolint:gosec
+		}
+
+		return res, nil
+	}
+
+	return [][]byte{payload}, nil
+}
+
+// appendBroadcastFrame encodes a frame in the broadcast format, see fragmentConverter.convert().
+func appendBroadcastFrame(b []byte, cmd msg.EDemoCommands, tick int, payload []byte) []byte {
+	b = protowire.AppendVarint(b, uint64(cmd))
+	b = binary.LittleEndian.AppendUint32(b, uint32(tick)) //nolint:gosec
+	b = append(b, 0)
+
+	if cmd == msg.EDemoCommands_DEM_Stop {
+		return b
+	}
+
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(payload))) //nolint:gosec
+
+	return append(b, payload...)
+}
+
+// splitFragments splits the ingame frames into fragments.
+// A CDemoFullPacket ends the delta of the previous fragment and is the full of the next one,
+// so the frames are received exactly once with both start + full + deltas and consecutive deltas.
+func (s *Server) splitFragments(frames []serverFrame) {
+	intervalTicks := max(int(s.config.KeyframeInterval.Seconds()*float64(s.config.Tps)), 1)
+
+	var cur *serverFragment
+
+	next := func(tick int) {
+		if cur != nil {
+			s.fragments = append(s.fragments, *cur)
+		}
+
+		cur = &serverFragment{startTick: tick, endTick: tick}
+	}
+
+	for _, f := range frames {
+		if cur == nil {
+			next(f.tick)
+		}
+
+		b := appendBroadcastFrame(nil, f.cmd, f.tick, f.payload)
+
+		if f.cmd == msg.EDemoCommands_DEM_FullPacket {
+			if cur.full != nil || len(cur.delta) > 0 {
+				cur.delta = append(cur.delta, b...)
+				cur.endTick = f.tick
+
+				next(f.tick)
+			}
+
+			cur.full = b
+
+			continue
+		}
+
+		if len(cur.delta) > 0 && f.tick >= cur.startTick+intervalTicks {
+			next(f.tick)
+		}
+
+		cur.delta = append(cur.delta, b...)
+		cur.endTick = f.tick
+	}
+
+	if last := frames[len(frames)-1]; last.cmd != msg.EDemoCommands_DEM_Stop {
+		// truncated demo
+		cur.delta = appendBroadcastFrame(cur.delta, msg.EDemoCommands_DEM_Stop, cur.endTick, nil)
+	}
+
+	s.fragments = append(s.fragments, *cur)
+}
+
+// liveTick returns the newest tick that was broadcast so far.
+func (s *Server) liveTick() int {
+	last := s.fragments[len(s.fragments)-1].endTick
+
+	if !s.config.RealTime {
+		return last
+	}
+
+	elapsed := int(time.Since(s.started).Seconds() * float64(s.config.Tps))
+
+	return min(s.fragments[0].startTick+elapsed, last)
+}
+
+// fragment returns the index of the requested fragment if it was started before the live tick.
+func (s *Server) fragment(r *http.Request) (int, bool) {
+	n, err := strconv.Atoi(r.PathValue("frag"))
+
+	i := n - serverFirstFragment
+	if err != nil || i < 0 || i >= len(s.fragments) || s.fragments[i].startTick > s.liveTick() {
+		return 0, false
+	}
+
+	return i, true
+}
+
+func (s *Server) syncFragment(liveTick int) int {
+	if !s.config.RealTime {
+		return 0
+	}
+
+	maxTick := liveTick - int(s.config.Delay.Seconds()*float64(s.config.Tps))
+
+	res := 0
+
+	for i, f := range s.fragments {
+		if f.startTick > maxTick {
+			break
+		}
+
+		if f.full != nil {
+			res = i
+		}
+	}
+
+	return res
+}
+
+func (s *Server) handleSync(w http.ResponseWriter, _ *http.Request) {
+	liveTick := s.liveTick()
+	i := s.syncFragment(liveTick)
+	f := s.fragments[i]
+
+	b, err := json.Marshal(sync{
+		Tick:             f.startTick,
+		EndTick:          f.endTick,
+		MaxTick:          liveTick,
+		RtDelay:          float64(liveTick-f.startTick) / float64(s.config.Tps),
+		Fragment:         i + serverFirstFragment,
+		SignupFragment:   serverSignupFragment,
+		Tps:              s.config.Tps,
+		KeyframeInterval: int(s.config.KeyframeInterval.Seconds()),
+		Map:              s.mapName,
+		Protocol:         serverProtocol,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("frag") != strconv.Itoa(serverSignupFragment) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	writeFragment(w, s.start)
+}
+
+func (s *Server) handleFull(w http.ResponseWriter, r *http.Request) {
+	i, ok := s.fragment(r)
+	if !ok || (s.fragments[i].full == nil && i > 0) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	writeFragment(w, s.fragments[i].full)
+}
+
+func (s *Server) handleDelta(w http.ResponseWriter, r *http.Request) {
+	i, ok := s.fragment(r)
+	if !ok || s.fragments[i].endTick > s.liveTick() {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	writeFragment(w, s.fragments[i].delta)
+}
+
+func writeFragment(w http.ResponseWriter, b []byte) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(b)
+}
+
+// ServeHTTP serves the broadcast.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
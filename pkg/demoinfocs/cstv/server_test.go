@@ -0,0 +1,170 @@
+package cstv_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	demoinfocs "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/cstv"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/demowriter"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+func tickConVar(tick int) demowriter.NetMessage {
+	return demowriter.NetMessage{
+		Type: int32(msg.NET_Messages_net_SetConVar),
+		Msg: &msg.CNETMsg_SetConVar{Convars: &msg.CMsg_CVars{Cvars: []*msg.CMsg_CVars_CVar{{
+			Name:  proto.String("tick"),
+			Value: proto.String(strconv.Itoa(tick)),
+		}}}},
+	}
+}
+
+// testServerDemo returns a demo with a packet for each tick from 1 to 20 and a CDemoFullPacket at tick 10.
+func testServerDemo(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w, err := demowriter.NewWriter(&buf, &msg.CDemoFileHeader{
+		DemoFileStamp: proto.String("PBDEMS_2"),
+		MapName:       proto.String("de_test"),
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.WritePacket(demowriter.SignonTick, tickConVar(0)))
+	assert.NoError(t, w.WriteSyncTick())
+
+	for tick := 1; tick <= 20; tick++ {
+		if tick == 10 {
+			assert.NoError(t, w.WriteFullPacket(int32(tick), &msg.CDemoStringTables{}, &msg.CDemoPacket{}))
+		}
+
+		assert.NoError(t, w.WritePacket(int32(tick), tickConVar(tick)))
+	}
+
+	assert.NoError(t, w.Close(nil))
+
+	return buf.Bytes()
+}
+
+// testFullPacketsDemo returns a demo with a CDemoFullPacket and a packet for each tick from 1 to ticks.
+func testFullPacketsDemo(t *testing.T, ticks int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w, err := demowriter.NewWriter(&buf, &msg.CDemoFileHeader{
+		DemoFileStamp: proto.String("PBDEMS_2"),
+		MapName:       proto.String("de_test"),
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.WritePacket(demowriter.SignonTick, tickConVar(0)))
+	assert.NoError(t, w.WriteSyncTick())
+
+	for tick := 1; tick <= ticks; tick++ {
+		assert.NoError(t, w.WriteFullPacket(int32(tick), &msg.CDemoStringTables{}, &msg.CDemoPacket{}))
+		assert.NoError(t, w.WritePacket(int32(tick), tickConVar(tick)))
+	}
+
+	assert.NoError(t, w.Close(nil))
+
+	return buf.Bytes()
+}
+
+func newTestServer(t *testing.T, config cstv.ServerConfig) *httptest.Server {
+	t.Helper()
+
+	srv, err := cstv.NewServerWithConfig(bytes.NewReader(testServerDemo(t)), config)
+	assert.NoError(t, err)
+
+	return httptest.NewServer(srv)
+}
+
+func getStatus(t *testing.T, url string) int {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	_, err = io.Copy(io.Discard, resp.Body)
+	assert.NoError(t, err)
+
+	return resp.StatusCode
+}
+
+func TestServer(t *testing.T) {
+	// fragments of 5 ticks
+	srv := newTestServer(t, cstv.ServerConfig{KeyframeInterval: 5 * time.Second, Tps: 1})
+	defer srv.Close()
+
+	config := demoinfocs.DefaultParserConfig
+	config.CSTVTimeout = time.Millisecond
+
+	p, err := demoinfocs.NewCSTVBroadcastParserWithConfig(srv.URL, config)
+	assert.NoError(t, err)
+
+	var ticks []string
+
+	p.RegisterEventHandler(func(events.ConVarsUpdated) {
+		ticks = append(ticks, p.GameState().Rules().ConVars()["tick"])
+	})
+
+	assert.NoError(t, p.ParseToEnd())
+
+	expected := []string{"0"}
+	for tick := 1; tick <= 20; tick++ {
+		expected = append(expected, strconv.Itoa(tick))
+	}
+
+	assert.Equal(t, expected, ticks)
+
+	// fragment 3 starts with the CDemoFullPacket at tick 10, fragment 2 doesn't have a full fragment
+	assert.Equal(t, http.StatusOK, getStatus(t, srv.URL+"/1/start"))
+	assert.Equal(t, http.StatusOK, getStatus(t, srv.URL+"/1/full"))
+	assert.Equal(t, http.StatusNotFound, getStatus(t, srv.URL+"/2/full"))
+	assert.Equal(t, http.StatusOK, getStatus(t, srv.URL+"/3/full"))
+	assert.Equal(t, http.StatusOK, getStatus(t, srv.URL+"/5/delta"))
+	assert.Equal(t, http.StatusNotFound, getStatus(t, srv.URL+"/6/delta"))
+}
+
+func TestServer_RealTime(t *testing.T) {
+	srv := newTestServer(t, cstv.ServerConfig{KeyframeInterval: 5 * time.Second, Tps: 1, RealTime: true})
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/sync")
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	var sync map[string]any
+
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&sync))
+	assert.Equal(t, float64(1), sync["fragment"])
+	assert.Equal(t, float64(1), sync["tick"])
+	assert.Equal(t, float64(1), sync["tps"])
+	assert.Equal(t, "de_test", sync["map"])
+
+	// only the first tick was broadcast so far
+	assert.Equal(t, http.StatusOK, getStatus(t, srv.URL+"/1/full"))
+	assert.Equal(t, http.StatusNotFound, getStatus(t, srv.URL+"/1/delta"))
+	assert.Equal(t, http.StatusNotFound, getStatus(t, srv.URL+"/3/full"))
+}
+
+func TestNewServer_InvalidFileType(t *testing.T) {
+	_, err := cstv.NewServer(bytes.NewReader([]byte("HL2DEMO\x00 some data")))
+	assert.Error(t, err)
+}
@@ -843,7 +843,7 @@ func (p *parser) bindWeaponS2(entity st.Entity) {
 	itemIndexVal := entity.PropertyValueMust("m_iItemDefinitionIndex")
 
 	if itemIndexVal.Any == nil {
-		p.eventDispatcher.Dispatch(events.ParserWarn{
+		p.warn(events.ParserWarn{
 			Type:    events.WarnTypeMissingItemDefinitionIndex,
 			Message: "missing m_iItemDefinitionIndex property in weapon entity",
 		})
@@ -857,10 +857,14 @@ func (p *parser) bindWeaponS2(entity st.Entity) {
 	if wepType == common.EqUnknown {
 		fmt.Fprintln(os.Stderr, "unknown equipment with index", itemIndex)
 
-		p.msgDispatcher.Dispatch(events.ParserWarn{
+		warn := events.ParserWarn{
 			Message: fmt.Sprintf("unknown equipment with index %d", itemIndex),
 			Type:    events.WarnTypeUnknownEquipmentIndex,
-		})
+		}
+
+		if p.recordWarn(warn) {
+			p.msgDispatcher.Dispatch(warn)
+		}
 	} else {
 		model := entity.PropertyValueMust("CBodyComponent.m_hModel").UInt64()
 		p.equipmentTypePerModel[model] = wepType
@@ -0,0 +1,806 @@
+package demoinfocs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	bit "github.com/markus-wa/demoinfocs-golang/v5/internal/bitread"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+// Demo index errors
+var (
+	// ErrInvalidDemoIndex signals that data couldn't be read as demo index,
+	// e.g. because it was written by an incompatible version (see DemoIndexVersion).
+	ErrInvalidDemoIndex = errors.New("invalid or unsupported demo index (ErrInvalidDemoIndex)")
+
+	// ErrDemoIndexMismatch signals that a demo index was created for a different demo.
+	ErrDemoIndexMismatch = errors.New("demo index doesn't match the demo (ErrDemoIndexMismatch)")
+)
+
+// DemoIndexVersion is the version of the demo index format written by DemoIndex.WriteTo().
+// ReadDemoIndex() only accepts indices of the same version.
+const DemoIndexVersion = 2
+
+// DemoIndexFileExtension is appended to the path of a demo to get the path of its index sidecar file.
+// See LoadOrBuildDemoIndex().
+const DemoIndexFileExtension = ".idx"
+
+const demoIndexMagic = "PBDEMIDX"
+
+// demoIndexChecksumSize is the number of bytes at the start of a demo that are covered by DemoIndex.Checksum.
+const demoIndexChecksumSize = 1 << 16
+
+// IndexEntryType is the type of IndexEntry.
+type IndexEntryType byte
+
+// IndexEntryTypes.
+const (
+	IndexEntryFullPacket IndexEntryType = iota + 1 // CDemoFullPacket (keyframe)
+	IndexEntryRoundStart                           // 'round_start' game event
+	IndexEntryRoundEnd                             // 'round_end' game event
+)
+
+// IndexEntry is the position of a keyframe or game event in a demo.
+type IndexEntry struct {
+	Type    IndexEntryType
+	Offset  int64             // Offset of the frame in the demo file in bytes
+	Frame   int               // Frame / demo-tick, see Parser.CurrentFrame()
+	Tick    int               // Ingame tick
+	Command msg.EDemoCommands // Demo command of the frame, without the compression flag
+}
+
+// DemoIndexHeader contains the CDemoFileHeader values that identify the indexed demo.
+type DemoIndexHeader struct {
+	MapName         string
+	ServerName      string
+	ClientName      string
+	PatchVersion    int32
+	BuildNum        int32
+	DemoVersionGUID string
+	ServerStartTick int32
+}
+
+func newDemoIndexHeader(h *msg.CDemoFileHeader) DemoIndexHeader {
+	return DemoIndexHeader{
+		MapName:         h.GetMapName(),
+		ServerName:      h.GetServerName(),
+		ClientName:      h.GetClientName(),
+		PatchVersion:    h.GetPatchVersion(),
+		BuildNum:        h.GetBuildNum(),
+		DemoVersionGUID: h.GetDemoVersionGuid(),
+		ServerStartTick: h.GetServerStartTick(),
+	}
+}
+
+/*
+DemoIndex contains the positions of all CDemoFullPackets, round starts and round ends of a demo.
+
+It can be passed to a Parser via ParserConfig.DemoIndex so SeekToTick() and SeekToFrame()
+don't need to scan the demo for keyframes first.
+
+Example:
+
+	idx, err := demoinfocs.LoadOrBuildDemoIndex("/path/to/demo.dem")
+	if err != nil {
+		log.Panic("failed to index demo: ", err)
+	}
+
+	cfg := demoinfocs.DefaultParserConfig
+	cfg.DemoIndex = idx
+
+	p := demoinfocs.NewParserWithConfig(f, cfg)
+
+	err = p.SeekToTick(idx.RoundStarts()[16].Tick)
+*/
+type DemoIndex struct {
+	Header    DemoIndexHeader
+	Size      int64      // Size of the demo in bytes
+	Checksum  uint64     // FNV-1a hash of the first 64 KiB of the demo
+	SignonEnd IndexEntry // First frame after DEM_SyncTick, everything before is signon data. Type and Command are not set.
+	LastFrame int
+	LastTick  int
+	Entries   []IndexEntry // Ordered by offset
+}
+
+func (idx *DemoIndex) entriesOfType(t IndexEntryType) []IndexEntry {
+	var res []IndexEntry
+
+	for _, e := range idx.Entries {
+		if e.Type == t {
+			res = append(res, e)
+		}
+	}
+
+	return res
+}
+
+// FullPackets returns all CDemoFullPacket entries (keyframes).
+func (idx *DemoIndex) FullPackets() []IndexEntry {
+	return idx.entriesOfType(IndexEntryFullPacket)
+}
+
+// RoundStarts returns all 'round_start' entries.
+func (idx *DemoIndex) RoundStarts() []IndexEntry {
+	return idx.entriesOfType(IndexEntryRoundStart)
+}
+
+// RoundEnds returns all 'round_end' entries.
+func (idx *DemoIndex) RoundEnds() []IndexEntry {
+	return idx.entriesOfType(IndexEntryRoundEnd)
+}
+
+// matches checks whether the index was built for the demo with the given header, size and checksum (see readDemoIdentity()).
+// The header alone isn't enough, e.g. demos of the same server and map may only differ in their content.
+func (idx *DemoIndex) matches(h *msg.CDemoFileHeader, size int64, checksum uint64) bool {
+	return h != nil && idx.Header == newDemoIndexHeader(h) && idx.Size == size && idx.Checksum == checksum
+}
+
+func (idx *DemoIndex) keyframeIndex() *keyframeIndex {
+	kfIdx := &keyframeIndex{
+		signon: keyframe{
+			offset: idx.SignonEnd.Offset,
+			frame:  idx.SignonEnd.Frame,
+			tick:   idx.SignonEnd.Tick,
+		},
+		lastFrame: idx.LastFrame,
+		lastTick:  idx.LastTick,
+	}
+
+	for _, e := range idx.FullPackets() {
+		kfIdx.keyframes = append(kfIdx.keyframes, keyframe{
+			offset: e.Offset,
+			frame:  e.Frame,
+			tick:   e.Tick,
+			full:   true,
+		})
+	}
+
+	return kfIdx
+}
+
+/*
+BuildDemoIndex does a single pass over a '.dem' file and indexes all CDemoFullPackets, round starts and round ends.
+Only game events are decoded, entities and string tables are skipped.
+Truncated demos are indexed up to the last complete frame.
+
+Round starts and ends are taken from the 'round_start' and 'round_end' game events,
+they are not mimicked from entity updates like events.RoundStart and events.RoundEnd are.
+
+The stream doesn't need to implement io.Seeker, but if it does frame payloads are skipped without reading them.
+Otherwise the stream is read to the end to get the size of the demo (see DemoIndex.Size).
+*/
+func BuildDemoIndex(demostream io.Reader) (*DemoIndex, error) {
+	return buildDemoIndex(demostream, true)
+}
+
+// demoIndexer contains the state of a BuildDemoIndex() pass.
+type demoIndexer struct {
+	idx        *DemoIndex
+	fr         *frameReader
+	rounds     bool             // Whether packets are decoded to find round starts and ends
+	eventNames map[int32]string // Game event IDs to names, from CMsgSource1LegacyGameEventList
+}
+
+func buildDemoIndex(r io.Reader, rounds bool) (*DemoIndex, error) {
+	prefix, err := readDemoPrefix(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read demo")
+	}
+
+	if seeker, ok := r.(io.Seeker); ok {
+		_, err = seeker.Seek(-int64(len(prefix)), io.SeekCurrent)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to seek demo stream")
+		}
+	} else {
+		r = io.MultiReader(bytes.NewReader(prefix), r)
+	}
+
+	fr, err := newDemoFrameReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ix := demoIndexer{
+		idx:    &DemoIndex{Checksum: demoChecksum(prefix)},
+		fr:     fr,
+		rounds: rounds,
+	}
+
+	err = ix.run()
+	if err != nil {
+		return nil, err
+	}
+
+	ix.idx.Size, err = ix.streamSize()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get size of demo")
+	}
+
+	return ix.idx, nil
+}
+
+// streamSize returns the size of the demo, the rest of the stream is read if it doesn't implement io.Seeker.
+func (ix *demoIndexer) streamSize() (int64, error) {
+	if seeker, ok := ix.fr.r.(io.Seeker); ok {
+		return seeker.Seek(0, io.SeekEnd)
+	}
+
+	n, err := io.Copy(io.Discard, ix.fr.br)
+
+	return ix.fr.pos + n, err
+}
+
+// readDemoPrefix reads the bytes that are covered by DemoIndex.Checksum, which may be less for small demos.
+func readDemoPrefix(r io.Reader) ([]byte, error) {
+	b := make([]byte, demoIndexChecksumSize)
+
+	n, err := io.ReadFull(r, b)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+
+	return b[:n], err
+}
+
+func demoChecksum(prefix []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(prefix)
+
+	return h.Sum64()
+}
+
+// checkDemoIndex makes sure the index belongs to the demo in rs, see also parser.checkDemoIndex().
+// The stream is moved, callers need to reposition it.
+func checkDemoIndex(rs io.ReadSeeker, idx *DemoIndex) error {
+	header, err := readDemoFileHeader(rs)
+	if err != nil {
+		return errors.Wrap(err, "failed to read demo header")
+	}
+
+	size, checksum, err := readDemoIdentity(rs)
+	if err != nil {
+		return err
+	}
+
+	if !idx.matches(header, size, checksum) {
+		return ErrDemoIndexMismatch
+	}
+
+	return nil
+}
+
+// readDemoIdentity returns the values of DemoIndex.Size and DemoIndex.Checksum for a demo.
+// The stream is moved, callers need to reposition it.
+func readDemoIdentity(rs io.ReadSeeker) (size int64, checksum uint64, err error) {
+	_, err = rs.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to seek demo stream")
+	}
+
+	prefix, err := readDemoPrefix(rs)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to read demo")
+	}
+
+	size, err = rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to seek demo stream")
+	}
+
+	return size, demoChecksum(prefix), nil
+}
+
+func (ix *demoIndexer) run() error {
+	frame := 0
+
+	for {
+		h, err := ix.fr.next()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "failed to read frame header")
+		}
+
+		ix.idx.LastTick = h.tick
+		ix.idx.LastFrame = frame
+
+		if h.cmd == msg.EDemoCommands_DEM_Stop {
+			break
+		}
+
+		err = ix.handleFrame(h, IndexEntry{
+			Offset:  h.offset,
+			Frame:   frame,
+			Tick:    h.tick,
+			Command: h.cmd,
+		})
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// parseFrame() doesn't dispatch frameParsedToken for unknown commands
+		if demoCommandMsgsCreators[h.cmd] != nil {
+			frame++
+		}
+
+		if h.cmd == msg.EDemoCommands_DEM_SyncTick {
+			ix.idx.SignonEnd = IndexEntry{
+				Offset: ix.fr.pos,
+				Frame:  frame,
+				Tick:   h.tick,
+			}
+		}
+	}
+
+	if ix.idx.SignonEnd.Offset == 0 {
+		return errors.New("no DEM_SyncTick found, the demo seems to be missing its signon data")
+	}
+
+	return nil
+}
+
+func (ix *demoIndexer) handleFrame(h frameHeader, entry IndexEntry) error {
+	switch {
+	case h.cmd == msg.EDemoCommands_DEM_FileHeader:
+		header := new(msg.CDemoFileHeader)
+
+		err := ix.unmarshalPayload(h, header)
+		if err != nil {
+			return err
+		}
+
+		ix.idx.Header = newDemoIndexHeader(header)
+
+	case h.cmd == msg.EDemoCommands_DEM_FullPacket && !ix.rounds:
+		err := ix.fr.skip(h)
+		if err != nil {
+			return err
+		}
+
+		ix.addEntry(IndexEntryFullPacket, entry)
+
+	case h.cmd == msg.EDemoCommands_DEM_FullPacket:
+		fullPacket := new(msg.CDemoFullPacket)
+
+		err := ix.unmarshalPayload(h, fullPacket)
+		if err != nil {
+			return err
+		}
+
+		ix.addEntry(IndexEntryFullPacket, entry)
+
+		return ix.handlePacket(fullPacket.GetPacket().GetData(), entry)
+
+	case ix.rounds && (h.cmd == msg.EDemoCommands_DEM_Packet || h.cmd == msg.EDemoCommands_DEM_SignonPacket):
+		packet := new(msg.CDemoPacket)
+
+		err := ix.unmarshalPayload(h, packet)
+		if err != nil {
+			return err
+		}
+
+		return ix.handlePacket(packet.GetData(), entry)
+
+	default:
+		return ix.fr.skip(h)
+	}
+
+	return nil
+}
+
+func (ix *demoIndexer) unmarshalPayload(h frameHeader, m proto.Message) error {
+	b, err := ix.fr.payload(h)
+	if err != nil {
+		return err
+	}
+
+	err = proto.Unmarshal(b, m)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unmarshal %v at offset %d", h.cmd, h.offset)
+	}
+
+	return nil
+}
+
+func (ix *demoIndexer) addEntry(t IndexEntryType, entry IndexEntry) {
+	entry.Type = t
+	ix.idx.Entries = append(ix.idx.Entries, entry)
+}
+
+// handlePacket looks for game events in the net-messages of a CDemoPacket, see handleDemoPacket().
+func (ix *demoIndexer) handlePacket(b []byte, entry IndexEntry) (err error) {
+	if len(b) == 0 {
+		return nil
+	}
+
+	r := bit.NewSmallBitReader(bytes.NewReader(b))
+
+	defer func() {
+		if err == nil {
+			err = errors.Wrapf(recoverFromUnexpectedEOF(recover()), "failed to read packet at offset %d", entry.Offset)
+		}
+	}()
+
+	for len(b)*8-r.ActualPosition() > 7 {
+		t := int32(r.ReadUBitInt())
+		size := int(r.ReadVarInt32())
+
+		switch msg.EBaseGameEvents(t) {
+		case msg.EBaseGameEvents_GE_Source1LegacyGameEventList:
+			list := new(msg.CMsgSource1LegacyGameEventList)
+
+			err = proto.Unmarshal(r.ReadBytes(size), list)
+			if err != nil {
+				return errors.Wrap(err, "failed to unmarshal game event list")
+			}
+
+			ix.handleGameEventList(list)
+
+		case msg.EBaseGameEvents_GE_Source1LegacyGameEvent:
+			ge := new(msg.CMsgSource1LegacyGameEvent)
+
+			err = proto.Unmarshal(r.ReadBytes(size), ge)
+			if err != nil {
+				return errors.Wrap(err, "failed to unmarshal game event")
+			}
+
+			err = ix.handleGameEvent(ge, entry)
+			if err != nil {
+				return err
+			}
+
+		default:
+			r.Skip(size << 3)
+		}
+	}
+
+	return nil
+}
+
+func (ix *demoIndexer) handleGameEventList(list *msg.CMsgSource1LegacyGameEventList) {
+	ix.eventNames = make(map[int32]string)
+
+	for _, d := range list.GetDescriptors() {
+		ix.eventNames[d.GetEventid()] = d.GetName()
+	}
+}
+
+func (ix *demoIndexer) handleGameEvent(ge *msg.CMsgSource1LegacyGameEvent, entry IndexEntry) error {
+	if ix.eventNames == nil {
+		// same fallback as handleGameEvent() for demos without game event list
+		bin, err := getGameEventListBinForProtocol(int(ix.idx.Header.PatchVersion))
+		if err != nil {
+			return errors.Wrap(err, "failed to load fallback game event list")
+		}
+
+		list := new(msg.CMsgSource1LegacyGameEventList)
+
+		err = proto.Unmarshal(bin, list)
+		if err != nil {
+			return errors.Wrap(err, "failed to unmarshal fallback game event list")
+		}
+
+		ix.handleGameEventList(list)
+	}
+
+	switch ix.eventNames[ge.GetEventid()] {
+	case "round_start":
+		ix.addEntry(IndexEntryRoundStart, entry)
+
+	case "round_end":
+		ix.addEntry(IndexEntryRoundEnd, entry)
+	}
+
+	return nil
+}
+
+// readDemoFileHeader reads the CDemoFileHeader from the first frame of a '.dem' file.
+func readDemoFileHeader(r io.Reader) (*msg.CDemoFileHeader, error) {
+	fr, err := newDemoFrameReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header := new(msg.CDemoFileHeader)
+
+	err = fr.readMessage(msg.EDemoCommands_DEM_FileHeader, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// WriteTo writes the index in a compact binary format that can be read via ReadDemoIndex().
+// It implements io.WriterTo.
+func (idx *DemoIndex) WriteTo(w io.Writer) (int64, error) {
+	b := []byte(demoIndexMagic)
+	b = binary.AppendUvarint(b, DemoIndexVersion)
+
+	b = appendDemoIndexHeader(b, idx.Header)
+	b = binary.AppendVarint(b, idx.Size)
+	b = binary.AppendUvarint(b, idx.Checksum)
+
+	b = appendIndexEntry(b, idx.SignonEnd, IndexEntry{})
+	b = binary.AppendVarint(b, int64(idx.LastFrame))
+	b = binary.AppendVarint(b, int64(idx.LastTick))
+
+	// entries are delta encoded, offsets, frames and ticks are (mostly) increasing
+	b = binary.AppendUvarint(b, uint64(len(idx.Entries)))
+
+	var prev IndexEntry
+
+	for _, e := range idx.Entries {
+		b = appendIndexEntry(b, e, prev)
+		prev = e
+	}
+
+	n, err := w.Write(b)
+
+	return int64(n), err
+}
+
+func appendDemoIndexHeader(b []byte, h DemoIndexHeader) []byte {
+	b = appendIndexString(b, h.MapName)
+	b = appendIndexString(b, h.ServerName)
+	b = appendIndexString(b, h.ClientName)
+	b = binary.AppendVarint(b, int64(h.PatchVersion))
+	b = binary.AppendVarint(b, int64(h.BuildNum))
+	b = appendIndexString(b, h.DemoVersionGUID)
+
+	return binary.AppendVarint(b, int64(h.ServerStartTick))
+}
+
+func appendIndexString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+
+	return append(b, s...)
+}
+
+func appendIndexEntry(b []byte, e, prev IndexEntry) []byte {
+	b = append(b, byte(e.Type))
+	b = binary.AppendUvarint(b, uint64(e.Command))
+	b = binary.AppendVarint(b, e.Offset-prev.Offset)
+	b = binary.AppendVarint(b, int64(e.Frame-prev.Frame))
+
+	return binary.AppendVarint(b, int64(e.Tick-prev.Tick))
+}
+
+// binaryReader decodes the formats written by DemoIndex.WriteTo() and Parser.Checkpoint().
+// The first error is kept and all further reads return zero values.
+type binaryReader struct {
+	r       *bufio.Reader
+	err     error
+	invalid error // Returned for lengths that exceed their limit
+}
+
+const maxDemoIndexStringLength = 1 << 12
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadUvarint(r.r)
+	r.err = unexpectedEOF(err)
+
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadVarint(r.r)
+	r.err = unexpectedEOF(err)
+
+	return v
+}
+
+func (r *binaryReader) string() string {
+	return string(r.bytes(maxDemoIndexStringLength))
+}
+
+func (r *binaryReader) bytes(limit uint64) []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+
+	if n > limit {
+		r.err = errors.Wrapf(r.invalid, "length %d exceeds limit", n)
+
+		return nil
+	}
+
+	b := make([]byte, n)
+
+	_, err := io.ReadFull(r.r, b)
+	r.err = unexpectedEOF(err)
+
+	return b
+}
+
+func (r *binaryReader) demoIndexHeader() DemoIndexHeader {
+	return DemoIndexHeader{
+		MapName:         r.string(),
+		ServerName:      r.string(),
+		ClientName:      r.string(),
+		PatchVersion:    int32(r.varint()), //nolint:gosec
+		BuildNum:        int32(r.varint()), //nolint:gosec
+		DemoVersionGUID: r.string(),
+		ServerStartTick: int32(r.varint()), //nolint:gosec
+	}
+}
+
+func (r *binaryReader) entry(prev IndexEntry) IndexEntry {
+	if r.err != nil {
+		return IndexEntry{}
+	}
+
+	t, err := r.r.ReadByte()
+	if err != nil {
+		r.err = unexpectedEOF(err)
+
+		return IndexEntry{}
+	}
+
+	return IndexEntry{
+		Type:    IndexEntryType(t),
+		Command: msg.EDemoCommands(r.uvarint()), //nolint:gosec
+		Offset:  prev.Offset + r.varint(),
+		Frame:   prev.Frame + int(r.varint()),
+		Tick:    prev.Tick + int(r.varint()),
+	}
+}
+
+// ReadDemoIndex reads an index that was written via DemoIndex.WriteTo().
+// Returns ErrInvalidDemoIndex if the data isn't a demo index or has a different version than DemoIndexVersion.
+func ReadDemoIndex(r io.Reader) (*DemoIndex, error) {
+	ir := &binaryReader{r: bufio.NewReader(r), invalid: ErrInvalidDemoIndex}
+
+	magic := make([]byte, len(demoIndexMagic))
+
+	_, err := io.ReadFull(ir.r, magic)
+	if err != nil || string(magic) != demoIndexMagic {
+		return nil, ErrInvalidDemoIndex
+	}
+
+	version := ir.uvarint()
+	if ir.err == nil && version != DemoIndexVersion {
+		return nil, errors.Wrapf(ErrInvalidDemoIndex, "unsupported demo index version %d", version)
+	}
+
+	idx := new(DemoIndex)
+
+	idx.Header = ir.demoIndexHeader()
+	idx.Size = ir.varint()
+	idx.Checksum = ir.uvarint()
+
+	idx.SignonEnd = ir.entry(IndexEntry{})
+	idx.LastFrame = int(ir.varint())
+	idx.LastTick = int(ir.varint())
+
+	n := ir.uvarint()
+	if ir.err == nil {
+		// don't trust n for the allocation, the data may be corrupt
+		idx.Entries = make([]IndexEntry, 0, min(n, 1<<16))
+	}
+
+	var prev IndexEntry
+
+	for i := uint64(0); i < n && ir.err == nil; i++ {
+		prev = ir.entry(prev)
+		idx.Entries = append(idx.Entries, prev)
+	}
+
+	if ir.err != nil {
+		return nil, errors.Wrap(ir.err, "failed to read demo index")
+	}
+
+	return idx, nil
+}
+
+/*
+LoadOrBuildDemoIndex returns the index of a '.dem' file from its sidecar file (demoPath + DemoIndexFileExtension).
+If the sidecar doesn't exist, can't be read or doesn't match the demo (CDemoFileHeader, size and checksum of the first bytes),
+the demo is indexed via BuildDemoIndex() and the sidecar is (re-)written.
+
+See also: DemoIndex
+*/
+func LoadOrBuildDemoIndex(demoPath string) (*DemoIndex, error) {
+	f, err := os.Open(demoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	header, err := readDemoFileHeader(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read demo header")
+	}
+
+	idxPath := demoPath + DemoIndexFileExtension
+
+	idx, err := readDemoIndexFile(idxPath)
+	if err == nil {
+		size, checksum, err := readDemoIdentity(f)
+		if err != nil {
+			return nil, err
+		}
+
+		if idx.matches(header, size, checksum) {
+			return idx, nil
+		}
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to seek demo stream")
+	}
+
+	idx, err = BuildDemoIndex(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build demo index")
+	}
+
+	err = writeDemoIndexFile(idxPath, idx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to write demo index")
+	}
+
+	return idx, nil
+}
+
+func readDemoIndexFile(path string) (*DemoIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return ReadDemoIndex(f)
+}
+
+// writeDemoIndexFile writes the index to a temporary file first
+// so concurrent readers never see a partially written index.
+func writeDemoIndexFile(path string, idx *DemoIndex) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = idx.WriteTo(f)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,198 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/demowriter"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+func testGameEventList() demowriter.NetMessage {
+	return demowriter.NetMessage{
+		Type: int32(msg.EBaseGameEvents_GE_Source1LegacyGameEventList),
+		Msg: &msg.CMsgSource1LegacyGameEventList{
+			Descriptors: []*msg.CMsgSource1LegacyGameEventListDescriptorT{
+				{Eventid: proto.Int32(1), Name: proto.String("round_start")},
+				{Eventid: proto.Int32(2), Name: proto.String("round_end")},
+				{Eventid: proto.Int32(3), Name: proto.String("player_death")},
+			},
+		},
+	}
+}
+
+func testGameEvent(id int32) demowriter.NetMessage {
+	return demowriter.NetMessage{
+		Type: int32(msg.EBaseGameEvents_GE_Source1LegacyGameEvent),
+		Msg:  &msg.CMsgSource1LegacyGameEvent{Eventid: proto.Int32(id)},
+	}
+}
+
+func testRoundsDemoData() []byte {
+	tick := demowriter.NetMessage{Type: int32(msg.NET_Messages_net_Tick), Msg: &msg.CNETMsg_Tick{}}
+
+	return newTestDemoBuilder().
+		signon(testGameEventList()).
+		packet(10, tick, testGameEvent(1)).
+		fullPacket(20, testGameEvent(3)).
+		packet(30, testGameEvent(3), testGameEvent(2)).
+		packet(40, testGameEvent(1)).
+		bytes()
+}
+
+func TestBuildDemoIndex(t *testing.T) {
+	idx, err := BuildDemoIndex(bytes.NewBuffer(testRoundsDemoData()))
+	require.NoError(t, err)
+
+	assert.Equal(t, DemoIndexHeader{
+		MapName:         "de_test",
+		PatchVersion:    14113,
+		ServerStartTick: 123,
+	}, idx.Header)
+	assert.Equal(t, int64(len(testRoundsDemoData())), idx.Size)
+	assert.Equal(t, demoChecksum(testRoundsDemoData()), idx.Checksum)
+	assert.Equal(t, 3, idx.SignonEnd.Frame)
+	assert.Equal(t, 7, idx.LastFrame)
+	assert.Equal(t, 40, idx.LastTick)
+
+	fullPackets := idx.FullPackets()
+	require.Len(t, fullPackets, 1)
+	assert.Equal(t, 4, fullPackets[0].Frame)
+	assert.Equal(t, 20, fullPackets[0].Tick)
+	assert.Equal(t, msg.EDemoCommands_DEM_FullPacket, fullPackets[0].Command)
+
+	roundStarts := idx.RoundStarts()
+	require.Len(t, roundStarts, 2)
+	assert.Equal(t, idx.SignonEnd.Offset, roundStarts[0].Offset)
+	assert.Equal(t, 10, roundStarts[0].Tick)
+	assert.Equal(t, 40, roundStarts[1].Tick)
+	assert.Equal(t, msg.EDemoCommands_DEM_Packet, roundStarts[1].Command)
+
+	roundEnds := idx.RoundEnds()
+	require.Len(t, roundEnds, 1)
+	assert.Equal(t, 5, roundEnds[0].Frame)
+	assert.Equal(t, 30, roundEnds[0].Tick)
+}
+
+func TestBuildDemoIndex_Seeker(t *testing.T) {
+	idx, err := BuildDemoIndex(bytes.NewBuffer(testRoundsDemoData()))
+	require.NoError(t, err)
+
+	seekerIdx, err := BuildDemoIndex(bytes.NewReader(testRoundsDemoData()))
+	require.NoError(t, err)
+
+	assert.Equal(t, idx, seekerIdx)
+}
+
+func TestBuildDemoIndex_InvalidFileType(t *testing.T) {
+	_, err := BuildDemoIndex(bytes.NewBufferString("HL2DEMO\x00\x00\x00\x00\x00\x00\x00\x00\x00"))
+	assert.ErrorIs(t, err, ErrInvalidFileType)
+}
+
+func TestDemoIndex_WriteTo_ReadDemoIndex(t *testing.T) {
+	idx, err := BuildDemoIndex(bytes.NewBuffer(testRoundsDemoData()))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	n, err := idx.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	read, err := ReadDemoIndex(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, idx, read)
+}
+
+func TestReadDemoIndex_Invalid(t *testing.T) {
+	var buf bytes.Buffer
+
+	_, err := new(DemoIndex).WriteTo(&buf)
+	require.NoError(t, err)
+
+	b := buf.Bytes()
+
+	_, err = ReadDemoIndex(bytes.NewBufferString("PBDEMS2\x00"))
+	assert.ErrorIs(t, err, ErrInvalidDemoIndex)
+
+	wrongVersion := bytes.Clone(b)
+	wrongVersion[len(demoIndexMagic)] = DemoIndexVersion + 1
+
+	_, err = ReadDemoIndex(bytes.NewBuffer(wrongVersion))
+	assert.ErrorIs(t, err, ErrInvalidDemoIndex)
+
+	_, err = ReadDemoIndex(bytes.NewBuffer(b[:len(b)-1]))
+	assert.Error(t, err)
+}
+
+func TestLoadOrBuildDemoIndex(t *testing.T) {
+	demoPath := filepath.Join(t.TempDir(), "test.dem")
+	require.NoError(t, os.WriteFile(demoPath, testRoundsDemoData(), 0o600))
+
+	idx, err := LoadOrBuildDemoIndex(demoPath)
+	require.NoError(t, err)
+	assert.FileExists(t, demoPath+DemoIndexFileExtension)
+
+	loaded, err := LoadOrBuildDemoIndex(demoPath)
+	require.NoError(t, err)
+	assert.Equal(t, idx, loaded)
+
+	// index of another demo is replaced
+	other := &DemoIndex{Header: DemoIndexHeader{MapName: "de_other"}}
+
+	f, err := os.Create(demoPath + DemoIndexFileExtension)
+	require.NoError(t, err)
+	_, err = other.WriteTo(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	rebuilt, err := LoadOrBuildDemoIndex(demoPath)
+	require.NoError(t, err)
+	assert.Equal(t, idx, rebuilt)
+}
+
+func TestLoadOrBuildDemoIndex_SameHeader(t *testing.T) {
+	demoPath := filepath.Join(t.TempDir(), "test.dem")
+	require.NoError(t, os.WriteFile(demoPath, testRoundsDemoData(), 0o600))
+
+	_, err := LoadOrBuildDemoIndex(demoPath)
+	require.NoError(t, err)
+
+	// another demo with the same CDemoFileHeader
+	data := newTestDemoBuilder().signon(testGameEventList()).packet(10, testGameEvent(1)).bytes()
+	require.NoError(t, os.WriteFile(demoPath, data, 0o600))
+
+	rebuilt, err := LoadOrBuildDemoIndex(demoPath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), rebuilt.Size)
+	assert.Len(t, rebuilt.RoundStarts(), 1)
+}
+
+func TestParser_SeekToTick_DemoIndexMismatch(t *testing.T) {
+	cfg := DefaultParserConfig
+	cfg.DemoIndex = &DemoIndex{Header: DemoIndexHeader{MapName: "de_other"}}
+
+	p := NewParserWithConfig(bytes.NewReader(testDemoData()), cfg)
+
+	assert.ErrorIs(t, p.SeekToTick(100), ErrDemoIndexMismatch)
+}
+
+func TestParser_SeekToTick_DemoIndexMismatch_SameHeader(t *testing.T) {
+	idx, err := buildDemoIndex(bytes.NewReader(testDemoData()), false)
+	require.NoError(t, err)
+
+	idx.Checksum++
+
+	cfg := DefaultParserConfig
+	cfg.DemoIndex = idx
+
+	p := NewParserWithConfig(bytes.NewReader(testDemoData()), cfg)
+
+	assert.ErrorIs(t, p.SeekToTick(100), ErrDemoIndexMismatch)
+}
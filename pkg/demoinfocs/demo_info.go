@@ -0,0 +1,103 @@
+package demoinfocs
+
+import (
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+// DemoInfo contains the metadata of a demo, see ReadDemoInfo().
+type DemoInfo struct {
+	MapName         string // E.g. de_ancient, de_nuke, cs_office, etc.
+	ServerName      string // Server's 'hostname' config value
+	ClientName      string // Usually 'SourceTV Demo'
+	GameDirectory   string
+	NetworkProtocol int // CDemoFileHeader.patch_version
+	BuildNum        int
+	DemoVersionName string
+
+	// Playback values from CDemoFileInfo, 0 if the demo doesn't have one (e.g. incomplete demos).
+	PlaybackTime   time.Duration // Demo duration
+	PlaybackTicks  int           // Game duration in ticks
+	PlaybackFrames int           // Amount of 'frames' aka demo-ticks recorded
+
+	// RoundStartTicks contains the ingame tick of each round start from CDemoFileInfo.game_info.
+	// CS2 demos don't contain a match ID or player list in game_info, use a Parser for those.
+	RoundStartTicks []int
+
+	FileHeader *msg.CDemoFileHeader
+	FileInfo   *msg.CDemoFileInfo // nil if the demo doesn't have one
+}
+
+// Rounds returns the amount of rounds according to CDemoFileInfo.game_info.
+func (info DemoInfo) Rounds() int {
+	return len(info.RoundStartTicks)
+}
+
+/*
+ReadDemoInfo reads the metadata of a '.dem' file without parsing it.
+
+Only the CDemoFileHeader at the start of the demo and the CDemoFileInfo at the file-info offset of the PBDEMS2 header are read.
+Incomplete demos (e.g. from a crashed server) don't have a CDemoFileInfo, in that case the playback values are 0 and FileInfo is nil.
+
+Returns ErrInvalidFileType if the stream isn't a CS2 demo.
+*/
+func ReadDemoInfo(r io.ReadSeeker) (DemoInfo, error) {
+	var info DemoInfo
+
+	_, err := r.Seek(0, io.SeekStart)
+	if err != nil {
+		return info, errors.Wrap(err, "failed to seek to start of demo")
+	}
+
+	fileInfoOffset, err := readFileStamp(r)
+	if err != nil {
+		return info, err
+	}
+
+	header := new(msg.CDemoFileHeader)
+
+	err = newFrameReader(r, headerSizeS2).readMessage(msg.EDemoCommands_DEM_FileHeader, header)
+	if err != nil {
+		return info, errors.Wrap(err, "failed to read CDemoFileHeader")
+	}
+
+	info.FileHeader = header
+	info.MapName = header.GetMapName()
+	info.ServerName = header.GetServerName()
+	info.ClientName = header.GetClientName()
+	info.GameDirectory = header.GetGameDirectory()
+	info.NetworkProtocol = int(header.GetPatchVersion())
+	info.BuildNum = int(header.GetBuildNum())
+	info.DemoVersionName = header.GetDemoVersionName()
+
+	if fileInfoOffset == 0 {
+		return info, nil
+	}
+
+	_, err = r.Seek(fileInfoOffset, io.SeekStart)
+	if err != nil {
+		return info, errors.Wrap(err, "failed to seek to CDemoFileInfo")
+	}
+
+	fileInfo := new(msg.CDemoFileInfo)
+
+	err = newFrameReader(r, fileInfoOffset).readMessage(msg.EDemoCommands_DEM_FileInfo, fileInfo)
+	if err != nil {
+		return info, errors.Wrap(err, "failed to read CDemoFileInfo")
+	}
+
+	info.FileInfo = fileInfo
+	info.PlaybackTime = time.Duration(float64(fileInfo.GetPlaybackTime()) * float64(time.Second))
+	info.PlaybackTicks = int(fileInfo.GetPlaybackTicks())
+	info.PlaybackFrames = int(fileInfo.GetPlaybackFrames())
+
+	for _, tick := range fileInfo.GetGameInfo().GetCs().GetRoundStartTicks() {
+		info.RoundStartTicks = append(info.RoundStartTicks, int(tick))
+	}
+
+	return info, nil
+}
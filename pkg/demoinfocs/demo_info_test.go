@@ -0,0 +1,50 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+func TestReadDemoInfo(t *testing.T) {
+	b := newTestDemoFileBuilder().signon().packet(1).bytesWithFileInfo(&msg.CDemoFileInfo{
+		PlaybackTime:   proto.Float32(1.5),
+		PlaybackTicks:  proto.Int32(96),
+		PlaybackFrames: proto.Int32(48),
+		GameInfo: &msg.CGameInfo{
+			Cs: &msg.CGameInfo_CCSGameInfo{RoundStartTicks: []int32{10, 50}},
+		},
+	})
+
+	info, err := ReadDemoInfo(bytes.NewReader(b))
+	require.NoError(t, err)
+
+	assert.Equal(t, "de_test", info.MapName)
+	assert.Equal(t, 14113, info.NetworkProtocol)
+	assert.Equal(t, 1500*time.Millisecond, info.PlaybackTime)
+	assert.Equal(t, 96, info.PlaybackTicks)
+	assert.Equal(t, 48, info.PlaybackFrames)
+	assert.Equal(t, []int{10, 50}, info.RoundStartTicks)
+	assert.Equal(t, 2, info.Rounds())
+	assert.NotNil(t, info.FileInfo)
+}
+
+func TestReadDemoInfo_Incomplete(t *testing.T) {
+	info, err := ReadDemoInfo(bytes.NewReader(testDemoData()))
+	require.NoError(t, err)
+
+	assert.Equal(t, "de_test", info.MapName)
+	assert.Zero(t, info.PlaybackTicks)
+	assert.Nil(t, info.FileInfo)
+}
+
+func TestReadDemoInfo_InvalidFileType(t *testing.T) {
+	_, err := ReadDemoInfo(bytes.NewReader(make([]byte, 100)))
+	assert.ErrorIs(t, err, ErrInvalidFileType)
+}
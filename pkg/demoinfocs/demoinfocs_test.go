@@ -7,9 +7,11 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -25,6 +27,7 @@ import (
 	common "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/common"
 	events "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
 	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+	st "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/sendtables"
 )
 
 const (
@@ -216,6 +219,88 @@ func TestS2(t *testing.T) {
 	assertions.NoError(err, "error occurred in ParseToEnd()")
 }
 
+func TestEntityServerClasses(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("skipping test due to -short flag")
+	}
+
+	f := openFile(t, s2DemPath)
+	defer mustClose(t, f)
+
+	classes := []string{"CCSPlayerController", "CCSPlayerPawn", "CCSTeam", "CCSGameRulesProxy"}
+
+	cfg := demoinfocs.DefaultParserConfig
+	cfg.EntityServerClasses = classes
+
+	p := demoinfocs.NewParserWithConfig(f, cfg)
+
+	var kills int
+
+	p.RegisterEventHandler(func(events.Kill) {
+		kills++
+	})
+
+	p.RegisterEventHandler(func(events.DataTablesParsed) {
+		for _, sc := range p.ServerClasses().All() {
+			sc.OnEntityCreated(func(entity st.Entity) {
+				assert.Contains(t, classes, entity.ServerClass().Name())
+			})
+		}
+	})
+
+	err := p.ParseToEnd()
+	assert.NoError(t, err)
+	assert.Positive(t, kills)
+	assert.Len(t, p.GameState().Participants().Playing(), 10)
+}
+
+func TestEntityPropertyPrefixes(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("skipping test due to -short flag")
+	}
+
+	type playerInfo struct {
+		Name  string
+		IsBot bool
+		Team  common.Team
+	}
+
+	parse := func(cfg demoinfocs.ParserConfig) (players []playerInfo, kills int) {
+		f := openFile(t, s2DemPath)
+		defer mustClose(t, f)
+
+		p := demoinfocs.NewParserWithConfig(f, cfg)
+
+		p.RegisterEventHandler(func(events.Kill) {
+			kills++
+		})
+
+		err := p.ParseToEnd()
+		assert.NoError(t, err)
+
+		for _, pl := range p.GameState().Participants().Playing() {
+			players = append(players, playerInfo{Name: pl.Name, IsBot: pl.IsBot, Team: pl.Team})
+		}
+
+		return players, kills
+	}
+
+	expectedPlayers, expectedKills := parse(demoinfocs.DefaultParserConfig)
+
+	cfg := demoinfocs.DefaultParserConfig
+	// omits properties the parser reads internally but doesn't require, e.g. health, armor and money
+	cfg.EntityPropertyPrefixes = []string{"m_flFlashDuration"}
+
+	players, kills := parse(cfg)
+
+	assert.ElementsMatch(t, expectedPlayers, players)
+	assert.Equal(t, expectedKills, kills)
+}
+
 func TestS2POV(t *testing.T) {
 	t.Parallel()
 
@@ -294,6 +379,187 @@ func TestParseToEnd_MultiCancel(t *testing.T) {
 	assert.Equal(t, demoinfocs.ErrCancelled, err, "parsing cancelled but error was not ErrCancelled")
 }
 
+func TestSeekToTick(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("skipping test")
+	}
+
+	const targetTick = 50000
+
+	participantNames := func(p demoinfocs.Parser) []string {
+		var names []string
+
+		for _, pl := range p.GameState().Participants().Playing() {
+			names = append(names, pl.Name)
+		}
+
+		slices.Sort(names)
+
+		return names
+	}
+
+	f := openFile(t, s2DemPath)
+	defer mustClose(t, f)
+
+	linear := demoinfocs.NewParser(f)
+
+	for linear.GameState().IngameTick() < targetTick {
+		_, err := linear.ParseNextFrame()
+		assert.NoError(t, err)
+	}
+
+	f2 := openFile(t, s2DemPath)
+	defer mustClose(t, f2)
+
+	p := demoinfocs.NewParser(f2)
+
+	err := p.SeekToTick(targetTick)
+	assert.NoError(t, err)
+	assert.Equal(t, linear.GameState().IngameTick(), p.GameState().IngameTick())
+	assert.Equal(t, participantNames(linear), participantNames(p))
+	assert.Equal(t, linear.GameState().TotalRoundsPlayed(), p.GameState().TotalRoundsPlayed())
+
+	// seeking backwards
+	err = p.SeekToTick(targetTick / 2)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, p.GameState().IngameTick(), targetTick/2)
+
+	err = p.SeekToTick(math.MaxInt32)
+	assert.ErrorIs(t, err, demoinfocs.ErrSeekOutOfRange)
+
+	err = p.ParseToEnd()
+	assert.NoError(t, err)
+}
+
+func TestDemoIndex(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("skipping test")
+	}
+
+	f := openFile(t, s2DemPath)
+	defer mustClose(t, f)
+
+	idx, err := demoinfocs.BuildDemoIndex(f)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, idx.FullPackets())
+	assert.NotEmpty(t, idx.RoundStarts())
+
+	_, err = f.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+
+	cfg := demoinfocs.DefaultParserConfig
+	cfg.DemoIndex = idx
+
+	p := demoinfocs.NewParserWithConfig(f, cfg)
+
+	roundStart := idx.RoundStarts()[len(idx.RoundStarts())/2]
+
+	err = p.SeekToTick(roundStart.Tick)
+	assert.NoError(t, err)
+	assert.Equal(t, roundStart.Tick, p.GameState().IngameTick())
+}
+
+func TestClipTicks(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("skipping test")
+	}
+
+	const startTick = 50000
+
+	type playerState struct {
+		Position string
+		Health   int
+		Armor    int
+		Money    int
+	}
+
+	type state struct {
+		Entities int
+		Players  map[string]playerState
+	}
+
+	snapshot := func(p demoinfocs.Parser) state {
+		s := state{
+			Entities: len(p.GameState().Entities()),
+			Players:  make(map[string]playerState),
+		}
+
+		for _, pl := range p.GameState().Participants().Playing() {
+			s.Players[pl.Name] = playerState{
+				Position: pl.Position().String(),
+				Health:   pl.Health(),
+				Armor:    pl.Armor(),
+				Money:    pl.Money(),
+			}
+		}
+
+		return s
+	}
+
+	// stateAtStartTick returns the state after the last frame of startTick
+	stateAtStartTick := func(p demoinfocs.Parser) state {
+		s := snapshot(p)
+
+		for p.GameState().IngameTick() <= startTick {
+			if p.GameState().IngameTick() == startTick {
+				s = snapshot(p)
+			}
+
+			ok, err := p.ParseNextFrame()
+			assert.NoError(t, err)
+
+			if !ok {
+				break
+			}
+		}
+
+		return s
+	}
+
+	f := openFile(t, s2DemPath)
+	defer mustClose(t, f)
+
+	var clip bytes.Buffer
+
+	err := demoinfocs.ClipTicks(&clip, f, startTick, startTick+1000)
+	assert.NoError(t, err)
+
+	f2 := openFile(t, s2DemPath)
+	defer mustClose(t, f2)
+
+	reference := demoinfocs.NewParser(f2)
+
+	err = reference.SeekToTick(startTick)
+	assert.NoError(t, err)
+
+	p := demoinfocs.NewParser(bytes.NewReader(clip.Bytes()))
+
+	assert.Equal(t, stateAtStartTick(reference), stateAtStartTick(p))
+}
+
+func TestReadDemoInfo(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("skipping test")
+	}
+
+	f := openFile(t, s2DemPath)
+	defer mustClose(t, f)
+
+	info, err := demoinfocs.ReadDemoInfo(f)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, info.MapName)
+	assert.NotZero(t, info.PlaybackTicks)
+	assert.NotEmpty(t, info.RoundStartTicks)
+}
+
 func TestInvalidFileType(t *testing.T) {
 	t.Parallel()
 
@@ -439,6 +705,7 @@ func BenchmarkInMemory(b *testing.B) {
 	assert.NoError(b, err, "failed to read file %q", s2DemPath)
 	assert.Equal(b, int64(n), inf.Size(), "byte count not as expected")
 
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -449,6 +716,31 @@ func BenchmarkInMemory(b *testing.B) {
 	}
 }
 
+// BenchmarkDemoSetS2 parses all demos of the S2 demo-set from memory and reports allocations,
+// most of which are caused by decoding entities.
+func BenchmarkDemoSetS2(b *testing.B) {
+	dems, err := os.ReadDir(demSetPathS2)
+	assert.NoError(b, err, "failed to list directory %q", demSetPathS2)
+
+	var data [][]byte
+
+	for _, d := range dems {
+		if strings.HasSuffix(d.Name(), ".dem") {
+			data = append(data, lo.Must(os.ReadFile(fmt.Sprintf("%s/%s", demSetPathS2, d.Name()))))
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, d := range data {
+			err = demoinfocs.NewParser(bytes.NewReader(d)).ParseToEnd()
+			assert.NoError(b, err, "ParseToEnd() returned an error")
+		}
+	}
+}
+
 func BenchmarkConcurrent(b *testing.B) {
 	b.Logf("Running concurrency benchmark with %d demos\n", *concurrentDemos)
 
@@ -0,0 +1,55 @@
+package demowriter
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/internal/bitwrite"
+)
+
+// NetMessage is a net-message with its type (e.g. msg.SVC_Messages_svc_PacketEntities) for EncodePacketData().
+type NetMessage struct {
+	Type int32
+	Msg  proto.Message
+}
+
+// RawNetMessage is a protobuf encoded net-message with its type for EncodeRawPacketData().
+type RawNetMessage struct {
+	Type int32
+	Data []byte
+}
+
+// EncodePacketData encodes net-messages the same way as they are stored in CDemoPacket.Data,
+// which is the inverse of how the parser decodes packets.
+func EncodePacketData(msgs ...NetMessage) ([]byte, error) {
+	raw := make([]RawNetMessage, len(msgs))
+
+	for i, m := range msgs {
+		b, err := proto.Marshal(m.Msg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal net-message of type %d", m.Type)
+		}
+
+		raw[i] = RawNetMessage{Type: m.Type, Data: b}
+	}
+
+	return EncodeRawPacketData(raw...)
+}
+
+// EncodeRawPacketData is like EncodePacketData() for net-messages that are already protobuf encoded,
+// e.g. to copy net-messages from another demo without decoding them.
+func EncodeRawPacketData(msgs ...RawNetMessage) ([]byte, error) {
+	w := new(bitwrite.BitWriter)
+
+	for _, m := range msgs {
+		if m.Type < 0 {
+			return nil, errors.Errorf("invalid net-message type %d", m.Type)
+		}
+
+		w.WriteUBitInt(uint(m.Type))
+		w.WriteVarInt(uint64(len(m.Data)))
+		w.WriteBytes(m.Data)
+	}
+
+	return w.Bytes(), nil
+}
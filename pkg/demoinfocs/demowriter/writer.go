@@ -0,0 +1,277 @@
+/*
+Package demowriter writes CS2 demos ('.dem' files in the PBDEMS2 format).
+
+It's the inverse of how the demoinfocs parser reads frames:
+every frame is a varint command (with the DEM_IsCompressed flag if the payload is snappy compressed),
+a varint tick, a varint payload size and the protobuf encoded payload.
+After the DEM_Stop frame a CDemoFileInfo is written and its offset is stored in the file header.
+
+Example:
+
+	w, err := demowriter.NewWriter(f, header)
+	if err != nil {
+		log.Panic("failed to create demo writer: ", err)
+	}
+
+	err = w.WriteFrame(msg.EDemoCommands_DEM_Packet, tick, packet)
+	...
+
+	err = w.Close(nil)
+*/
+package demowriter
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+// ErrClosed signals that a frame was written after Close().
+var ErrClosed = errors.New("demo writer is closed (ErrClosed)")
+
+const (
+	fileStamp  = "PBDEMS2\x00"
+	headerSize = 16 // filestamp + file-info offset + spawn-groups offset
+
+	// SignonTick is the tick of frames before the first packet, it's written as -1 (0xFFFFFFFF).
+	SignonTick = -1
+)
+
+// Config contains the configuration for a Writer.
+type Config struct {
+	// CompressMinSize is the minimum payload size in bytes for frames to be snappy compressed.
+	// 0 disables compression.
+	CompressMinSize int
+
+	// TickRate of the server, used for CDemoFileInfo.playback_time if it isn't set when closing.
+	TickRate float64
+}
+
+// DefaultConfig is the default Writer configuration used by NewWriter().
+var DefaultConfig = Config{
+	CompressMinSize: 1024,
+	TickRate:        64,
+}
+
+// Writer writes a demo frame by frame.
+type Writer struct {
+	w        io.Writer
+	config   Config
+	offset   int64 // Bytes written so far
+	frames   int
+	lastTick int32
+	closed   bool
+	buf      []byte
+}
+
+// NewWriter writes the PBDEMS2 file header and the CDemoFileHeader to w and returns a Writer for the following frames.
+// It uses DefaultConfig.
+//
+// See also: NewWriterWithConfig() & Close()
+func NewWriter(w io.Writer, header *msg.CDemoFileHeader) (*Writer, error) {
+	return NewWriterWithConfig(w, header, DefaultConfig)
+}
+
+// NewWriterWithConfig is like NewWriter() but with a custom configuration.
+func NewWriterWithConfig(w io.Writer, header *msg.CDemoFileHeader, config Config) (*Writer, error) {
+	dw := &Writer{
+		w:        w,
+		config:   config,
+		lastTick: SignonTick,
+	}
+
+	// the file-info offset is patched in Close()
+	_, err := dw.write(append([]byte(fileStamp), make([]byte, headerSize-len(fileStamp))...))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to write file header")
+	}
+
+	err = dw.WriteFrame(msg.EDemoCommands_DEM_FileHeader, SignonTick, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return dw, nil
+}
+
+func (w *Writer) write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+
+	return n, err
+}
+
+// Offset returns the amount of bytes written so far, which is the offset of the next frame.
+func (w *Writer) Offset() int64 {
+	return w.offset
+}
+
+// Frames returns the amount of frames written so far, excluding the CDemoFileHeader and DEM_Stop.
+func (w *Writer) Frames() int {
+	return w.frames
+}
+
+// WriteFrame writes a demo command.
+// tick is the ingame tick of the frame, SignonTick for frames before the first packet.
+// The payload is snappy compressed if it's at least Config.CompressMinSize bytes long.
+func (w *Writer) WriteFrame(cmd msg.EDemoCommands, tick int32, m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal %v", cmd)
+	}
+
+	return w.WriteRawFrame(cmd, tick, b)
+}
+
+// WriteRawFrame writes a demo command whose payload is already protobuf encoded (but not compressed),
+// e.g. to copy frames from another demo without decoding them.
+func (w *Writer) WriteRawFrame(cmd msg.EDemoCommands, tick int32, payload []byte) error {
+	if w.closed {
+		return ErrClosed
+	}
+
+	cmd &= ^msg.EDemoCommands_DEM_IsCompressed
+
+	if w.config.CompressMinSize > 0 && len(payload) >= w.config.CompressMinSize {
+		payload = snappy.Encode(nil, payload)
+		cmd |= msg.EDemoCommands_DEM_IsCompressed
+	}
+
+	b := protowire.AppendVarint(w.buf[:0], uint64(cmd))
+	b = protowire.AppendVarint(b, uint64(uint32(tick)))
+	b = protowire.AppendVarint(b, uint64(len(payload)))
+	b = append(b, payload...)
+	w.buf = b
+
+	_, err := w.write(b)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %v", cmd)
+	}
+
+	if cmd&^msg.EDemoCommands_DEM_IsCompressed != msg.EDemoCommands_DEM_FileHeader {
+		w.frames++
+	}
+
+	if tick != SignonTick {
+		w.lastTick = tick
+	}
+
+	return nil
+}
+
+// WritePacket writes a DEM_Packet with the given net-messages, see EncodePacketData().
+func (w *Writer) WritePacket(tick int32, msgs ...NetMessage) error {
+	data, err := EncodePacketData(msgs...)
+	if err != nil {
+		return err
+	}
+
+	return w.WriteFrame(msg.EDemoCommands_DEM_Packet, tick, &msg.CDemoPacket{Data: data})
+}
+
+// WriteFullPacket writes a DEM_FullPacket, a keyframe with all string tables and entities.
+func (w *Writer) WriteFullPacket(tick int32, stringTables *msg.CDemoStringTables, packet *msg.CDemoPacket) error {
+	return w.WriteFrame(msg.EDemoCommands_DEM_FullPacket, tick, &msg.CDemoFullPacket{
+		StringTable: stringTables,
+		Packet:      packet,
+	})
+}
+
+// WriteStringTables writes a DEM_StringTables frame.
+func (w *Writer) WriteStringTables(tick int32, stringTables *msg.CDemoStringTables) error {
+	return w.WriteFrame(msg.EDemoCommands_DEM_StringTables, tick, stringTables)
+}
+
+// WriteSyncTick writes the DEM_SyncTick frame that marks the end of the signon data.
+func (w *Writer) WriteSyncTick() error {
+	return w.WriteFrame(msg.EDemoCommands_DEM_SyncTick, SignonTick, &msg.CDemoSyncTick{})
+}
+
+/*
+Close writes the DEM_Stop frame and the CDemoFileInfo and stores the offset of the CDemoFileInfo in the file header.
+It doesn't close the underlying writer.
+
+If info is nil or playback values aren't set, they are taken from the written frames.
+The offset can only be stored if the underlying writer implements io.WriteSeeker,
+otherwise it stays 0 - like for demos of crashed servers.
+*/
+func (w *Writer) Close(info *msg.CDemoFileInfo) error {
+	if w.closed {
+		return ErrClosed
+	}
+
+	frames := w.frames
+
+	err := w.WriteRawFrame(msg.EDemoCommands_DEM_Stop, w.lastTick, nil)
+	if err != nil {
+		return err
+	}
+
+	w.closed = true
+
+	if info == nil {
+		info = new(msg.CDemoFileInfo)
+	} else {
+		info = proto.Clone(info).(*msg.CDemoFileInfo)
+	}
+
+	ticks := max(w.lastTick, 0)
+
+	if info.PlaybackTicks == nil {
+		info.PlaybackTicks = proto.Int32(ticks)
+	}
+
+	if info.PlaybackFrames == nil {
+		info.PlaybackFrames = proto.Int32(int32(frames)) //nolint:gosec
+	}
+
+	if info.PlaybackTime == nil && w.config.TickRate > 0 {
+		info.PlaybackTime = proto.Float32(float32(float64(ticks) / w.config.TickRate))
+	}
+
+	fileInfoOffset := w.offset
+
+	b, err := proto.Marshal(info)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal CDemoFileInfo")
+	}
+
+	// written directly so it doesn't count as frame
+	frame := protowire.AppendVarint(nil, uint64(msg.EDemoCommands_DEM_FileInfo))
+	frame = protowire.AppendVarint(frame, uint64(uint32(w.lastTick)))
+	frame = protowire.AppendVarint(frame, uint64(len(b)))
+
+	_, err = w.write(append(frame, b...))
+	if err != nil {
+		return errors.Wrap(err, "failed to write CDemoFileInfo")
+	}
+
+	ws, ok := w.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+
+	return patchFileInfoOffset(ws, fileInfoOffset, w.offset)
+}
+
+func patchFileInfoOffset(ws io.WriteSeeker, fileInfoOffset, end int64) error {
+	_, err := ws.Seek(int64(len(fileStamp)), io.SeekStart)
+	if err != nil {
+		return errors.Wrap(err, "failed to seek to file-info offset")
+	}
+
+	_, err = ws.Write(binary.LittleEndian.AppendUint32(nil, uint32(fileInfoOffset))) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "failed to write file-info offset")
+	}
+
+	_, err = ws.Seek(end, io.SeekStart)
+
+	return errors.Wrap(err, "failed to seek to end of demo")
+}
@@ -0,0 +1,118 @@
+package demowriter_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	demoinfocs "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/demowriter"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+func testHeader() *msg.CDemoFileHeader {
+	return &msg.CDemoFileHeader{
+		DemoFileStamp: proto.String("PBDEMS_2"),
+		PatchVersion:  proto.Int32(14113),
+		MapName:       proto.String("de_test"),
+	}
+}
+
+func writeTestDemo(t *testing.T, f *os.File, config demowriter.Config) {
+	t.Helper()
+
+	w, err := demowriter.NewWriterWithConfig(f, testHeader(), config)
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.WriteFrame(msg.EDemoCommands_DEM_SignonPacket, demowriter.SignonTick, &msg.CDemoPacket{}))
+	assert.NoError(t, w.WriteSyncTick())
+
+	for tick := int32(1); tick <= 10; tick++ {
+		if tick == 5 {
+			assert.NoError(t, w.WriteFullPacket(tick, &msg.CDemoStringTables{}, &msg.CDemoPacket{}))
+
+			continue
+		}
+
+		assert.NoError(t, w.WritePacket(tick, demowriter.NetMessage{
+			Type: int32(msg.NET_Messages_net_Tick),
+			Msg:  &msg.CNETMsg_Tick{Tick: proto.Uint32(uint32(tick))},
+		}, demowriter.NetMessage{
+			Type: int32(msg.NET_Messages_net_SetConVar),
+			Msg: &msg.CNETMsg_SetConVar{Convars: &msg.CMsg_CVars{Cvars: []*msg.CMsg_CVars_CVar{{
+				Name:  proto.String("test"),
+				Value: proto.String(string(bytes.Repeat([]byte{'x'}, int(tick)*100))),
+			}}}},
+		}))
+	}
+
+	assert.Equal(t, 12, w.Frames())
+	assert.NoError(t, w.Close(nil))
+	assert.ErrorIs(t, w.Close(nil), demowriter.ErrClosed)
+}
+
+func TestWriter(t *testing.T) {
+	for name, config := range map[string]demowriter.Config{
+		"default":      demowriter.DefaultConfig,
+		"uncompressed": {TickRate: 64},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Create(filepath.Join(t.TempDir(), "test.dem"))
+			assert.NoError(t, err)
+
+			defer f.Close()
+
+			writeTestDemo(t, f, config)
+
+			info, err := demoinfocs.ReadDemoInfo(f)
+			assert.NoError(t, err)
+			assert.Equal(t, "de_test", info.MapName)
+			assert.Equal(t, 10, info.PlaybackTicks)
+			assert.Equal(t, 12, info.PlaybackFrames)
+
+			_, err = f.Seek(0, io.SeekStart)
+			assert.NoError(t, err)
+
+			idx, err := demoinfocs.BuildDemoIndex(f)
+			assert.NoError(t, err)
+
+			if assert.Len(t, idx.FullPackets(), 1) {
+				assert.Equal(t, 5, idx.FullPackets()[0].Tick)
+			}
+
+			_, err = f.Seek(0, io.SeekStart)
+			assert.NoError(t, err)
+
+			p := demoinfocs.NewParser(f)
+
+			var ticks []int
+
+			p.RegisterEventHandler(func(events.FrameDone) {
+				ticks = append(ticks, p.GameState().IngameTick())
+			})
+
+			err = p.ParseToEnd()
+			assert.NoError(t, err)
+			assert.Equal(t, 10, ticks[len(ticks)-1])
+			assert.Len(t, p.GameState().Rules().ConVars()["test"], 1000)
+		})
+	}
+}
+
+func TestWriter_NotSeekable(t *testing.T) {
+	var buf bytes.Buffer
+
+	w, err := demowriter.NewWriter(&buf, testHeader())
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close(nil))
+
+	info, err := demoinfocs.ReadDemoInfo(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Nil(t, info.FileInfo)
+}
@@ -598,6 +598,11 @@ const (
 	WarnTypeStringTableParsingFailure // Should happen only with CS2 POV demos
 	WarnTypePacketEntitiesPanic
 	WarnTypeUnknownProtobufMessage
+	WarnTypeCorruptFramesSkipped // frames were skipped after a corrupt frame, see ParserConfig.RecoverFromCorruptFrames
+	WarnTypeUnknownGameEvent     // occurs when a game-event isn't known, the game-event handlers probably need to be updated
+	WarnTypeUnknownSayText2MessageName
+	WarnTypeRankUpdateUnknownPlayer
+	WarnTypeCorruptCompressedMessage // the message is skipped
 )
 
 // WarnTypeUnknownDemoCommandMessageType occurs when a demo-command message type is unknown - contact a maintainer.
@@ -713,3 +718,32 @@ type TeamClanNameUpdated struct {
 	NewName   string
 	TeamState *common.TeamState
 }
+
+// BroadcastFragmentReceived signals that a fragment of a live CSTV broadcast was downloaded.
+// Dispatched before the frames of the fragment are parsed, if multiple fragments were received during a frame
+// the event is only dispatched once for the last one.
+// Only available for CSTV broadcasts, see cstv.Reader.Stats().
+type BroadcastFragmentReceived struct {
+	Fragment          int   // Number of the fragment
+	FragmentsReceived int   // Total number of received fragments, including the start and full fragment
+	BytesReceived     int64 // Total size of all received fragments
+	Retries           int   // Total number of failed requests that were retried
+}
+
+// BroadcastLagChanged signals that the distance between the current ingame tick and the newest tick of a live CSTV broadcast changed.
+// It's checked whenever a fragment or a new /sync was received, MaxTick is only updated if cstv.ReaderConfig.SyncInterval is set.
+// Only available for CSTV broadcasts, see cstv.Reader.Stats().
+type BroadcastLagChanged struct {
+	IngameTick int           // The current ingame tick
+	MaxTick    int           // The newest tick of the broadcast according to the last /sync
+	Lag        int           // MaxTick - IngameTick
+	LagTime    time.Duration // Lag converted to time using the tick rate of the broadcast
+}
+
+// BroadcastLive signals that the parser caught up with a live CSTV broadcast, i.e. the next fragment wasn't available yet.
+// Useful to tell apart the replay of a broadcast's history from live events, see cstv.ReaderConfig.StartFragment.
+// Dispatched once.
+type BroadcastLive struct {
+	IngameTick int // The current ingame tick
+	Fragment   int // The last received fragment
+}
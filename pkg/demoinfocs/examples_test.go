@@ -2,6 +2,7 @@ package demoinfocs_test
 
 import (
 	"log"
+	"os"
 	"testing"
 
 	demoinfocs "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
@@ -37,10 +38,35 @@ func ExampleParser() {
 	}
 }
 
+/*
+This will print all kills of a demo, consuming them in a for-range loop instead of registering an event handler.
+*/
+//noinspection GoUnhandledErrorResult
+func ExampleEventsOf() {
+	f, err := os.Open("../../test/cs-demos/s2/s2.dem")
+	if err != nil {
+		log.Panic("failed to open demo file: ", err)
+	}
+
+	defer f.Close()
+
+	p := demoinfocs.NewParser(f)
+	defer p.Close()
+
+	for kill, err := range demoinfocs.EventsOf[events.Kill](p) {
+		if err != nil {
+			log.Panic("failed to parse demo: ", err)
+		}
+
+		log.Printf("%s <%v> %s\n", kill.Killer, kill.Weapon, kill.Victim)
+	}
+}
+
 func TestExamplesWithoutOutput(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping long running test")
 	}
 
 	ExampleParser()
+	ExampleEventsOf()
 }
@@ -3,6 +3,8 @@
 package fake
 
 import (
+	"context"
+	"io"
 	"time"
 
 	dp "github.com/markus-wa/godispatch"
@@ -70,7 +72,10 @@ func (p *Parser) MockNetMessagesFrame(frame int, messages ...any) {
 }
 
 // NewParser returns a new parser mock with pre-initialized Events and NetMessages.
-// Pre-mocks RegisterEventHandler() and RegisterNetMessageHandler().
+// Pre-mocks RegisterEventHandler(), RegisterNetMessageHandler() and their Unregister counterparts.
+//
+// demoinfocs.On() and demoinfocs.OnNetMessage() can be used with the mock
+// to make sure handlers have the correct signature at compile time.
 func NewParser() *Parser {
 	p := &Parser{
 		Events:      make(map[int][]any),
@@ -79,6 +84,8 @@ func NewParser() *Parser {
 
 	p.On("RegisterEventHandler").Return()
 	p.On("RegisterNetMessageHandler").Return()
+	p.On("UnregisterEventHandler").Return()
+	p.On("UnregisterNetMessageHandler").Return()
 
 	return p
 }
@@ -168,6 +175,28 @@ func (p *Parser) ParseToEnd() (err error) {
 	return args.Error(0)
 }
 
+// ParseToEndContext is a mock-implementation of Parser.ParseToEndContext().
+//
+// Dispatches Parser.Events and Parser.NetMessages in the specified order until the context is done.
+//
+// Returns the mocked error value.
+func (p *Parser) ParseToEndContext(ctx context.Context) (err error) {
+	args := p.Called(ctx)
+
+	maxFrame := maxKey(p.Events)
+	maxNetMessageFrame := maxKey(p.NetMessages)
+
+	if maxFrame < maxNetMessageFrame {
+		maxFrame = maxNetMessageFrame
+	}
+
+	for p.currentFrame <= maxFrame && ctx.Err() == nil {
+		p.parseNextFrame()
+	}
+
+	return args.Error(0)
+}
+
 func (p *Parser) parseNextFrame() {
 	events, ok := p.Events[p.currentFrame]
 	if ok {
@@ -199,6 +228,21 @@ func (p *Parser) ParseNextFrame() (b bool, err error) {
 	return args.Bool(0), args.Error(1)
 }
 
+// ParseNextFrameContext is a mock-implementation of Parser.ParseNextFrameContext().
+//
+// Dispatches Parser.Events and Parser.NetMessages in the specified order, unless the context is done.
+//
+// Returns the mocked bool and error values.
+func (p *Parser) ParseNextFrameContext(ctx context.Context) (b bool, err error) {
+	args := p.Called(ctx)
+
+	if ctx.Err() == nil {
+		p.parseNextFrame()
+	}
+
+	return args.Bool(0), args.Error(1)
+}
+
 func maxKey[T any, N constraints.Ordered](numbers map[N]T) (maxNumber N) {
 	for n := range numbers {
 		if n > maxNumber {
@@ -221,3 +265,25 @@ func (p *Parser) Cancel() {
 func (p *Parser) Close() error {
 	return p.Called().Error(0)
 }
+
+// SeekToTick is a mock-implementation of Parser.SeekToTick().
+// Does not modify the mock's frame position.
+func (p *Parser) SeekToTick(tick int) error {
+	return p.Called(tick).Error(0)
+}
+
+// SeekToFrame is a mock-implementation of Parser.SeekToFrame().
+// Does not modify the mock's frame position.
+func (p *Parser) SeekToFrame(frame int) error {
+	return p.Called(frame).Error(0)
+}
+
+// Warnings is a mock-implementation of Parser.Warnings().
+func (p *Parser) Warnings() []demoinfocs.WarningSummary {
+	return p.Called().Get(0).([]demoinfocs.WarningSummary)
+}
+
+// Checkpoint is a mock-implementation of Parser.Checkpoint().
+func (p *Parser) Checkpoint(w io.Writer) error {
+	return p.Called(w).Error(0)
+}
@@ -1,6 +1,7 @@
 package fake_test
 
 import (
+	"context"
 	"testing"
 
 	assert "github.com/stretchr/testify/assert"
@@ -61,6 +62,26 @@ func TestParseToEndEvents(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestParseToEndContextEvents_Cancelled(t *testing.T) {
+	p := fake.NewParser()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.On("ParseToEndContext", ctx).Return(context.Canceled)
+	p.MockEvents(kill(common.EqAK47))
+	p.MockEvents(kill(common.EqScout))
+
+	var actual []any
+	p.RegisterEventHandler(func(e events.Kill) {
+		actual = append(actual, e)
+		cancel()
+	})
+
+	err := p.ParseToEndContext(ctx)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, actual, 1)
+}
+
 func TestParseNextFrameNetMessages(t *testing.T) {
 	p := fake.NewParser()
 	p.On("ParseNextFrame").Return(true, nil)
@@ -0,0 +1,223 @@
+package demoinfocs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+// headerSizeS2 is the size of the PBDEMS2 file header (filestamp + file-info offset + spawn-groups offset) in bytes.
+const headerSizeS2 = 16
+
+// maxFrameSize is the largest payload size of a frame, larger sizes are considered corrupt.
+// Payloads of real demos are at most a few MiB, this keeps corrupt sizes from allocating up to 4 GiB.
+const maxFrameSize = 1 << 27
+
+// errFrameTooLarge signals a frame whose size exceeds maxFrameSize.
+var errFrameTooLarge = errors.New("frame size exceeds the maximum")
+
+// frameHeader contains the framing information of a single demo command.
+type frameHeader struct {
+	offset     int64             // Offset of the frame in the demo file in bytes
+	cmd        msg.EDemoCommands // Demo command without the compression flag
+	compressed bool
+	tick       int
+	size       int // Size of the payload in bytes
+}
+
+// frameReader reads the frames of a '.dem' file without decoding their payloads.
+// It's used for cheap passes over a demo (e.g. to find keyframes) and doesn't work with CSTV broadcasts.
+type frameReader struct {
+	r   io.Reader
+	br  *bufio.Reader
+	pos int64
+}
+
+const frameReaderBufferSize = 1 << 16
+
+// newFrameReader returns a frameReader for a stream that's already positioned at offset.
+// Payloads are skipped by reading them if the stream doesn't implement io.Seeker.
+func newFrameReader(r io.Reader, offset int64) *frameReader {
+	return &frameReader{
+		r:   r,
+		br:  bufio.NewReaderSize(r, frameReaderBufferSize),
+		pos: offset,
+	}
+}
+
+// newDemoFrameReader checks the PBDEMS2 header of a '.dem' file and returns a frameReader for the first frame.
+// The stream must be positioned at the start of the file.
+func newDemoFrameReader(r io.Reader) (*frameReader, error) {
+	_, err := readFileStamp(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFrameReader(r, headerSizeS2), nil
+}
+
+// readFileStamp reads and checks the PBDEMS2 header of a '.dem' file.
+// Returns the offset of the DEM_FileInfo frame, which is 0 for incomplete demos.
+func readFileStamp(r io.Reader) (fileInfoOffset int64, err error) {
+	header := make([]byte, headerSizeS2)
+
+	_, err = io.ReadFull(r, header)
+	if err != nil {
+		return 0, errors.Wrap(unexpectedEOF(err), "failed to read demo header")
+	}
+
+	if string(header[:8]) != "PBDEMS2\x00" {
+		return 0, ErrInvalidFileType
+	}
+
+	return int64(binary.LittleEndian.Uint32(header[8:12])), nil
+}
+
+// readMessage reads the next frame, which must be of the given command, into m.
+func (fr *frameReader) readMessage(cmd msg.EDemoCommands, m proto.Message) error {
+	h, err := fr.next()
+	if err != nil {
+		return errors.Wrap(unexpectedEOF(err), "failed to read frame header")
+	}
+
+	if h.cmd != cmd {
+		return errors.Errorf("expected %v at offset %d but got %v", cmd, h.offset, h.cmd)
+	}
+
+	b, err := fr.payload(h)
+	if err != nil {
+		return err
+	}
+
+	err = proto.Unmarshal(b, m)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unmarshal %v", cmd)
+	}
+
+	return nil
+}
+
+// ReadByte implements io.ByteReader so binary.ReadUvarint() can be used.
+func (fr *frameReader) ReadByte() (byte, error) {
+	b, err := fr.br.ReadByte()
+	if err == nil {
+		fr.pos++
+	}
+
+	return b, err
+}
+
+func (fr *frameReader) readVarInt32() (uint32, error) {
+	v, err := binary.ReadUvarint(fr)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(v), nil //nolint:gosec
+}
+
+// next reads the header of the next frame.
+// Returns io.EOF if the end of the stream was reached before the start of a frame
+// and io.ErrUnexpectedEOF if the stream ends within a frame header.
+func (fr *frameReader) next() (frameHeader, error) {
+	h := frameHeader{offset: fr.pos}
+
+	cmd, err := fr.readVarInt32()
+	if err != nil {
+		return h, err
+	}
+
+	h.cmd = msg.EDemoCommands(cmd) & ^msg.EDemoCommands_DEM_IsCompressed
+	h.compressed = (msg.EDemoCommands(cmd) & msg.EDemoCommands_DEM_IsCompressed) != 0
+
+	tick, err := fr.readVarInt32()
+	if err != nil {
+		return h, unexpectedEOF(err)
+	}
+
+	// This appears to actually be an int32, where a -1 means pre-game.
+	if tick != math.MaxUint32 {
+		h.tick = int(tick)
+	}
+
+	// unlike the parser we also read the size of DEM_Stop (usually 0), so the CDemoFileInfo can be read after it
+	size, err := fr.readVarInt32()
+	if err != nil {
+		return h, unexpectedEOF(err)
+	}
+
+	h.size = int(size)
+
+	if h.size > maxFrameSize {
+		return h, errors.Wrapf(errFrameTooLarge, "%v at offset %d has a size of %d bytes", h.cmd, h.offset, h.size)
+	}
+
+	return h, nil
+}
+
+// skip skips the payload of the frame that was just read via next().
+func (fr *frameReader) skip(h frameHeader) error {
+	seeker, ok := fr.r.(io.Seeker)
+
+	if !ok || h.size <= fr.br.Buffered() {
+		n, err := fr.br.Discard(h.size)
+		fr.pos += int64(n)
+
+		return unexpectedEOF(err)
+	}
+
+	fr.pos += int64(h.size)
+
+	_, err := seeker.Seek(fr.pos, io.SeekStart)
+	if err != nil {
+		return errors.Wrap(err, "failed to skip frame payload")
+	}
+
+	fr.br.Reset(fr.r)
+
+	return nil
+}
+
+// payload reads and - if necessary - decompresses the payload of the frame that was just read via next().
+func (fr *frameReader) payload(h frameHeader) ([]byte, error) {
+	// the buffer grows with the data that's actually read, so sizes beyond the end of the stream don't allocate their full size
+	b := bytes.NewBuffer(make([]byte, 0, min(h.size, frameReaderBufferSize)))
+
+	n, err := b.ReadFrom(io.LimitReader(fr.br, int64(h.size)))
+	fr.pos += n
+
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if n < int64(h.size) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	buf := b.Bytes()
+
+	if h.compressed {
+		buf, err = snappy.Decode(nil, buf)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decompress frame payload")
+		}
+	}
+
+	return buf, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -1,8 +1,10 @@
 package demoinfocs
 
 import (
+	"cmp"
 	"fmt"
 	"math"
+	"slices"
 	"time"
 
 	"github.com/golang/geo/r3"
@@ -24,7 +26,7 @@ func (p *parser) handleGameEventList(gel *msg.CMsgSource1LegacyGameEventList) {
 
 func (p *parser) handleGameEvent(ge *msg.CMsgSource1LegacyGameEvent) {
 	if p.gameEventDescs == nil {
-		p.eventDispatcher.Dispatch(events.ParserWarn{
+		p.warn(events.ParserWarn{
 			Message: "received GameEvent but event descriptors are missing",
 			Type:    events.WarnTypeGameEventBeforeDescriptors,
 		})
@@ -47,6 +49,15 @@ func (p *parser) handleGameEvent(ge *msg.CMsgSource1LegacyGameEvent) {
 
 	data := mapGameEventData(desc, ge)
 
+	if !p.config.LegacyGameEventDispatch {
+		p.gameEventsInTick++
+		p.gameEventIndex = p.gameEventsInTick
+
+		defer func() {
+			p.gameEventIndex = 0
+		}()
+	}
+
 	if handler, eventKnown := p.gameEventHandler.gameEventNameToHandler[desc.GetName()]; eventKnown {
 		// some events are known but have no handler
 		// these will just be dispatched as GenericGameEvent
@@ -54,16 +65,85 @@ func (p *parser) handleGameEvent(ge *msg.CMsgSource1LegacyGameEvent) {
 			handler(data)
 		}
 	} else {
-		p.eventDispatcher.Dispatch(events.ParserWarn{Message: fmt.Sprintf("unknown event %q", desc.GetName())})
+		p.warn(events.ParserWarn{
+			Message: fmt.Sprintf("unknown event %q", desc.GetName()),
+			Type:    events.WarnTypeUnknownGameEvent,
+		})
 		unassert.Error("unknown event %q", desc.GetName())
 	}
 
-	p.eventDispatcher.Dispatch(events.GenericGameEvent{
+	p.queueGameEventResult(events.GenericGameEvent{
 		Name: desc.GetName(),
 		Data: data,
+	}, true)
+}
+
+// queuedGameEvent is an event of a game-event that's dispatched at the end of the tick, see dispatchQueuedGameEvents().
+type queuedGameEvent struct {
+	index   int  // Index of the game-event within the tick
+	generic bool // Whether event is the GenericGameEvent of the game-event
+	event   any
+}
+
+// queueGameEventResult queues an event of the game-event that's currently being handled until the end of the tick.
+// Events are dispatched immediately outside of game-event handlers or if ParserConfig.LegacyGameEventDispatch is set.
+func (p *parser) queueGameEventResult(event any, generic bool) {
+	if p.gameEventIndex == 0 {
+		p.eventDispatcher.Dispatch(event)
+
+		return
+	}
+
+	p.queuedGameEvents = append(p.queuedGameEvents, queuedGameEvent{
+		index:   p.gameEventIndex,
+		generic: generic,
+		event:   event,
+	})
+}
+
+// delayUntilEndOfTick runs f at the end of the tick as part of the game-event that's currently being handled (if any),
+// so events dispatched by f keep the position of the game-event in the tick.
+func (p *parser) delayUntilEndOfTick(f func()) {
+	index := p.gameEventIndex
+
+	p.delayedEventHandlers = append(p.delayedEventHandlers, func() {
+		p.gameEventIndex = index
+		f()
+		p.gameEventIndex = 0
 	})
 }
 
+// dispatchQueuedGameEvents dispatches the events of all game-events of the tick in the order the game-events were received,
+// each followed by its GenericGameEvent. See ParserConfig.LegacyGameEventDispatch.
+func (p *parser) dispatchQueuedGameEvents() {
+	queued := p.queuedGameEvents
+
+	slices.SortStableFunc(queued, func(a, b queuedGameEvent) int {
+		if a.index != b.index {
+			return cmp.Compare(a.index, b.index)
+		}
+
+		if a.generic == b.generic {
+			return 0
+		}
+
+		if a.generic {
+			return 1
+		}
+
+		return -1
+	})
+
+	for _, e := range queued {
+		p.eventDispatcher.Dispatch(e.event)
+	}
+
+	clear(queued)
+
+	p.queuedGameEvents = queued[:0]
+	p.gameEventsInTick = 0
+}
+
 type gameEventHandler struct {
 	parser                      *parser
 	gameEventNameToHandler      map[string]gameEventHandlerFunc
@@ -74,7 +154,7 @@ type gameEventHandler struct {
 }
 
 func (geh gameEventHandler) dispatch(event any) {
-	geh.parser.eventDispatcher.Dispatch(event)
+	geh.parser.queueGameEventResult(event, false)
 }
 
 func (geh gameEventHandler) gameState() *gameState {
@@ -117,10 +197,11 @@ func newGameEventHandler(parser *parser, ignoreBombsiteIndexNotFound bool) gameE
 
 	// some events need to be delayed until their data is available
 	// some events can't be delayed because the required state is lost by the end of the tick
-	// TODO: maybe we're supposed to delay all of them and store the data we need until the end of the tick
+	// with ParserConfig.LegacyGameEventDispatch unset only the handling is delayed, the events of all game-events are
+	// dispatched at the end of the tick anyway - in the order the game-events were received
 	delay := func(f gameEventHandlerFunc) gameEventHandlerFunc {
 		return func(data map[string]*msg.CMsgSource1LegacyGameEventKeyT) {
-			parser.delayedEventHandlers = append(parser.delayedEventHandlers, func() {
+			parser.delayUntilEndOfTick(func() {
 				f(data)
 			})
 		}
@@ -488,7 +569,7 @@ func (geh gameEventHandler) playerHurt(data map[string]*msg.CMsgSource1LegacyGam
 	}
 
 	if rawWeapon == "" && wepType == common.EqUnknown {
-		geh.parser.delayedEventHandlers = append(geh.parser.delayedEventHandlers, func() {
+		geh.parser.delayUntilEndOfTick(func() {
 			resolvedType := geh.attackerWeaponType(wepType, userID)
 			if resolvedType == common.EqUnknown {
 				if geh.frameToBombExploded[geh.parser.currentFrame] {
@@ -568,7 +649,7 @@ func (geh gameEventHandler) decoyDetonate(data map[string]*msg.CMsgSource1Legacy
 		GrenadeEvent: event,
 	})
 
-	geh.parser.delayedEventHandlers = append(geh.parser.delayedEventHandlers, func() {
+	geh.parser.delayUntilEndOfTick(func() {
 		geh.deleteThrownGrenade(event.Thrower, common.EqDecoy)
 	})
 }
@@ -716,7 +797,7 @@ func (geh gameEventHandler) playerTeam(data map[string]*msg.CMsgSource1LegacyGam
 		})
 	} else {
 		// TODO: figure out why this happens and whether it's a bug or not
-		geh.dispatch(events.ParserWarn{
+		geh.parser.warn(events.ParserWarn{
 			Message: "Player team swap game-event occurred but player is nil",
 			Type:    events.WarnTypeTeamSwapPlayerNil,
 		})
@@ -835,7 +916,7 @@ func (geh gameEventHandler) bombEvent(data map[string]*msg.CMsgSource1LegacyGame
 		if bombEvent.Site == events.BomsiteUnknown {
 			// this may occur on de_grind for bombsite B, really makes you think
 			// see https://github.com/markus-wa/demoinfocs-golang/issues/280
-			geh.dispatch(events.ParserWarn{
+			geh.parser.warn(events.ParserWarn{
 				Message: "bombsite unknown for bomb related event",
 				Type:    events.WarnTypeBombsiteUnknown,
 			})
@@ -1190,6 +1271,7 @@ func (p *parser) processFlyingFlashbangs() {
 // - Bomb props used to detect bomb events are updated after the prop m_eRoundWinReason used to detect round end events
 //
 // This makes sure game events are dispatched in a more expected order.
+// Unless ParserConfig.LegacyGameEventDispatch is set, the events of all game-events of the tick are dispatched here as well.
 func (p *parser) processFrameGameEvents() {
 	if !p.disableMimicSource1GameEvents {
 		p.processFlyingFlashbangs()
@@ -1201,4 +1283,6 @@ func (p *parser) processFrameGameEvents() {
 	}
 
 	p.delayedEventHandlers = p.delayedEventHandlers[:0]
+
+	p.dispatchQueuedGameEvents()
 }
@@ -2,6 +2,9 @@ package demoinfocs
 
 import (
 	"crypto/rand"
+	"fmt"
+	"io/fs"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -55,9 +58,109 @@ func TestRoundEnd_LoserState_Score(t *testing.T) {
 	}
 	p.handleGameEvent(ge)
 
+	assert.Equal(t, 0, eventOccurred)
+
+	p.processFrameGameEvents()
+
 	assert.Equal(t, 1, eventOccurred)
 }
 
+// handleTestGameEvent passes a game-event with the given data to handleGameEvent(), creating its descriptor if necessary.
+func handleTestGameEvent(p *parser, name string, data map[string]*msg.CMsgSource1LegacyGameEventKeyT) {
+	if p.gameEventDescs == nil {
+		p.gameEventDescs = make(map[int32]*msg.CMsgSource1LegacyGameEventListDescriptorT)
+	}
+
+	id := int32(len(p.gameEventDescs) + 1)
+	desc := &msg.CMsgSource1LegacyGameEventListDescriptorT{
+		Eventid: proto.Int32(id),
+		Name:    proto.String(name),
+	}
+	ge := &msg.CMsgSource1LegacyGameEvent{
+		Eventid:   proto.Int32(id),
+		EventName: proto.String(name),
+	}
+
+	for k, v := range data {
+		desc.Keys = append(desc.Keys, &msg.CMsgSource1LegacyGameEventListKeyT{Name: proto.String(k)})
+		ge.Keys = append(ge.Keys, v)
+	}
+
+	p.gameEventDescs[id] = desc
+	p.handleGameEvent(ge)
+}
+
+func testGameEventOrder(t *testing.T, legacy bool) []string {
+	t.Helper()
+
+	config := DefaultParserConfig
+	config.LegacyGameEventDispatch = legacy
+
+	p := NewParserWithConfig(rand.Reader, config).(*parser)
+	p.disableMimicSource1GameEvents = true
+
+	var order []string
+
+	p.RegisterEventHandler(func(e any) {
+		switch e := e.(type) {
+		case events.GenericGameEvent:
+			order = append(order, e.Name)
+		case events.PlayerHurt:
+			order = append(order, "PlayerHurt")
+		case events.PlayerJump:
+			order = append(order, "PlayerJump")
+		case events.RoundMVPAnnouncement:
+			order = append(order, "RoundMVPAnnouncement")
+		}
+	})
+
+	// the weapon of player_hurt is unknown, so it's resolved at the end of the tick
+	handleTestGameEvent(p, "player_hurt", playerHurtEventData(11, 65535, ""))
+	handleTestGameEvent(p, "player_jump", map[string]*msg.CMsgSource1LegacyGameEventKeyT{"userid": {ValShort: proto.Int32(12)}})
+	handleTestGameEvent(p, "round_mvp", map[string]*msg.CMsgSource1LegacyGameEventKeyT{"userid": {ValShort: proto.Int32(13)}})
+
+	p.processFrameGameEvents()
+
+	return order
+}
+
+func TestGameEventDispatch_EndOfTick(t *testing.T) {
+	assert.Equal(t, []string{
+		"PlayerHurt", "player_hurt",
+		"PlayerJump", "player_jump",
+		"RoundMVPAnnouncement", "round_mvp",
+	}, testGameEventOrder(t, false))
+}
+
+func TestGameEventDispatch_Legacy(t *testing.T) {
+	assert.Equal(t, []string{
+		"player_hurt",
+		"PlayerJump", "player_jump",
+		"RoundMVPAnnouncement", "round_mvp",
+		"PlayerHurt",
+	}, testGameEventOrder(t, true))
+}
+
+func TestGetGameEventListBinForProtocol(t *testing.T) {
+	files, err := fs.Glob(eventListFolder, "event-list-dump/*.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("event-list-dump/%d.bin", latestGameEventListProtocol), slices.Max(files),
+		"latestGameEventListProtocol must be the protocol of the newest game-event list")
+
+	for _, protocol := range []int{13000, 14069, 14088, latestGameEventListProtocol, latestGameEventListProtocol + 1} {
+		bin, err := getGameEventListBinForProtocol(protocol)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, bin)
+	}
+
+	expected, err := eventListFolder.ReadFile("event-list-dump/14070.bin")
+	assert.NoError(t, err)
+
+	bin, err := getGameEventListBinForProtocol(14069)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, bin)
+}
+
 func TestGetPlayerWeapon_NilPlayer(t *testing.T) {
 	wep := getPlayerWeapon(nil, common.EqAK47)
 
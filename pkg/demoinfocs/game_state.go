@@ -252,6 +252,18 @@ func newGameState(demoInfo demoInfoProvider) *gameState {
 	return gs
 }
 
+// reset drops all entity-derived state, e.g. before restoring the game-state from a keyframe.
+// ConVars are kept as they're mostly sent with the signon data.
+func (gs *gameState) reset() {
+	conVars := gs.rules.conVars
+
+	*gs = *newGameState(gs.demoInfo)
+
+	gs.rules.conVars = conVars
+	gs.tState.Opponent = &gs.ctState
+	gs.ctState.Opponent = &gs.tState
+}
+
 type gameRules struct {
 	conVars map[string]string
 	entity  st.Entity
@@ -0,0 +1,109 @@
+package demoinfocs
+
+import (
+	dp "github.com/markus-wa/godispatch"
+	"google.golang.org/protobuf/proto"
+)
+
+// HandlerOption configures a handler registered via On() or OnNetMessage().
+type HandlerOption func(*handlerOptions)
+
+type handlerOptions struct {
+	tickRange bool
+	fromTick  int
+	toTick    int
+	once      bool
+}
+
+// InTickRange makes the handler ignore events / net-messages outside of the ingame ticks from and to (both inclusive).
+// The tick is taken from GameState().IngameTick() when the event / net-message is dispatched.
+func InTickRange(from, to int) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.tickRange = true
+		opts.fromTick = from
+		opts.toTick = to
+	}
+}
+
+// Once unregisters the handler after it has been called once.
+// Combined with InTickRange() only calls within the tick range count.
+func Once() HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.once = true
+	}
+}
+
+/*
+On registers a handler for events of type E (see the events package), it's the type-safe version of Parser.RegisterEventHandler().
+The returned HandlerIdentifier can be used to unregister the handler via Parser.UnregisterEventHandler().
+
+Example:
+
+	demoinfocs.On(p, func(e events.Kill) {
+		fmt.Printf("%s <%v> %s\n", e.Killer, e.Weapon, e.Victim)
+	}, demoinfocs.InTickRange(10000, 20000))
+*/
+func On[E any](p Parser, handler func(E), opts ...HandlerOption) dp.HandlerIdentifier {
+	return registerHandler(p, p.RegisterEventHandler, p.UnregisterEventHandler, handler, opts)
+}
+
+/*
+OnNetMessage registers a handler for net-messages of type M (see the msg package), it's the type-safe version of Parser.RegisterNetMessageHandler().
+The returned HandlerIdentifier can be used to unregister the handler via Parser.UnregisterNetMessageHandler().
+
+Example:
+
+	demoinfocs.OnNetMessage(p, func(m *msg.CSVCMsg_ServerInfo) {
+		fmt.Println("map:", m.GetMapName())
+	}, demoinfocs.Once())
+*/
+func OnNetMessage[M proto.Message](p Parser, handler func(M), opts ...HandlerOption) dp.HandlerIdentifier {
+	return registerHandler(p, p.RegisterNetMessageHandler, p.UnregisterNetMessageHandler, handler, opts)
+}
+
+func registerHandler[T any](
+	p Parser,
+	register func(any) dp.HandlerIdentifier,
+	unregister func(dp.HandlerIdentifier),
+	handler func(T),
+	opts []HandlerOption,
+) dp.HandlerIdentifier {
+	var options handlerOptions
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if !options.tickRange && !options.once {
+		return register(handler)
+	}
+
+	var (
+		id     dp.HandlerIdentifier
+		called bool
+	)
+
+	id = register(func(e T) {
+		if called {
+			return
+		}
+
+		if options.tickRange {
+			tick := p.GameState().IngameTick()
+
+			if tick < options.fromTick || tick > options.toTick {
+				return
+			}
+		}
+
+		if options.once {
+			called = true
+
+			unregister(id)
+		}
+
+		handler(e)
+	})
+
+	return id
+}
@@ -0,0 +1,89 @@
+package demoinfocs_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	demoinfocs "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
+	events "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/fake"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+func TestOn(t *testing.T) {
+	p := fake.NewParser()
+	p.On("ParseToEnd").Return(nil)
+	p.MockEvents(events.Kill{IsHeadshot: true}, events.RoundEnd{})
+	p.MockEvents(events.Kill{})
+
+	var kills []events.Kill
+
+	demoinfocs.On(p, func(e events.Kill) {
+		kills = append(kills, e)
+	})
+
+	err := p.ParseToEnd()
+	assert.NoError(t, err)
+	assert.Equal(t, []events.Kill{{IsHeadshot: true}, {}}, kills)
+}
+
+func TestOn_Once(t *testing.T) {
+	p := fake.NewParser()
+	p.On("ParseToEnd").Return(nil)
+	p.MockEvents(events.Kill{IsHeadshot: true})
+	p.MockEvents(events.Kill{})
+
+	var kills []events.Kill
+
+	demoinfocs.On(p, func(e events.Kill) {
+		kills = append(kills, e)
+	}, demoinfocs.Once())
+
+	err := p.ParseToEnd()
+	assert.NoError(t, err)
+	assert.Equal(t, []events.Kill{{IsHeadshot: true}}, kills)
+	p.AssertCalled(t, "UnregisterEventHandler")
+}
+
+func TestOn_InTickRange(t *testing.T) {
+	gs := new(fake.GameState)
+	gs.On("IngameTick").Return(100).Once()
+	gs.On("IngameTick").Return(200).Once()
+	gs.On("IngameTick").Return(300).Once()
+
+	p := fake.NewParser()
+	p.On("ParseToEnd").Return(nil)
+	p.On("GameState").Return(gs)
+	p.MockEvents(events.BombPlanted{})
+	p.MockEvents(events.BombDefused{})
+	p.MockEvents(events.BombExplode{})
+
+	var actual []any
+
+	demoinfocs.On(p, func(e any) {
+		actual = append(actual, e)
+	}, demoinfocs.InTickRange(150, 300))
+
+	err := p.ParseToEnd()
+	assert.NoError(t, err)
+	assert.Equal(t, []any{events.BombDefused{}, events.BombExplode{}}, actual)
+}
+
+func TestOnNetMessage(t *testing.T) {
+	p := fake.NewParser()
+	p.On("ParseToEnd").Return(nil)
+	p.MockNetMessages(&msg.CSVCMsg_ServerInfo{MapName: proto.String("de_dust2")})
+	p.MockNetMessages(&msg.CSVCMsg_ServerInfo{MapName: proto.String("de_inferno")})
+
+	var maps []string
+
+	demoinfocs.OnNetMessage(p, func(m *msg.CSVCMsg_ServerInfo) {
+		maps = append(maps, m.GetMapName())
+	}, demoinfocs.Once())
+
+	err := p.ParseToEnd()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"de_dust2"}, maps)
+}
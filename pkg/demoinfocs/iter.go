@@ -0,0 +1,133 @@
+package demoinfocs
+
+import (
+	"iter"
+	"sync/atomic"
+)
+
+/*
+Events returns an iterator over all events of the demo, parsing it frame by frame via Parser.ParseNextFrame().
+If parsing fails the error is yielded as the last element.
+
+Example:
+
+	for e, err := range demoinfocs.Events(p) {
+		if err != nil {
+			log.Panic("failed to parse demo: ", err)
+		}
+
+		fmt.Printf("%T\n", e)
+	}
+
+See EventsOf() for details.
+*/
+func Events(p Parser) iter.Seq2[any, error] {
+	return EventsOf[any](p)
+}
+
+/*
+EventsOf returns an iterator over all events of type E (see the events package), parsing the demo frame by frame via Parser.ParseNextFrame().
+If parsing fails the error is yielded as the last element.
+
+Each event is yielded while parsing is paused at the point where the event was dispatched,
+so the game-state is the same as it would be inside an event handler registered via Parser.RegisterEventHandler().
+Other event handlers are still called.
+
+Breaking out of the loop stops parsing at the end of the current frame, events after the break point in that frame aren't yielded.
+Parsing can be continued afterwards, e.g. with a new iterator or Parser.ParseToEnd().
+The Parser must not be used to parse frames inside the loop.
+
+Example:
+
+	for kill, err := range demoinfocs.EventsOf[events.Kill](p) {
+		if err != nil {
+			log.Panic("failed to parse demo: ", err)
+		}
+
+		fmt.Printf("%s <%v> %s\n", kill.Killer, kill.Weapon, kill.Victim)
+	}
+*/
+func EventsOf[E any](p Parser) iter.Seq2[E, error] {
+	return func(yield func(E, error) bool) {
+		var (
+			eventCh    = make(chan E)
+			resumeCh   = make(chan struct{})
+			errCh      = make(chan error, 1)
+			stopped    atomic.Bool
+			parsePanic any
+		)
+
+		handlerID := p.RegisterEventHandler(func(e E) {
+			if stopped.Load() {
+				return
+			}
+
+			// pause parsing until the loop body is done with the event
+			eventCh <- e
+			<-resumeCh
+		})
+
+		go func() {
+			defer close(eventCh)
+
+			defer func() {
+				parsePanic = recover()
+			}()
+
+			for !stopped.Load() {
+				moreFrames, err := p.ParseNextFrame()
+				if err != nil {
+					errCh <- err
+
+					return
+				}
+
+				if !moreFrames {
+					return
+				}
+			}
+		}()
+
+		// whether the parsing go-routine is waiting for the loop body
+		paused := false
+
+		defer func() {
+			stopped.Store(true)
+
+			if paused {
+				resumeCh <- struct{}{}
+			}
+
+			// let the rest of the current frame finish, events that were dispatched before stopping still need to be released
+			for range eventCh {
+				resumeCh <- struct{}{}
+			}
+
+			p.UnregisterEventHandler(handlerID)
+		}()
+
+		for e := range eventCh {
+			paused = true
+
+			if !yield(e, nil) {
+				return
+			}
+
+			paused = false
+			resumeCh <- struct{}{}
+		}
+
+		if parsePanic != nil {
+			panic(parsePanic)
+		}
+
+		select {
+		case err := <-errCh:
+			var zero E
+
+			yield(zero, err)
+
+		default:
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package demoinfocs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	demoinfocs "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
+	common "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/common"
+	events "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/fake"
+)
+
+func newIterTestParser() *fake.Parser {
+	p := fake.NewParser()
+	p.On("ParseNextFrame").Return(true, nil).Times(3)
+	p.On("ParseNextFrame").Return(false, nil)
+
+	p.MockEvents(events.RoundStart{}, events.Kill{Weapon: common.NewEquipment(common.EqAK47)})
+	p.MockEvents(events.Kill{Weapon: common.NewEquipment(common.EqAWP)})
+	p.MockEvents(events.RoundEnd{})
+
+	return p
+}
+
+func TestEvents(t *testing.T) {
+	p := newIterTestParser()
+
+	var actual []any
+
+	for e, err := range demoinfocs.Events(p) {
+		assert.NoError(t, err)
+
+		actual = append(actual, e)
+	}
+
+	assert.Len(t, actual, 4)
+	assert.IsType(t, events.RoundStart{}, actual[0])
+	assert.IsType(t, events.RoundEnd{}, actual[3])
+}
+
+func TestEventsOf(t *testing.T) {
+	p := newIterTestParser()
+
+	var weapons []common.EquipmentType
+
+	for kill, err := range demoinfocs.EventsOf[events.Kill](p) {
+		assert.NoError(t, err)
+
+		weapons = append(weapons, kill.Weapon.Type)
+	}
+
+	assert.Equal(t, []common.EquipmentType{common.EqAK47, common.EqAWP}, weapons)
+}
+
+func TestEventsOf_Break(t *testing.T) {
+	p := newIterTestParser()
+
+	n := 0
+
+	for range demoinfocs.EventsOf[events.Kill](p) {
+		n++
+
+		break
+	}
+
+	assert.Equal(t, 1, n)
+	p.AssertNumberOfCalls(t, "ParseNextFrame", 1)
+	p.AssertCalled(t, "UnregisterEventHandler")
+
+	// parsing can be continued
+	var rest []events.Kill
+
+	for kill := range demoinfocs.EventsOf[events.Kill](p) {
+		rest = append(rest, kill)
+	}
+
+	assert.Len(t, rest, 1)
+}
+
+func TestEventsOf_Error(t *testing.T) {
+	expectedErr := errors.New("test")
+
+	p := fake.NewParser()
+	p.On("ParseNextFrame").Return(true, expectedErr)
+	p.MockEvents(events.Kill{})
+
+	var (
+		kills int
+		err   error
+	)
+
+	for _, err = range demoinfocs.EventsOf[events.Kill](p) {
+		if err == nil {
+			kills++
+		}
+	}
+
+	assert.Equal(t, 1, kills)
+	assert.Same(t, expectedErr, err)
+}
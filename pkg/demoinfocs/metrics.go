@@ -0,0 +1,217 @@
+package demoinfocs
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// MetricKind is the kind of work a metric measures, see Metrics.
+type MetricKind int
+
+const (
+	// MetricDemoCommand measures reading, decompressing and unmarshalling demo commands.
+	// The name is the command, e.g. "DEM_Packet", bytes is the size of the frame.
+	MetricDemoCommand MetricKind = iota
+
+	// MetricNetMessage measures unmarshalling net-messages of a packet.
+	// The name is the message type, e.g. "CSVCMsg_PacketEntities", bytes is the size of the message.
+	MetricNetMessage
+
+	// MetricNetMessageHandler measures net-message handlers, including the internal ones that update the game-state.
+	// The name is the message type and the handler function, bytes is always 0.
+	// The duration includes the handlers of events dispatched by the net-message handler.
+	MetricNetMessageHandler
+
+	// MetricServerClass measures decoding entities in CSVCMsg_PacketEntities per server-class (see ParserConfig.EntityServerClasses).
+	// The name is the server-class, e.g. "CCSPlayerPawn", bytes is the size of the entity data.
+	// Each entity creation or update is counted separately.
+	MetricServerClass
+
+	// MetricEventHandler measures event handlers registered via Parser.RegisterEventHandler().
+	// The name is the event type and the handler function, bytes is always 0.
+	MetricEventHandler
+)
+
+var metricKindNames = map[MetricKind]string{
+	MetricDemoCommand:       "demo command",
+	MetricNetMessage:        "net-message",
+	MetricNetMessageHandler: "net-message handler",
+	MetricServerClass:       "server-class",
+	MetricEventHandler:      "event handler",
+}
+
+func (k MetricKind) String() string {
+	if name, ok := metricKindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("MetricKind(%d)", int(k))
+}
+
+/*
+Metrics is a sink for parser instrumentation, see ParserConfig.Metrics.
+
+Observe() is called from the goroutine that reads frames (demo commands, net-messages)
+and from the goroutine that dispatches net-messages (handlers, server-classes), so it must be safe for concurrent use.
+It's called very often, implementations should aggregate and not block.
+
+See also: MetricsRecorder
+*/
+type Metrics interface {
+	Observe(kind MetricKind, name string, bytes int, duration time.Duration)
+}
+
+// MetricSummary contains the aggregated observations of a metric, see MetricsRecorder.
+type MetricSummary struct {
+	Kind     MetricKind
+	Name     string
+	Count    int
+	Bytes    int64
+	Duration time.Duration // Total duration of all observations
+}
+
+// AvgDuration returns the average duration of the observations.
+func (s MetricSummary) AvgDuration() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return s.Duration / time.Duration(s.Count)
+}
+
+type metricKey struct {
+	kind MetricKind
+	name string
+}
+
+// MetricsRecorder is a Metrics implementation that aggregates all observations in memory.
+// It can be shared between multiple parsers.
+type MetricsRecorder struct {
+	mu      sync.Mutex
+	metrics map[metricKey]*MetricSummary
+}
+
+// NewMetricsRecorder returns a new, empty MetricsRecorder.
+func NewMetricsRecorder() *MetricsRecorder {
+	return &MetricsRecorder{
+		metrics: make(map[metricKey]*MetricSummary),
+	}
+}
+
+// Observe implements Metrics.
+func (r *MetricsRecorder) Observe(kind MetricKind, name string, bytes int, duration time.Duration) {
+	key := metricKey{kind: kind, name: name}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.metrics[key]
+	if s == nil {
+		s = &MetricSummary{Kind: kind, Name: name}
+		r.metrics[key] = s
+	}
+
+	s.Count++
+	s.Bytes += int64(bytes)
+	s.Duration += duration
+}
+
+// Summary returns the aggregated metrics, ordered by kind and descending total duration.
+func (r *MetricsRecorder) Summary() []MetricSummary {
+	r.mu.Lock()
+
+	summary := make([]MetricSummary, 0, len(r.metrics))
+
+	for _, s := range r.metrics {
+		summary = append(summary, *s)
+	}
+
+	r.mu.Unlock()
+
+	slices.SortFunc(summary, func(a, b MetricSummary) int {
+		return cmp.Or(
+			cmp.Compare(a.Kind, b.Kind),
+			cmp.Compare(b.Duration, a.Duration),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+
+	return summary
+}
+
+// WriteReport writes the Summary() as human readable table, limited to the top n metrics of each kind (n <= 0 means all).
+func (r *MetricsRecorder) WriteReport(w io.Writer, n int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, err := fmt.Fprintln(tw, "kind\tname\tcount\tbytes\ttotal\tavg\t")
+	if err != nil {
+		return err
+	}
+
+	var (
+		kind    = MetricKind(-1)
+		rank    int
+		omitted int
+	)
+
+	for _, s := range r.Summary() {
+		if s.Kind != kind {
+			kind = s.Kind
+			rank = 0
+		}
+
+		rank++
+
+		if n > 0 && rank > n {
+			omitted++
+
+			continue
+		}
+
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t\n", s.Kind, s.Name, s.Count, s.Bytes, s.Duration, s.AvgDuration())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Flush()
+	if err != nil || omitted == 0 {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "(%d more)\n", omitted)
+
+	return err
+}
+
+// instrumentHandler wraps a net-message or event handler to observe its duration if ParserConfig.Metrics is set.
+func (p *parser) instrumentHandler(kind MetricKind, handler any) any {
+	metrics := p.config.Metrics
+	if metrics == nil {
+		return handler
+	}
+
+	v := reflect.ValueOf(handler)
+	if v.Kind() != reflect.Func || v.Type().NumIn() != 1 {
+		return handler // the dispatcher panics for invalid handlers
+	}
+
+	t := v.Type()
+	name := t.In(0).String() + " " + runtime.FuncForPC(v.Pointer()).Name()
+
+	return reflect.MakeFunc(t, func(args []reflect.Value) []reflect.Value {
+		start := time.Now()
+		out := v.Call(args)
+
+		metrics.Observe(kind, name, 0, time.Since(start))
+
+		return out
+	}).Interface()
+}
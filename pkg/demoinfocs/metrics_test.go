@@ -0,0 +1,86 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+)
+
+func findMetric(summary []MetricSummary, kind MetricKind, name string) (MetricSummary, bool) {
+	for _, s := range summary {
+		if s.Kind == kind && strings.HasPrefix(s.Name, name) {
+			return s, true
+		}
+	}
+
+	return MetricSummary{}, false
+}
+
+func TestParserConfig_Metrics(t *testing.T) {
+	metrics := NewMetricsRecorder()
+
+	config := DefaultParserConfig
+	config.Metrics = metrics
+
+	p := NewParserWithConfig(bytes.NewReader(testClipDemoData(t)), config)
+
+	var frames int
+
+	p.RegisterEventHandler(func(events.FrameDone) {
+		frames++
+	})
+
+	assert.NoError(t, p.ParseToEnd())
+
+	summary := metrics.Summary()
+
+	packets, ok := findMetric(summary, MetricDemoCommand, "DEM_Packet")
+	if assert.True(t, ok) {
+		assert.Equal(t, 9, packets.Count) // 10 ticks - 1 full packet
+		assert.Positive(t, packets.Bytes)
+	}
+
+	conVars, ok := findMetric(summary, MetricNetMessage, "CNETMsg_SetConVar")
+	if assert.True(t, ok) {
+		assert.Equal(t, 9, conVars.Count)
+	}
+
+	_, ok = findMetric(summary, MetricNetMessageHandler, "*msg.CNETMsg_SetConVar ")
+	assert.True(t, ok)
+
+	handler, ok := findMetric(summary, MetricEventHandler, "events.FrameDone ")
+	if assert.True(t, ok) {
+		assert.Equal(t, frames, handler.Count)
+	}
+
+	var report, top strings.Builder
+
+	assert.NoError(t, metrics.WriteReport(&report, 0))
+	assert.Contains(t, report.String(), "DEM_Packet")
+	assert.NotContains(t, report.String(), "more)")
+
+	assert.NoError(t, metrics.WriteReport(&top, 1))
+	assert.Contains(t, top.String(), "more)")
+}
+
+func TestMetricsRecorder_Summary(t *testing.T) {
+	metrics := NewMetricsRecorder()
+
+	metrics.Observe(MetricServerClass, "CCSPlayerPawn", 10, time.Millisecond)
+	metrics.Observe(MetricServerClass, "CCSPlayerPawn", 20, 3*time.Millisecond)
+	metrics.Observe(MetricServerClass, "CCSTeam", 5, time.Second)
+	metrics.Observe(MetricDemoCommand, "DEM_Packet", 100, time.Microsecond)
+
+	assert.Equal(t, []MetricSummary{
+		{Kind: MetricDemoCommand, Name: "DEM_Packet", Count: 1, Bytes: 100, Duration: time.Microsecond},
+		{Kind: MetricServerClass, Name: "CCSTeam", Count: 1, Bytes: 5, Duration: time.Second},
+		{Kind: MetricServerClass, Name: "CCSPlayerPawn", Count: 2, Bytes: 30, Duration: 4 * time.Millisecond},
+	}, metrics.Summary())
+
+	assert.Equal(t, 2*time.Millisecond, metrics.Summary()[2].AvgDuration())
+}
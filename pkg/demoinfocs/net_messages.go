@@ -84,7 +84,10 @@ func (p *parser) handleMessageSayText2(msg *msg.CUserMessageSayText2) {
 	default:
 		errMsg := fmt.Sprintf("skipped sending ChatMessageEvent for SayText2 with unknown MsgName %q", msg.GetMessagename())
 
-		p.eventDispatcher.Dispatch(events.ParserWarn{Message: errMsg})
+		p.warn(events.ParserWarn{
+			Message: errMsg,
+			Type:    events.WarnTypeUnknownSayText2MessageName,
+		})
 		unassert.Error(errMsg)
 	}
 }
@@ -96,7 +99,10 @@ func (p *parser) handleServerRankUpdate(msg *msg.CCSUsrMsg_ServerRankUpdate) {
 		if !ok {
 			errMsg := fmt.Sprintf("rank update for unknown player with SteamID32=%d", steamID32)
 
-			p.eventDispatcher.Dispatch(events.ParserWarn{Message: errMsg})
+			p.warn(events.ParserWarn{
+				Message: errMsg,
+				Type:    events.WarnTypeRankUpdateUnknownPlayer,
+			})
 			unassert.Error(errMsg)
 		}
 
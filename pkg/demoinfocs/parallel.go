@@ -0,0 +1,350 @@
+package demoinfocs
+
+import (
+	"context"
+	"io"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+
+	dp "github.com/markus-wa/godispatch"
+	"github.com/pkg/errors"
+)
+
+// ParallelParserConfig contains the configuration for a ParallelParser.
+type ParallelParserConfig struct {
+	// ParserConfig is used for the parsers of all segments.
+	// If ParserConfig.DemoIndex is set its keyframes are used to split the demo, otherwise the demo is scanned for them.
+	ParserConfig ParserConfig
+
+	// Workers is the maximum amount of segments parsed concurrently.
+	// Each Parser uses two busy go-routines (parsing & message dispatching, see ParserConfig.MsgQueueBufferSize),
+	// so values <= 0 default to half of GOMAXPROCS (at least 1).
+	Workers int
+
+	// Segments is the amount of segments the demo is split into, 0 means the same as Workers.
+	// There can be fewer segments if the demo doesn't contain enough CDemoFullPackets.
+	Segments int
+}
+
+// Segment is a part of a demo that's parsed by its own Parser, see ParallelParser.
+type Segment struct {
+	Index      int
+	StartFrame int // First frame whose events are dispatched
+	EndFrame   int // Last frame whose events are dispatched
+	StartTick  int // Ingame tick of StartFrame
+	EndTick    int // Ingame tick of EndFrame
+}
+
+// SegmentCallback is called with the Parser of each segment before it starts parsing, see ParallelParser.OnSegment().
+type SegmentCallback func(seg Segment, p Parser) error
+
+/*
+ParallelParser parses a single demo with multiple Parsers, one per segment.
+Segments start at CDemoFullPacket keyframes (see Parser.SeekToFrame()), so no parser needs to process the frames of another segment.
+This reduces the latency for a single demo at the cost of CPU time, for many demos ParseMany() is more efficient.
+
+There are two kinds of event handlers:
+
+  - Segment handlers, registered on the Parser passed to OnSegment() callbacks. They are called concurrently for
+    different segments and only see the events and game-state of their own segment.
+    The game-state at the start of a segment is restored from the keyframe, events before that (e.g. PlayerConnect) aren't repeated.
+  - Sequential handlers, registered via RegisterEventHandler(). They receive the events of all segments
+    in the same order as a single Parser would dispatch them, from the go-routine that called ParseToEnd().
+    Use these for anything that needs global state across the whole demo.
+
+Events are buffered until all previous segments are done, so pointers in events (e.g. *common.Player)
+reflect the state at the end of their segment rather than when the event happened.
+Use segment handlers if the game-state at the time of the event is needed.
+*/
+type ParallelParser struct {
+	r                io.ReaderAt
+	size             int64
+	config           ParallelParserConfig
+	keyframeIndex    *keyframeIndex
+	segments         []Segment
+	segmentKeyframes []keyframe // keyframe each segment starts from, unused for the first segment
+	segmentCallbacks []SegmentCallback
+	eventDispatcher  dp.Dispatcher
+	sequential       map[dp.HandlerIdentifier]struct{} // Identifiers of the registered sequential handlers
+}
+
+// NewParallelParser creates a ParallelParser for the demo in r and splits it into segments.
+// size is the size of the demo in bytes.
+//
+// Returns ErrInvalidFileType if the demo isn't a CS2 demo
+// and ErrDemoIndexMismatch if ParserConfig.DemoIndex was created for a different demo.
+func NewParallelParser(r io.ReaderAt, size int64, config ParallelParserConfig) (*ParallelParser, error) {
+	if config.Workers <= 0 {
+		config.Workers = max(1, runtime.GOMAXPROCS(0)/2)
+	}
+
+	if config.Segments <= 0 {
+		config.Segments = config.Workers
+	}
+
+	pp := &ParallelParser{
+		r:          r,
+		size:       size,
+		config:     config,
+		sequential: make(map[dp.HandlerIdentifier]struct{}),
+	}
+
+	if config.ParserConfig.DemoIndex != nil {
+		err := checkDemoIndex(io.NewSectionReader(r, 0, size), config.ParserConfig.DemoIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		pp.keyframeIndex = config.ParserConfig.DemoIndex.keyframeIndex()
+	} else {
+		idx, err := scanKeyframes(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan demo for keyframes")
+		}
+
+		pp.keyframeIndex = idx
+	}
+
+	pp.splitSegments()
+
+	return pp, nil
+}
+
+// splitSegments picks the keyframes closest to evenly spaced frames as segment starts.
+func (pp *ParallelParser) splitSegments() {
+	idx := pp.keyframeIndex
+	starts := []keyframe{{frame: 0, tick: 0}}
+
+	for i := 1; i < pp.config.Segments; i++ {
+		target := idx.lastFrame * i / pp.config.Segments
+
+		for _, kf := range idx.keyframes {
+			if kf.full && kf.frame >= target && kf.frame > starts[len(starts)-1].frame {
+				starts = append(starts, kf)
+
+				break
+			}
+		}
+	}
+
+	pp.segmentKeyframes = starts
+	pp.segments = make([]Segment, len(starts))
+
+	for i, kf := range starts {
+		seg := Segment{
+			Index:      i,
+			StartFrame: kf.frame,
+			StartTick:  kf.tick,
+			EndFrame:   idx.lastFrame,
+			EndTick:    idx.lastTick,
+		}
+
+		// the keyframe itself is replayed without events by the next segment
+		if i > 0 {
+			seg.StartFrame++
+		}
+
+		if i+1 < len(starts) {
+			seg.EndFrame = starts[i+1].frame
+			seg.EndTick = starts[i+1].tick
+		}
+
+		pp.segments[i] = seg
+	}
+}
+
+// Segments returns the segments the demo is split into.
+func (pp *ParallelParser) Segments() []Segment {
+	return pp.segments
+}
+
+// OnSegment adds a callback that's called with the Parser of each segment before it starts parsing,
+// e.g. to register segment handlers. Callbacks are called concurrently for different segments.
+//
+// See ParallelParser for details.
+func (pp *ParallelParser) OnSegment(callback SegmentCallback) {
+	pp.segmentCallbacks = append(pp.segmentCallbacks, callback)
+}
+
+// RegisterEventHandler registers a sequential handler for game events.
+// Sequential handlers receive the events of all segments in order, see ParallelParser for details.
+//
+// The handler must be of the type func(<EventType>) where EventType is the kind of event to be handled.
+// To catch all events func(any) can be used.
+//
+// Returns an identifier with which the handler can be removed via UnregisterEventHandler().
+func (pp *ParallelParser) RegisterEventHandler(handler any) dp.HandlerIdentifier {
+	id := pp.eventDispatcher.RegisterHandler(handler)
+	pp.sequential[id] = struct{}{}
+
+	return id
+}
+
+// UnregisterEventHandler removes a sequential handler via identifier.
+//
+// The identifier is returned at registration by RegisterEventHandler().
+func (pp *ParallelParser) UnregisterEventHandler(identifier dp.HandlerIdentifier) {
+	delete(pp.sequential, identifier)
+
+	pp.eventDispatcher.UnregisterHandler(identifier)
+}
+
+type segmentResult struct {
+	events []any
+	err    error
+}
+
+/*
+ParseToEnd parses all segments concurrently and dispatches their events to the sequential handlers in order.
+
+Returns the first error of any segment, wrapped with the segment's frame range.
+If a segment fails, segments after it are stopped and their events aren't dispatched,
+events of the segments before it are dispatched as usual.
+*/
+func (pp *ParallelParser) ParseToEnd(ctx context.Context) error {
+	return pp.parseSegments(ctx, pp.parseSegment)
+}
+
+// parseSegments calls parse for all segments on the workers and dispatches the events of the results in order.
+func (pp *ParallelParser) parseSegments(ctx context.Context, parse func(ctx context.Context, i int) segmentResult) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+
+	// segments that are still running must be stopped before waiting for them
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	results := make([]chan segmentResult, len(pp.segments))
+	for i := range results {
+		results[i] = make(chan segmentResult, 1)
+	}
+
+	indices := make(chan int, len(pp.segments))
+	for i := range pp.segments {
+		indices <- i
+	}
+
+	close(indices)
+
+	for range min(pp.config.Workers, len(pp.segments)) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indices {
+				results[i] <- parse(ctx, i)
+			}
+		}()
+	}
+
+	for i, seg := range pp.segments {
+		res := <-results[i]
+
+		for _, e := range res.events {
+			pp.eventDispatcher.Dispatch(e)
+		}
+
+		if res.err != nil {
+			return errors.Wrapf(res.err, "failed to parse segment %d (frames %d to %d)", i, seg.StartFrame, seg.EndFrame)
+		}
+	}
+
+	return nil
+}
+
+func (pp *ParallelParser) parseSegment(ctx context.Context, i int) (res segmentResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			res.err = errors.Wrapf(ErrParsePanic, "%v\n%s", r, debug.Stack())
+		}
+	}()
+
+	if res.err = ctx.Err(); res.err != nil {
+		return res
+	}
+
+	seg := pp.segments[i]
+	rs := io.NewSectionReader(pp.r, 0, pp.size)
+	p := NewParserWithConfig(rs, pp.config.ParserConfig).(*parser)
+
+	defer p.Close()
+
+	// shared between all segments, it's never modified after scanning
+	p.keyframeIndex = pp.keyframeIndex
+
+	if len(pp.sequential) > 0 {
+		p.RegisterEventHandler(func(e any) {
+			res.events = append(res.events, e)
+		})
+	}
+
+	for _, callback := range pp.segmentCallbacks {
+		res.err = callback(seg, p)
+		if res.err != nil {
+			return res
+		}
+	}
+
+	if i > 0 {
+		res.err = p.restoreSegmentStart(rs, pp.segmentKeyframes[i])
+		if res.err != nil {
+			return res
+		}
+	}
+
+	endFrame := seg.EndFrame
+	if i == len(pp.segments)-1 {
+		// the last segment continues until the end, even if the index was created from a truncated demo
+		endFrame = math.MaxInt
+	}
+
+	for p.currentFrame <= endFrame {
+		var moreFrames bool
+
+		moreFrames, res.err = p.ParseNextFrameContext(ctx)
+		if res.err != nil || !moreFrames {
+			break
+		}
+	}
+
+	return res
+}
+
+// restoreSegmentStart parses the signon data and restores the given keyframe without dispatching any events.
+func (p *parser) restoreSegmentStart(rs io.ReadSeeker, kf keyframe) (err error) {
+	defer func() {
+		if err == nil {
+			err = recoverFromUnexpectedEOF(recover())
+		}
+	}()
+
+	_, err = p.parseHeader()
+	if err != nil {
+		return err
+	}
+
+	// the signon data and keyframe are only needed for the state, events are handled by the previous segment
+	eventDispatcher := p.eventDispatcher
+	p.eventDispatcher = new(dp.Dispatcher)
+
+	err = p.parseSignon()
+
+	p.eventDispatcher = eventDispatcher
+
+	if err != nil {
+		return err
+	}
+
+	err = p.restoreKeyframe(rs, kf)
+	if err != nil {
+		return err
+	}
+
+	return p.replayUntil(func() bool { return true }, true)
+}
@@ -0,0 +1,193 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+)
+
+func testSegmentedDemoData() []byte {
+	b := newTestDemoBuilder().signon()
+
+	for _, start := range []int32{1, 10, 20} {
+		if start > 1 {
+			b.fullPacket(start)
+		}
+
+		for tick := start + 1; tick < start+5; tick++ {
+			b.packet(tick)
+		}
+	}
+
+	return b.bytes()
+}
+
+type testTickRecorder struct {
+	mu    sync.Mutex
+	ticks map[int][]int
+}
+
+func (r *testTickRecorder) record(seg Segment, p Parser) error {
+	p.RegisterEventHandler(func(events.FrameDone) {
+		r.mu.Lock()
+		r.ticks[seg.Index] = append(r.ticks[seg.Index], p.GameState().IngameTick())
+		r.mu.Unlock()
+	})
+
+	return nil
+}
+
+func TestParallelParser(t *testing.T) {
+	data := testSegmentedDemoData()
+
+	var expectedTicks []int
+
+	p := NewParser(bytes.NewReader(data))
+	p.RegisterEventHandler(func(events.FrameDone) {
+		expectedTicks = append(expectedTicks, p.GameState().IngameTick())
+	})
+
+	err := p.ParseToEnd()
+	assert.NoError(t, err)
+
+	pp, err := NewParallelParser(bytes.NewReader(data), int64(len(data)), ParallelParserConfig{
+		ParserConfig: DefaultParserConfig,
+		Workers:      2,
+		Segments:     3,
+	})
+	assert.NoError(t, err)
+
+	segments := pp.Segments()
+	if assert.Len(t, segments, 3) {
+		assert.Equal(t, 0, segments[0].StartFrame)
+		assert.Equal(t, 10, segments[1].StartTick)
+		assert.Equal(t, segments[0].EndFrame+1, segments[1].StartFrame)
+		assert.Equal(t, segments[1].EndFrame+1, segments[2].StartFrame)
+		assert.Equal(t, 24, segments[2].EndTick)
+	}
+
+	recorder := &testTickRecorder{ticks: make(map[int][]int)}
+	pp.OnSegment(recorder.record)
+
+	var frames int
+
+	pp.RegisterEventHandler(func(events.FrameDone) {
+		frames++
+	})
+
+	err = pp.ParseToEnd(context.Background())
+	assert.NoError(t, err)
+
+	var stitchedTicks []int
+	for i := range segments {
+		stitchedTicks = append(stitchedTicks, recorder.ticks[i]...)
+	}
+
+	assert.NotEmpty(t, expectedTicks)
+	assert.Equal(t, expectedTicks, stitchedTicks)
+	assert.Equal(t, len(expectedTicks), frames)
+}
+
+func TestParallelParser_UnregisterEventHandler(t *testing.T) {
+	data := testSegmentedDemoData()
+
+	pp, err := NewParallelParser(bytes.NewReader(data), int64(len(data)), ParallelParserConfig{
+		ParserConfig: DefaultParserConfig,
+		Segments:     3,
+	})
+	assert.NoError(t, err)
+
+	var removed, frames int
+
+	id := pp.RegisterEventHandler(func(events.FrameDone) {
+		removed++
+	})
+
+	// unregistering twice must not affect other handlers
+	pp.UnregisterEventHandler(id)
+	pp.UnregisterEventHandler(id)
+
+	pp.RegisterEventHandler(func(events.FrameDone) {
+		frames++
+	})
+
+	err = pp.ParseToEnd(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, removed)
+	assert.Positive(t, frames)
+}
+
+func TestParallelParser_Error(t *testing.T) {
+	data := testCorruptDemoData()
+
+	pp, err := NewParallelParser(bytes.NewReader(data), int64(len(data)), ParallelParserConfig{
+		ParserConfig: DefaultParserConfig,
+		Segments:     2,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, pp.Segments(), 2)
+
+	err = pp.ParseToEnd(context.Background())
+	assert.ErrorIs(t, err, ErrUnexpectedEndOfDemo)
+	assert.Contains(t, err.Error(), "segment 0")
+}
+
+func TestParallelParser_Error_StopsLaterSegments(t *testing.T) {
+	data := testSegmentedDemoData()
+
+	pp, err := NewParallelParser(bytes.NewReader(data), int64(len(data)), ParallelParserConfig{
+		ParserConfig: DefaultParserConfig,
+		Workers:      3,
+		Segments:     3,
+	})
+	assert.NoError(t, err)
+
+	segmentErr := errors.New("segment failed")
+	done := make(chan error)
+
+	go func() {
+		done <- pp.parseSegments(context.Background(), func(ctx context.Context, i int) segmentResult {
+			if i == 0 {
+				return segmentResult{err: segmentErr}
+			}
+
+			<-ctx.Done()
+
+			return segmentResult{err: ctx.Err()}
+		})
+	}()
+
+	select {
+	case err = <-done:
+		assert.ErrorIs(t, err, segmentErr)
+		assert.Contains(t, err.Error(), "segment 0")
+
+	case <-time.After(10 * time.Second):
+		t.Fatal("segments after the failed one weren't stopped")
+	}
+}
+
+func TestNewParallelParser_DemoIndexMismatch(t *testing.T) {
+	data := testSegmentedDemoData()
+
+	idx, err := BuildDemoIndex(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	cfg := ParallelParserConfig{ParserConfig: DefaultParserConfig}
+	cfg.ParserConfig.DemoIndex = idx
+
+	_, err = NewParallelParser(bytes.NewReader(data), int64(len(data)), cfg)
+	assert.NoError(t, err)
+
+	other := append(bytes.Clone(data), 0) // same CDemoFileHeader but a different size and checksum
+
+	_, err = NewParallelParser(bytes.NewReader(other), int64(len(other)), cfg)
+	assert.ErrorIs(t, err, ErrDemoIndexMismatch)
+}
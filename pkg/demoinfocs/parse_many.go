@@ -0,0 +1,224 @@
+package demoinfocs
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+)
+
+// ErrParsePanic signals that parsing a demo in ParseMany() panicked outside of an event or net-message handler
+// (e.g. in the ParserCallback), the error message contains the panic value and stack trace.
+// Panics in handlers are returned as regular parser errors.
+var ErrParsePanic = errors.New("panic while parsing demo (ErrParsePanic)")
+
+// FileParserCallback is like ParserCallback but also receives the path of the demo file.
+type FileParserCallback func(path string, p Parser) error
+
+// ParseResult is the result of parsing a single demo with ParseMany().
+type ParseResult struct {
+	Path     string
+	Err      error         // Error returned by the callback or the parser, wraps ErrParsePanic on panics and ctx.Err() if the demo wasn't parsed because the context was done
+	Duration time.Duration // Time it took to parse the demo
+}
+
+// ParseManyConfig configures ParseManyWithConfig().
+type ParseManyConfig struct {
+	// Workers is the maximum amount of demos parsed concurrently.
+	// Each Parser uses two busy go-routines (parsing & message dispatching, see ParserConfig.MsgQueueBufferSize),
+	// so values <= 0 default to half of GOMAXPROCS (at least 1).
+	Workers int
+
+	// ParserConfig is used for all demos.
+	ParserConfig ParserConfig
+
+	// MemoryLimit is the heap size in bytes above which no new demos are started until others have finished.
+	// At least one demo is always being parsed, so this is a soft limit. 0 means no limit.
+	MemoryLimit uint64
+
+	// OnProgress is called with the value of Parser.Progress() every time it advances by at least 1%.
+	// It's called from the worker go-routines, so it must be safe for concurrent use.
+	OnProgress func(path string, progress float32)
+
+	// OnResult is called when a demo has been parsed.
+	// It's called from the worker go-routines, so it must be safe for concurrent use.
+	OnResult func(ParseResult)
+}
+
+// ParseMany parses the demo files at the given paths with a pool of workers (see ParseManyConfig.Workers).
+// The callback is called for each demo before it's parsed, e.g. to register event handlers.
+//
+// See ParseManyWithConfig() for details.
+func ParseMany(ctx context.Context, paths []string, workers int, configure ParserCallback) []ParseResult {
+	return ParseManyWithConfig(ctx, paths, ParseManyConfig{
+		Workers:      workers,
+		ParserConfig: DefaultParserConfig,
+	}, func(_ string, p Parser) error {
+		return configure(p)
+	})
+}
+
+/*
+ParseManyWithConfig parses the demo files at the given paths with a pool of workers.
+The callback is called for each demo before it's parsed, e.g. to register event handlers.
+
+Returns one result per path, in the same order as the paths.
+Errors and panics of a single demo don't affect the others, they are returned via ParseResult.Err.
+When the context is done all running demos are stopped (see Parser.ParseToEndContext()) and no new demos are started.
+*/
+func ParseManyWithConfig(ctx context.Context, paths []string, config ParseManyConfig, configure FileParserCallback) []ParseResult {
+	workers := config.Workers
+	if workers <= 0 {
+		workers = max(1, runtime.GOMAXPROCS(0)/2)
+	}
+
+	results := make([]ParseResult, len(paths))
+	indices := make(chan int)
+
+	var (
+		wg      sync.WaitGroup
+		limiter = newMemoryLimiter(config.MemoryLimit)
+	)
+
+	for range min(workers, len(paths)) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indices {
+				results[i] = parseManyFile(ctx, paths[i], config, configure, limiter)
+
+				if config.OnResult != nil {
+					config.OnResult(results[i])
+				}
+			}
+		}()
+	}
+
+	for i, path := range paths {
+		select {
+		case <-ctx.Done():
+			results[i] = ParseResult{Path: path, Err: ctx.Err()}
+
+			if config.OnResult != nil {
+				config.OnResult(results[i])
+			}
+
+		case indices <- i:
+		}
+	}
+
+	close(indices)
+	wg.Wait()
+
+	return results
+}
+
+func parseManyFile(ctx context.Context, path string, config ParseManyConfig, configure FileParserCallback, limiter *memoryLimiter) (res ParseResult) {
+	res.Path = path
+
+	err := limiter.acquire(ctx)
+	if err != nil {
+		res.Err = err
+
+		return res
+	}
+
+	defer limiter.release()
+
+	start := time.Now()
+
+	defer func() {
+		res.Duration = time.Since(start)
+
+		if r := recover(); r != nil {
+			res.Err = errors.Wrapf(ErrParsePanic, "%v\n%s", r, debug.Stack())
+		}
+	}()
+
+	res.Err = ParseFileWithConfigContext(ctx, path, config.ParserConfig, func(p Parser) error {
+		if config.OnProgress != nil {
+			reportProgress(p, path, config.OnProgress)
+		}
+
+		return configure(path, p)
+	})
+
+	return res
+}
+
+func reportProgress(p Parser, path string, onProgress func(string, float32)) {
+	lastPercent := -1
+
+	p.RegisterEventHandler(func(events.FrameDone) {
+		progress := p.Progress()
+
+		percent := int(progress * 100)
+		if percent > lastPercent {
+			lastPercent = percent
+
+			onProgress(path, progress)
+		}
+	})
+}
+
+// memoryLimiter delays new demos while the heap is above the limit and other demos are still being parsed.
+type memoryLimiter struct {
+	limit  uint64
+	mu     sync.Mutex
+	active int
+	sample []metrics.Sample
+}
+
+const memoryLimiterPollInterval = 100 * time.Millisecond
+
+func newMemoryLimiter(limit uint64) *memoryLimiter {
+	return &memoryLimiter{
+		limit:  limit,
+		sample: []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}},
+	}
+}
+
+func (l *memoryLimiter) tryAcquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit > 0 && l.active > 0 {
+		metrics.Read(l.sample)
+
+		if l.sample[0].Value.Kind() == metrics.KindUint64 && l.sample[0].Value.Uint64() > l.limit {
+			return false
+		}
+	}
+
+	l.active++
+
+	return true
+}
+
+func (l *memoryLimiter) acquire(ctx context.Context) error {
+	for !l.tryAcquire() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for memory to become available: %w", ctx.Err())
+
+		case <-time.After(memoryLimiterPollInterval):
+		}
+	}
+
+	return nil
+}
+
+func (l *memoryLimiter) release() {
+	l.mu.Lock()
+	l.active--
+	l.mu.Unlock()
+}
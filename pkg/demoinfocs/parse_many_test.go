@@ -0,0 +1,124 @@
+package demoinfocs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+)
+
+func testValidDemoData() []byte {
+	return newTestDemoBuilder().signon().packet(1).packet(2).bytes()
+}
+
+func writeTestDemos(t *testing.T, demos ...[]byte) []string {
+	t.Helper()
+
+	dir := t.TempDir()
+	paths := make([]string, len(demos))
+
+	for i, data := range demos {
+		paths[i] = filepath.Join(dir, "demo"+string(rune('a'+i))+".dem")
+
+		err := os.WriteFile(paths[i], data, 0o600)
+		assert.NoError(t, err)
+	}
+
+	return paths
+}
+
+func TestParseMany(t *testing.T) {
+	paths := writeTestDemos(t, testValidDemoData(), testCorruptDemoData(), testValidDemoData())
+	paths = append(paths, filepath.Join(t.TempDir(), "missing.dem"))
+
+	var (
+		mu     sync.Mutex
+		frames int
+	)
+
+	results := ParseMany(context.Background(), paths, 2, func(p Parser) error {
+		p.RegisterEventHandler(func(events.FrameDone) {
+			mu.Lock()
+			frames++
+			mu.Unlock()
+		})
+
+		return nil
+	})
+
+	if assert.Len(t, results, 4) {
+		for i, res := range results {
+			assert.Equal(t, paths[i], res.Path)
+		}
+
+		assert.NoError(t, results[0].Err)
+		assert.ErrorIs(t, results[1].Err, ErrUnexpectedEndOfDemo)
+		assert.NoError(t, results[2].Err)
+		assert.Error(t, results[3].Err)
+	}
+
+	assert.Positive(t, frames)
+}
+
+func TestParseMany_Panic(t *testing.T) {
+	paths := writeTestDemos(t, testValidDemoData(), testValidDemoData())
+
+	results := ParseMany(context.Background(), paths, 1, func(Parser) error {
+		panic("test")
+	})
+
+	for _, res := range results {
+		assert.ErrorIs(t, res.Err, ErrParsePanic)
+		assert.Contains(t, res.Err.Error(), "test")
+	}
+}
+
+func TestParseMany_Canceled(t *testing.T) {
+	paths := writeTestDemos(t, testValidDemoData(), testValidDemoData())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := ParseMany(ctx, paths, 1, func(Parser) error {
+		return nil
+	})
+
+	for _, res := range results {
+		assert.ErrorIs(t, res.Err, context.Canceled)
+	}
+}
+
+func TestParseManyWithConfig_Progress(t *testing.T) {
+	paths := writeTestDemos(t, testValidDemoData())
+
+	var (
+		progress []float32
+		results  []ParseResult
+	)
+
+	ParseManyWithConfig(context.Background(), paths, ParseManyConfig{
+		ParserConfig: DefaultParserConfig,
+		MemoryLimit:  1,
+		OnProgress: func(path string, p float32) {
+			assert.Equal(t, paths[0], path)
+
+			progress = append(progress, p)
+		},
+		OnResult: func(res ParseResult) {
+			results = append(results, res)
+		},
+	}, func(path string, _ Parser) error {
+		assert.Equal(t, paths[0], path)
+
+		return nil
+	})
+
+	assert.NotEmpty(t, progress)
+	assert.Len(t, results, 1)
+	assert.NoError(t, results[0].Err)
+}
@@ -1,6 +1,7 @@
 package demoinfocs
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
 	"io"
@@ -22,7 +23,7 @@ import (
 	st "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/sendtables"
 )
 
-//go:generate ifacemaker -f parser.go -f parsing.go -s parser -i Parser -p demoinfocs -D -y "Parser is an auto-generated interface for Parser, intended to be used when mockability is needed." -c "DO NOT EDIT: Auto generated" -o parser_interface.go
+//go:generate ifacemaker -f parser.go -f parsing.go -f seeking.go -f checkpoint.go -f warnings.go -s parser -i Parser -p demoinfocs -D -y "Parser is an auto-generated interface for Parser, intended to be used when mockability is needed." -c "DO NOT EDIT: Auto generated" -o parser_interface.go
 
 type sendTableParser interface {
 	ReadEnterPVS(r *bit.BitReader, index int, entities map[int]st.Entity, slot int) st.Entity
@@ -33,6 +34,7 @@ type sendTableParser interface {
 	OnServerInfo(m *msg.CSVCMsg_ServerInfo) error
 	OnPacketEntities(m *msg.CSVCMsg_PacketEntities) error
 	OnEntity(h st.EntityHandler)
+	ResetEntities()
 }
 
 // header contains information from a demo's header.
@@ -97,6 +99,8 @@ type parser struct {
 	// Important fields
 
 	config                          ParserConfig
+	demoStream                      io.Reader // Kept for seeking, see SeekToTick()
+	streamBase                      int64     // Offset in the demo stream at which bitReader was opened
 	bitReader                       *bit.BitReader
 	stParser                        sendTableParser
 	additionalNetMessageCreators    map[int]NetMessageCreator // Map of net-message-IDs to NetMessageCreators (for parsing custom net-messages)
@@ -134,6 +138,19 @@ type parser struct {
 	stringTables          []*msg.CSVCMsg_CreateStringTable                         // Contains all created sendtables, needed when updating them
 	delayedEventHandlers  []func()                                                 // Contains event handlers that need to be executed at the end of a tick (e.g. flash events because FlashDuration isn't updated before that)
 	pendingMessagesCache  []pendingMessage                                         // Cache for pending messages that need to be dispatched after the current tick
+	keyframeIndex         *keyframeIndex                                           // Positions of CDemoFullPackets, lazily created when seeking
+	signonRawPlayers      map[int]*common.PlayerInfo                               // Copy of rawPlayers at the end of the signon data, used when seeking
+	demoFileHeader        *msg.CDemoFileHeader                                     // Used to check ParserConfig.DemoIndex
+	checkpoints           *checkpointRecorder                                      // Frames required for Checkpoint(), nil if ParserConfig.EnableCheckpoints isn't set
+	currentFrameInfo      frameInfo                                                // Position of the frame that's currently being handled, used for FrameDecodeError
+	broadcast             broadcastStatsProvider                                   // The CSTV reader if the demo stream is a live broadcast, nil otherwise
+	broadcastStats        cstv.Stats                                               // Last queued broadcast stats, see queueBroadcastStats()
+	broadcastLag          int                                                      // Last dispatched BroadcastLagChanged.Lag
+	warnings              map[events.WarnType]*WarningSummary                      // Aggregated ParserWarns, see Warnings()
+	warningsLock          sync.Mutex                                               // Used to sync up warnings between parsing & handling go-routines
+	queuedGameEvents      []queuedGameEvent                                        // Events of the game-events of the current tick, see ParserConfig.LegacyGameEventDispatch
+	gameEventsInTick      int                                                      // Number of game-events received in the current tick
+	gameEventIndex        int                                                      // Index of the game-event that's currently being handled within the tick, 0 outside of game-event handlers
 }
 
 // NetMessageCreator creates additional net-messages to be dispatched to net-message handlers.
@@ -258,7 +275,7 @@ Parameter handler has to be of type any because Go generics only work on functio
 Returns an identifier with which the handler can be removed via UnregisterEventHandler().
 */
 func (p *parser) RegisterEventHandler(handler any) dp.HandlerIdentifier {
-	return p.eventDispatcher.RegisterHandler(handler)
+	return p.eventDispatcher.RegisterHandler(p.instrumentHandler(MetricEventHandler, handler))
 }
 
 // UnregisterEventHandler removes a game event handler via identifier.
@@ -280,7 +297,7 @@ Returns an identifier with which the handler can be removed via UnregisterNetMes
 See also: RegisterEventHandler()
 */
 func (p *parser) RegisterNetMessageHandler(handler any) dp.HandlerIdentifier {
-	return p.msgDispatcher.RegisterHandler(handler)
+	return p.msgDispatcher.RegisterHandler(p.instrumentHandler(MetricNetMessageHandler, handler))
 }
 
 // UnregisterNetMessageHandler removes a net-message handler via identifier.
@@ -314,6 +331,13 @@ func (p *parser) error() error {
 	return err
 }
 
+// clearError removes the error after recovering from it.
+func (p *parser) clearError() {
+	p.errLock.Lock()
+	p.err = nil
+	p.errLock.Unlock()
+}
+
 func (p *parser) setError(err error) {
 	if err == nil {
 		return
@@ -335,7 +359,7 @@ func (p *parser) setError(err error) {
 func (p *parser) poolBitReader(r *bit.BitReader) {
 	err := r.Pool()
 	if err != nil {
-		p.eventDispatcher.Dispatch(events.ParserWarn{
+		p.warn(events.ParserWarn{
 			Message: err.Error(),
 		})
 	}
@@ -369,7 +393,12 @@ func NewCSTVBroadcastParser(baseUrl string) (Parser, error) {
 //
 // See also: NewParserWithConfig() & DefaultParserConfig
 func NewCSTVBroadcastParserWithConfig(baseUrl string, config ParserConfig) (Parser, error) {
-	r, err := cstv.NewReader(baseUrl, config.CSTVTimeout)
+	readerConfig := config.CSTVReaderConfig
+	if readerConfig.Retry == nil {
+		readerConfig.Retry = cstv.TimeoutRetryPolicy(config.CSTVTimeout)
+	}
+
+	r, err := cstv.NewReaderWithConfig(baseUrl, readerConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create CSTV reader: %w", err)
 	}
@@ -386,6 +415,13 @@ type ParserCallback func(Parser) error
 //
 // Returns an error if the parser encounters an error.
 func ParseWithConfig(r io.Reader, config ParserConfig, configure ParserCallback) error {
+	return ParseWithConfigContext(context.Background(), r, config, configure)
+}
+
+// ParseWithConfigContext is like ParseWithConfig() but stops parsing when the context is done.
+//
+// See also: Parser.ParseToEndContext()
+func ParseWithConfigContext(ctx context.Context, r io.Reader, config ParserConfig, configure ParserCallback) error {
 	p := NewParserWithConfig(r, config)
 	defer p.Close()
 
@@ -394,7 +430,7 @@ func ParseWithConfig(r io.Reader, config ParserConfig, configure ParserCallback)
 		return fmt.Errorf("failed to configure parser: %w", err)
 	}
 
-	err = p.ParseToEnd()
+	err = p.ParseToEndContext(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to parse demo: %w", err)
 	}
@@ -415,6 +451,13 @@ func Parse(r io.Reader, configure ParserCallback) error {
 //
 // Returns an error if the file can't be opened or if the parser encounters an error.
 func ParseFileWithConfig(path string, config ParserConfig, configure ParserCallback) error {
+	return ParseFileWithConfigContext(context.Background(), path, config, configure)
+}
+
+// ParseFileWithConfigContext is like ParseFileWithConfig() but stops parsing when the context is done.
+//
+// See also: Parser.ParseToEndContext()
+func ParseFileWithConfigContext(ctx context.Context, path string, config ParserConfig, configure ParserCallback) error {
 	f, err := os.Open(path)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
@@ -422,7 +465,7 @@ func ParseFileWithConfig(path string, config ParserConfig, configure ParserCallb
 
 	defer f.Close()
 
-	return ParseWithConfig(f, config, configure)
+	return ParseWithConfigContext(ctx, f, config, configure)
 }
 
 // ParseFile parses a demo file at the given path.
@@ -433,6 +476,13 @@ func ParseFile(path string, configure ParserCallback) error {
 	return ParseFileWithConfig(path, DefaultParserConfig, configure)
 }
 
+// ParseFileContext is like ParseFile() but stops parsing when the context is done.
+//
+// See also: Parser.ParseToEndContext()
+func ParseFileContext(ctx context.Context, path string, configure ParserCallback) error {
+	return ParseFileWithConfigContext(ctx, path, DefaultParserConfig, configure)
+}
+
 // ParseCSTVBroadcastWithConfig parses a live CSTV broadcast from the given base URL with a custom configuration.
 // The handler is called with the Parser instance.
 // The baseUrl is the base URL of the CSTV broadcast, e.g. "http://localhost:8080/s85568392932860274t1733091777".
@@ -511,6 +561,67 @@ type ParserConfig struct {
 	// It's the maximum time to retry for a response from the CSTV server, using an exponential backoff mechanism, starting at 1s.
 	// Only used when Format is DemoFormatCSTVBroadcast.
 	CSTVTimeout time.Duration
+
+	// CSTVReaderConfig configures the HTTP client for CSTV broadcasts, e.g. auth headers, proxies or cancellation.
+	// CSTVTimeout is ignored if CSTVReaderConfig.Retry is set.
+	// Only used by NewCSTVBroadcastParserWithConfig().
+	CSTVReaderConfig cstv.ReaderConfig
+
+	// RecoverFromCorruptFrames tells the parser to skip ahead to the next CDemoFullPacket when a frame can't be decoded
+	// (see FrameDecodeError) instead of aborting. Entities and the game-state are restored from the CDemoFullPacket,
+	// the skipped frames and rounds are described by a ParserWarn event with the type WarnTypeCorruptFramesSkipped.
+	// Player and entity pointers from before the corrupt frame must not be used anymore afterwards.
+	// Requires a '.dem' file stream that implements io.ReadSeeker, other streams still return the FrameDecodeError.
+	RecoverFromCorruptFrames bool
+
+	// DemoIndex is used by SeekToTick() and SeekToFrame() instead of scanning the demo for keyframes.
+	// Seeking returns ErrDemoIndexMismatch if it was created for a different demo.
+	// See BuildDemoIndex() and LoadOrBuildDemoIndex().
+	DemoIndex *DemoIndex
+
+	// EnableCheckpoints makes the parser keep the signon data and all frames since the last CDemoFullPacket in memory,
+	// which is required for Parser.Checkpoint(). See NewParserFromCheckpoint().
+	// The memory isn't bounded, it grows with the number of frames between two CDemoFullPackets
+	// and without limit for demos or broadcasts that don't contain any after the signon data.
+	EnableCheckpoints bool
+
+	// EntityServerClasses limits entity decoding to the given server-classes, e.g. "CCSPlayerController", "CCSPlayerPawn",
+	// "CCSTeam", "CCSGameRulesProxy" and "CSmokeGrenadeProjectile". Empty means all server-classes.
+	// Entities of other server-classes are skipped without decoding their properties, which saves a lot of CPU time.
+	// They don't exist for the parser: no entity events are dispatched and the game-state doesn't contain them
+	// (e.g. weapons if their server-classes aren't included), so it's only complete for the included server-classes.
+	EntityServerClasses []string
+
+	// EntityPropertyPrefixes limits entity decoding to properties that start with one of the given prefixes,
+	// e.g. "m_iHealth" or "CBodyComponent". Empty means all properties.
+	// Prefixes are matched against top-level properties, "m_pGameRules.m_bWarmupPeriod" keeps all of "m_pGameRules".
+	// Properties the parser needs for the game-state (e.g. entity handles, teams, SteamIDs and CBodyComponent) are always decoded.
+	// Other properties don't have a value, so the game-state may be incomplete if it relies on them,
+	// e.g. Player.Health(), Armor() and Money() return 0 unless "m_iHealth", "m_ArmorValue" and "m_pInGameMoneyServices" are included.
+	EntityPropertyPrefixes []string
+
+	// Metrics receives counts, bytes and durations of decoding demo commands, net-messages and entities
+	// as well as of net-message and event handlers, e.g. to find out why a demo is slow to parse.
+	// Instrumentation has an overhead, so it should only be used for profiling. nil disables it.
+	// See NewMetricsRecorder() for an in-process summary report.
+	Metrics Metrics
+
+	// Strict defines per WarnType whether ParserWarn events are ignored, dispatched (default)
+	// or stop parsing with a *WarningError, e.g. to fail CI when a CS2 update introduces unknown net-messages.
+	// All warnings are counted in Parser.Warnings() regardless of their policy.
+	Strict StrictConfig
+
+	// LegacyGameEventDispatch restores the old dispatch model of events that are based on game-events (e.g. events.Kill,
+	// events.PlayerHurt or events.GenericGameEvent): most are dispatched immediately when the game-event is received,
+	// some are delayed until the end of the tick, so their order within a tick depends on net-message order and entity timing.
+	//
+	// By default the data of a game-event is captured when it's received, but its events are dispatched at the end of the tick,
+	// before events.FrameDone, in a deterministic order:
+	//  1. events mimicked from entity updates at the end of the tick (e.g. events.PlayerFlashed or events.RoundEnd)
+	//  2. the events of each game-event, in the order the game-events were received - each followed by its events.GenericGameEvent
+	// Players and entities referenced by the events reflect the state at the end of the tick.
+	// Events that are detected from entity updates (e.g. events.PlayerConnect) are still dispatched immediately.
+	LegacyGameEventDispatch bool
 }
 
 // DefaultParserConfig is the default Parser configuration used by NewParser().
@@ -527,6 +638,7 @@ func NewParserWithConfig(demostream io.Reader, config ParserConfig) Parser {
 
 	// Init parser
 	p.config = config
+	p.demoStream = demostream
 	if p.config.Format == DemoFormatFile {
 		p.bitReader = bit.NewLargeBitReader(demostream)
 	} else {
@@ -547,6 +659,14 @@ func NewParserWithConfig(demostream io.Reader, config ParserConfig) Parser {
 	p.source2FallbackGameEventListBin = config.Source2FallbackGameEventListBin
 	p.ignorePacketEntitiesPanic = config.IgnorePacketEntitiesPanic
 
+	if config.EnableCheckpoints {
+		p.checkpoints = new(checkpointRecorder)
+	}
+
+	if provider, ok := demostream.(broadcastStatsProvider); ok && config.Format == DemoFormatCSTVBroadcast {
+		p.broadcast = provider
+	}
+
 	dispatcherCfg := dp.Config{
 		PanicHandler: func(v any) {
 			p.setError(fmt.Errorf("%v\nstacktrace:\n%s", v, debug.Stack()))
@@ -555,22 +675,26 @@ func NewParserWithConfig(demostream io.Reader, config ParserConfig) Parser {
 	p.msgDispatcher = dp.NewDispatcherWithConfig(dispatcherCfg)
 	p.eventDispatcher = dp.NewDispatcherWithConfig(dispatcherCfg)
 
-	p.msgDispatcher.RegisterHandler(p.handleGameEventList)
-	p.msgDispatcher.RegisterHandler(p.handleGameEvent)
-	p.msgDispatcher.RegisterHandler(p.handleServerInfo)
-	p.msgDispatcher.RegisterHandler(p.handleCreateStringTable)
-	p.msgDispatcher.RegisterHandler(p.handleUpdateStringTable)
-	p.msgDispatcher.RegisterHandler(p.handleSetConVar)
-	p.msgDispatcher.RegisterHandler(p.handleServerRankUpdate)
-	p.msgDispatcher.RegisterHandler(p.handleMessageSayText)
-	p.msgDispatcher.RegisterHandler(p.handleMessageSayText2)
-	p.msgDispatcher.RegisterHandler(p.handleSendTables)
-	p.msgDispatcher.RegisterHandler(p.handleFileInfo)
-	p.msgDispatcher.RegisterHandler(p.handleDemoFileHeader)
-	p.msgDispatcher.RegisterHandler(p.handleClassInfo)
-	p.msgDispatcher.RegisterHandler(p.handleStringTables)
-	p.msgDispatcher.RegisterHandler(p.handleFrameParsed)
-	p.msgDispatcher.RegisterHandler(p.gameState.handleIngameTickNumber)
+	p.RegisterNetMessageHandler(p.handleGameEventList)
+	p.RegisterNetMessageHandler(p.handleGameEvent)
+	p.RegisterNetMessageHandler(p.handleServerInfo)
+	p.RegisterNetMessageHandler(p.handleCreateStringTable)
+	p.RegisterNetMessageHandler(p.handleUpdateStringTable)
+	p.RegisterNetMessageHandler(p.handleSetConVar)
+	p.RegisterNetMessageHandler(p.handleServerRankUpdate)
+	p.RegisterNetMessageHandler(p.handleMessageSayText)
+	p.RegisterNetMessageHandler(p.handleMessageSayText2)
+	p.RegisterNetMessageHandler(p.handleSendTables)
+	p.RegisterNetMessageHandler(p.handleFileInfo)
+	p.RegisterNetMessageHandler(p.handleDemoFileHeader)
+	p.RegisterNetMessageHandler(p.handleClassInfo)
+	p.RegisterNetMessageHandler(p.handleStringTables)
+	p.RegisterNetMessageHandler(p.handleFrameParsed)
+	p.RegisterNetMessageHandler(p.handleSyncTick)
+	p.RegisterNetMessageHandler(p.gameState.handleIngameTickNumber)
+	p.RegisterNetMessageHandler(p.handleFrameInfo)
+	p.RegisterNetMessageHandler(p.handleBroadcastStats)
+	p.RegisterNetMessageHandler(p.handleQueuedWarn)
 
 	if config.MsgQueueBufferSize >= 0 {
 		p.initMsgQueue(config.MsgQueueBufferSize)
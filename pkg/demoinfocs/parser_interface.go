@@ -3,7 +3,9 @@
 package demoinfocs
 
 import (
+	"context"
 	_ "embed"
+	"io"
 	"time"
 
 	st "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/sendtables"
@@ -104,9 +106,20 @@ type Parser interface {
 	// Aborts and returns ErrCancelled if Cancel() is called before the end.
 	//
 	// See also: ParseNextFrame() for other possible errors.
-	ParseToEnd() (err error)
+	ParseToEnd() error
+	/*
+	   ParseToEndContext is like ParseToEnd() but stops when the context is done.
+
+	   The context is checked before each frame, all messages of the frames parsed until then are still handled.
+	   In that case ctx.Err() is returned, wrapped with the frame and ingame tick the parser stopped at.
+	   Unlike Cancel() this doesn't unregister any handlers, so the state of the parser can be inspected afterwards.
+	   Parsing can't be resumed after this.
+	*/
+	ParseToEndContext(ctx context.Context) (err error)
 	// Cancel aborts ParseToEnd() and drains the internal event queues.
 	// No further events will be sent to event or message handlers after this.
+	//
+	// See also: ParseToEndContext() for stopping without unregistering handlers.
 	Cancel()
 	/*
 	   ParseNextFrame attempts to parse the next frame / demo-tick (not ingame tick).
@@ -119,4 +132,52 @@ type Parser interface {
 	   See also: ParseToEnd() for parsing the complete demo in one go (faster).
 	*/
 	ParseNextFrame() (moreFrames bool, err error)
+	/*
+	   ParseNextFrameContext is like ParseNextFrame() but doesn't parse the frame if the context is already done.
+
+	   In that case true and ctx.Err() are returned, wrapped with the frame and ingame tick the parser stopped at.
+	   The parser isn't affected otherwise, so parsing can be resumed with a different context.
+	*/
+	ParseNextFrameContext(ctx context.Context) (moreFrames bool, err error)
+	/*
+	   SeekToTick moves the parser to the given ingame tick.
+
+	   The entities, string tables and game-state are restored from the closest preceding CDemoFullPacket (keyframe),
+	   afterwards the frames between the keyframe and the target are replayed.
+	   If the target lies ahead of the current position and there is no keyframe in-between, the parser simply parses forward.
+	   After seeking GameState().IngameTick() is the first tick >= the requested tick.
+
+	   Game events are not dispatched for replayed frames. Net-message handlers and entity handlers
+	   (e.g. registered via ServerClass.OnEntityCreated()) are still called as the state is rebuilt.
+
+	   Returns ErrSeekNotSupported if the demo stream doesn't implement io.ReadSeeker (e.g. CSTV broadcasts)
+	   and ErrSeekOutOfRange if the tick lies beyond the end of the demo.
+	   Must not be called after ParseToEnd() or after ParseNextFrame() returned false.
+	*/
+	SeekToTick(tick int) error
+	// SeekToFrame moves the parser to the given frame / demo-tick (not ingame tick).
+	// After seeking CurrentFrame() returns the requested frame (or the first frame after the signon data if the requested frame is part of it).
+	//
+	// See SeekToTick() for details and possible errors.
+	SeekToFrame(frame int) error
+	/*
+	   Checkpoint writes the current state of the parser to w, so parsing can be resumed later via NewParserFromCheckpoint(),
+	   e.g. after a crash or restart.
+
+	   The entity & field state isn't serialised. Instead the checkpoint contains the frames needed to rebuild it:
+	   the signon data plus the last CDemoFullPacket and all frames after it, as they were read from the demo.
+	   NewParserFromCheckpoint() parses these frames again (without dispatching events), which rebuilds the entities,
+	   string tables, player infos and the game-state. So the size of a checkpoint and the time to restore it grow with the
+	   number of frames since the last CDemoFullPacket.
+	   ConVars, the stream position, the current frame and the demo header are stored as well.
+	   The checkpoint contains the CDemoFileHeader of the demo and CheckpointVersion, loading it for a different demo or
+	   with a different version of the library fails.
+
+	   Requires ParserConfig.EnableCheckpoints, otherwise ErrCheckpointsDisabled is returned.
+	   Must be called between frames (e.g. after ParseNextFrame()) and not from an event or net-message handler.
+	*/
+	Checkpoint(w io.Writer) error
+	// Warnings returns the ParserWarn events that occurred so far, aggregated per WarnType and ordered by type.
+	// Includes warnings that weren't dispatched because of ParserConfig.Strict.
+	Warnings() []WarningSummary
 }
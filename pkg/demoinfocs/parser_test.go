@@ -1,6 +1,8 @@
 package demoinfocs
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,6 +12,10 @@ import (
 
 	dispatch "github.com/markus-wa/godispatch"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/internal/bitwrite"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
 )
 
 func TestParser_CurrentFrame(t *testing.T) {
@@ -135,3 +141,75 @@ func TestParser_Close(t *testing.T) {
 
 	assert.False(t, called)
 }
+
+func TestParser_ParseToEndContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := NewParser(bytes.NewReader(testDemoData()))
+
+	err := p.ParseToEndContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorContains(t, err, "frame 0")
+}
+
+func TestParser_ParseNextFrameContext_Resume(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	p := NewParser(bytes.NewReader(testDemoData()))
+
+	moreFrames, err := p.ParseNextFrameContext(ctx)
+	assert.True(t, moreFrames)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Zero(t, p.CurrentFrame())
+
+	moreFrames, err = p.ParseNextFrameContext(context.Background())
+	assert.True(t, moreFrames)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, p.CurrentFrame())
+}
+
+func TestParser_ParseToEnd_FrameDecodeError(t *testing.T) {
+	p := NewParser(bytes.NewReader(testDemoData()))
+
+	err := p.ParseToEnd()
+	assert.ErrorIs(t, err, ErrUnexpectedEndOfDemo)
+
+	var decodeErr *FrameDecodeError
+
+	assert.ErrorAs(t, err, &decodeErr)
+	assert.Equal(t, 3, decodeErr.Frame)
+	assert.Equal(t, msg.EDemoCommands_DEM_Packet, decodeErr.Command)
+	assert.Equal(t, int32(-1), decodeErr.MsgType)
+	assert.Equal(t, int64(16+7+len(testFileHeader())+7+7), decodeErr.Offset)
+}
+
+func TestParser_ParseToEnd_FrameDecodeError_NetMessage(t *testing.T) {
+	w := new(bitwrite.BitWriter)
+	w.WriteUBitInt(uint(msg.NET_Messages_net_Tick))
+	w.WriteBytes([]byte{2, 0xFF, 0xFF}) // size 2, invalid protobuf
+
+	packet, err := proto.Marshal(&msg.CDemoPacket{Data: w.Bytes()})
+	assert.NoError(t, err)
+
+	b := newTestDemoBuilder().frame(msg.EDemoCommands_DEM_Packet, 5, packet).bytes()
+
+	err = NewParser(bytes.NewReader(b)).ParseToEnd()
+
+	var decodeErr *FrameDecodeError
+
+	assert.ErrorAs(t, err, &decodeErr)
+	assert.Equal(t, 1, decodeErr.Frame)
+	assert.Equal(t, 5, decodeErr.Tick)
+	assert.Equal(t, int32(msg.NET_Messages_net_Tick), decodeErr.MsgType)
+}
+
+func TestEntityPropertyPrefixes_Required(t *testing.T) {
+	assert.Nil(t, entityPropertyPrefixes(nil))
+
+	prefixes := entityPropertyPrefixes([]string{"m_iHealth"})
+
+	assert.Contains(t, prefixes, "m_iHealth")
+	assert.Subset(t, prefixes, []string{"m_hController", "m_steamID", "m_iTeamNum", "CBodyComponent"})
+}
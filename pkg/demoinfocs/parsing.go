@@ -1,9 +1,11 @@
 package demoinfocs
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"math"
+	"slices"
 	"time"
 
 	"github.com/golang/snappy"
@@ -11,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/cstv"
 	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
 	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/sendtables/sendtablescs2"
 
@@ -22,6 +25,40 @@ const (
 	gameRulesPrefixS2    = "m_pGameRules"
 )
 
+// requiredEntityPropertyPrefixes are always decoded if ParserConfig.EntityPropertyPrefixes is set,
+// the game-state bindings (see datatables.go) read them without checking whether they have a value.
+var requiredEntityPropertyPrefixes = []string{
+	"CBodyComponent", // positions and models
+	"m_hController",
+	"m_hOriginalControllerOfCurrentPawn",
+	"m_hOwnerEntity",
+	"m_hPawn",
+	"m_hPlayerPawn",
+	"m_hThrower",
+	"m_hBombDefuser",
+	"m_bBombDefused",
+	"m_nBombSite",
+	"m_nWhichBombZone",
+	"m_iConnected",
+	"m_iItemDefinitionIndex",
+	"m_iTeamNum",
+	"m_iszPlayerName",
+	"m_nModelIndex",
+	gameRulesPrefixS2,
+	"m_pWeaponServices",
+	"m_steamID",
+	"m_szTeamname",
+}
+
+// entityPropertyPrefixes returns the prefixes of ParserConfig.EntityPropertyPrefixes along with requiredEntityPropertyPrefixes.
+func entityPropertyPrefixes(prefixes []string) []string {
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	return append(slices.Clone(prefixes), requiredEntityPropertyPrefixes...)
+}
+
 // Parsing errors
 var (
 	// ErrCancelled signals that parsing was cancelled via Parser.Cancel()
@@ -35,6 +72,126 @@ var (
 	ErrInvalidFileType = errors.New("invalid File-Type; expecting HL2DEMO in the first 8 bytes (ErrInvalidFileType)")
 )
 
+// FrameDecodeError signals that a frame or one of its net-messages couldn't be decoded, i.e. that the demo is corrupt.
+//
+// errors.Is(err, ErrUnexpectedEndOfDemo) returns true for FrameDecodeErrors, so they can be handled like incomplete demos.
+// Use errors.As() to get the position of the corrupt data.
+type FrameDecodeError struct {
+	Frame   int               // Frame / demo-tick, see Parser.CurrentFrame()
+	Tick    int               // Ingame tick
+	Command msg.EDemoCommands // Demo command of the frame
+	MsgType int32             // Type of the net-message inside the frame, -1 if the error isn't specific to a net-message
+	Offset  int64             // Offset of the frame in the demo stream in bytes
+	Err     error
+}
+
+func (e *FrameDecodeError) Error() string {
+	return fmt.Sprintf("failed to decode frame %d (ingame tick %d, command %v, net-message type %d) at offset %d: %v",
+		e.Frame, e.Tick, e.Command, e.MsgType, e.Offset, e.Err)
+}
+
+func (e *FrameDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is(err, ErrUnexpectedEndOfDemo) return true.
+func (e *FrameDecodeError) Is(target error) bool {
+	return target == ErrUnexpectedEndOfDemo
+}
+
+// frameInfo is queued before the net-messages of each frame, so message handlers know the position of decode errors.
+type frameInfo struct {
+	offset int64
+	cmd    msg.EDemoCommands
+}
+
+func (p *parser) handleFrameInfo(info frameInfo) {
+	p.currentFrameInfo = info
+}
+
+// broadcastStatsProvider is implemented by cstv.Reader and cstv.Recorder.
+type broadcastStatsProvider interface {
+	Stats() cstv.Stats
+}
+
+// broadcastStatsUpdate is queued when the stats of a CSTV broadcast changed, see queueBroadcastStats().
+type broadcastStatsUpdate struct {
+	prev, cur cstv.Stats
+}
+
+// queueBroadcastStats queues the stats of the CSTV broadcast if they changed since the last frame.
+// They're queued after the tick of the frame, so the lag is calculated from the current ingame tick.
+func (p *parser) queueBroadcastStats() {
+	cur := p.broadcast.Stats()
+
+	if cur == p.broadcastStats {
+		return
+	}
+
+	p.msgQueue <- broadcastStatsUpdate{prev: p.broadcastStats, cur: cur}
+	p.broadcastStats = cur
+}
+
+func (p *parser) handleBroadcastStats(update broadcastStatsUpdate) {
+	cur := update.cur
+
+	if cur.FragmentsReceived != update.prev.FragmentsReceived {
+		p.eventDispatcher.Dispatch(events.BroadcastFragmentReceived{
+			Fragment:          cur.Fragment,
+			FragmentsReceived: cur.FragmentsReceived,
+			BytesReceived:     cur.BytesReceived,
+			Retries:           cur.Retries,
+		})
+	}
+
+	if cur.Live && !update.prev.Live {
+		p.eventDispatcher.Dispatch(events.BroadcastLive{
+			IngameTick: p.gameState.ingameTick,
+			Fragment:   cur.Fragment,
+		})
+	}
+
+	lag := cur.MaxTick - p.gameState.ingameTick
+
+	if lag == p.broadcastLag {
+		return
+	}
+
+	p.broadcastLag = lag
+
+	var lagTime time.Duration
+
+	if cur.Tps > 0 {
+		lagTime = time.Duration(lag) * time.Second / time.Duration(cur.Tps)
+	}
+
+	p.eventDispatcher.Dispatch(events.BroadcastLagChanged{
+		IngameTick: p.gameState.ingameTick,
+		MaxTick:    cur.MaxTick,
+		Lag:        lag,
+		LagTime:    lagTime,
+	})
+}
+
+// newFrameDecodeError returns a FrameDecodeError for the frame that's currently being handled.
+// Must be called from a message handler or after SyncAllQueues() so the frame and tick are up to date.
+func (p *parser) newFrameDecodeError(msgType int32, err error) *FrameDecodeError {
+	return &FrameDecodeError{
+		Frame:   p.currentFrame,
+		Tick:    p.gameState.ingameTick,
+		Command: p.currentFrameInfo.cmd,
+		MsgType: msgType,
+		Offset:  p.currentFrameInfo.offset,
+		Err:     err,
+	}
+}
+
+// setFrameDecodeError is like setError(newFrameDecodeError()) but for use in parseFrame().
+func (p *parser) setFrameDecodeError(msgType int32, err error) {
+	p.msgDispatcher.SyncAllQueues()
+	p.setError(p.newFrameDecodeError(msgType, err))
+}
+
 // parseHeader attempts to parse the header of the demo and returns it.
 // If not done manually this will be called by Parser.ParseNextFrame() or Parser.ParseToEnd().
 //
@@ -63,14 +220,26 @@ func (p *parser) parseHeader() (header, error) {
 
 		if p.ignorePacketEntitiesPanic {
 			warnFunc = func(err error) {
-				p.eventDispatcher.Dispatch(events.ParserWarn{
+				p.warn(events.ParserWarn{
 					Type:    events.WarnTypePacketEntitiesPanic,
 					Message: fmt.Sprintf("encountered PacketEntities panic: %v", err),
 				})
 			}
 		}
 
-		p.stParser = sendtablescs2.NewParser(warnFunc)
+		stParser := sendtablescs2.NewParser(warnFunc)
+		stParser.SetEntityFilter(sendtablescs2.EntityFilter{
+			ServerClasses:    p.config.EntityServerClasses,
+			PropertyPrefixes: entityPropertyPrefixes(p.config.EntityPropertyPrefixes),
+		})
+
+		if metrics := p.config.Metrics; metrics != nil {
+			stParser.SetDecodeMetrics(func(className string, bytes int, duration time.Duration) {
+				metrics.Observe(MetricServerClass, className, bytes, duration)
+			})
+		}
+
+		p.stParser = stParser
 
 		p.stParser.OnEntity(p.onEntity)
 
@@ -108,7 +277,19 @@ func msgQueueSize(ticks int) int {
 // Aborts and returns ErrCancelled if Cancel() is called before the end.
 //
 // See also: ParseNextFrame() for other possible errors.
-func (p *parser) ParseToEnd() (err error) {
+func (p *parser) ParseToEnd() error {
+	return p.ParseToEndContext(context.Background())
+}
+
+/*
+ParseToEndContext is like ParseToEnd() but stops when the context is done.
+
+The context is checked before each frame, all messages of the frames parsed until then are still handled.
+In that case ctx.Err() is returned, wrapped with the frame and ingame tick the parser stopped at.
+Unlike Cancel() this doesn't unregister any handlers, so the state of the parser can be inspected afterwards.
+Parsing can't be resumed after this.
+*/
+func (p *parser) ParseToEndContext(ctx context.Context) (err error) {
 	defer func() {
 		// Make sure all the messages of the demo are handled
 		p.msgDispatcher.SyncAllQueues()
@@ -140,17 +321,42 @@ func (p *parser) ParseToEnd() (err error) {
 		}
 	}
 
+	done := ctx.Done()
+
 	for {
+		select {
+		case <-done:
+			return p.contextError(ctx)
+		default:
+		}
+
 		if !p.parseFrame() {
+			if p.error() != nil && p.recoverFromCorruptFrame() {
+				continue
+			}
+
 			return p.error()
 		}
 
 		if err = p.error(); err != nil {
+			if p.recoverFromCorruptFrame() {
+				err = nil
+
+				continue
+			}
+
 			return
 		}
 	}
 }
 
+// contextError wraps ctx.Err() with the position the parser stopped at.
+func (p *parser) contextError(ctx context.Context) error {
+	p.msgDispatcher.SyncAllQueues()
+
+	return errors.Wrapf(ctx.Err(), "parsing stopped at frame %d, ingame tick %d", p.currentFrame, p.gameState.ingameTick)
+}
+
 func recoverFromUnexpectedEOF(r any) error {
 	if r == nil {
 		return nil
@@ -171,6 +377,8 @@ func recoverFromUnexpectedEOF(r any) error {
 
 // Cancel aborts ParseToEnd() and drains the internal event queues.
 // No further events will be sent to event or message handlers after this.
+//
+// See also: ParseToEndContext() for stopping without unregistering handlers.
 func (p *parser) Cancel() {
 	p.setError(ErrCancelled)
 	p.eventDispatcher.UnregisterAllHandlers()
@@ -188,6 +396,20 @@ May panic if the demo is corrupt in some way.
 See also: ParseToEnd() for parsing the complete demo in one go (faster).
 */
 func (p *parser) ParseNextFrame() (moreFrames bool, err error) {
+	return p.ParseNextFrameContext(context.Background())
+}
+
+/*
+ParseNextFrameContext is like ParseNextFrame() but doesn't parse the frame if the context is already done.
+
+In that case true and ctx.Err() are returned, wrapped with the frame and ingame tick the parser stopped at.
+The parser isn't affected otherwise, so parsing can be resumed with a different context.
+*/
+func (p *parser) ParseNextFrameContext(ctx context.Context) (moreFrames bool, err error) {
+	if ctx.Err() != nil {
+		return true, p.contextError(ctx)
+	}
+
 	defer func() {
 		// Make sure all the messages of the frame are handled
 		p.msgDispatcher.SyncAllQueues()
@@ -212,6 +434,10 @@ func (p *parser) ParseNextFrame() (moreFrames bool, err error) {
 
 	moreFrames = p.parseFrame()
 
+	if p.error() != nil && p.recoverFromCorruptFrame() {
+		return true, nil
+	}
+
 	return moreFrames, p.error()
 }
 
@@ -237,6 +463,7 @@ var demoCommandMsgsCreators = map[msg.EDemoCommands]NetMessageCreator{
 }
 
 func (p *parser) parseFrame() bool {
+	offset := p.streamOffset()
 	cmd := msg.EDemoCommands(p.bitReader.ReadVarInt32())
 
 	msgType := cmd & ^msg.EDemoCommands_DEM_IsCompressed
@@ -279,11 +506,22 @@ func (p *parser) parseFrame() bool {
 		size = p.bitReader.ReadVarInt32()
 	}
 
+	p.msgQueue <- frameInfo{offset: offset, cmd: msgType}
 	p.msgQueue <- ingameTickNumber(int32(tick))
 
+	if p.broadcast != nil {
+		p.queueBroadcastStats()
+	}
+
+	var start time.Time
+
+	if p.config.Metrics != nil {
+		start = time.Now()
+	}
+
 	msgCreator := demoCommandMsgsCreators[msgType]
 	if msgCreator == nil {
-		p.eventDispatcher.Dispatch(events.ParserWarn{
+		p.queueWarn(events.ParserWarn{
 			Message: fmt.Sprintf("skipping unknown demo commands message type with value %d", msgType),
 			Type:    events.WarnTypeUnknownDemoCommandMessageType,
 		})
@@ -292,6 +530,12 @@ func (p *parser) parseFrame() bool {
 		return true
 	}
 
+	if size > maxFrameSize {
+		p.setFrameDecodeError(-1, errors.Wrapf(errFrameTooLarge, "%v has a size of %d bytes", msgType, size))
+
+		return false
+	}
+
 	buf := p.bitReader.ReadBytes(int(size))
 
 	if msgCompressed {
@@ -300,11 +544,14 @@ func (p *parser) parseFrame() bool {
 		buf, err = snappy.Decode(nil, buf)
 		if err != nil {
 			if errors.Is(err, snappy.ErrCorrupt) {
-				p.eventDispatcher.Dispatch(events.ParserWarn{
+				p.queueWarn(events.ParserWarn{
 					Message: "compressed message is corrupt",
+					Type:    events.WarnTypeCorruptCompressedMessage,
 				})
 			} else {
-				panic(err)
+				p.setFrameDecodeError(-1, errors.Wrap(err, "failed to decompress frame"))
+
+				return false
 			}
 		}
 	}
@@ -326,27 +573,41 @@ func (p *parser) parseFrame() bool {
 		default:
 			err := proto.Unmarshal(buf, m)
 			if err != nil {
-				panic(err) // FIXME: avoid panic
+				p.setFrameDecodeError(-1, errors.Wrap(err, "failed to unmarshal demo command"))
+
+				return false
 			}
 		}
 	} else {
 		err := proto.Unmarshal(buf, m)
 		if err != nil {
-			panic(err) // FIXME: avoid panic
+			p.setFrameDecodeError(-1, errors.Wrap(err, "failed to unmarshal demo command"))
+
+			return false
 		}
 	}
 
+	if p.config.Metrics != nil {
+		p.config.Metrics.Observe(MetricDemoCommand, msgType.String(), int(size), time.Since(start))
+	}
+
+	if p.checkpoints != nil {
+		p.checkpoints.record(msgType, tick, buf, m, isCSTVBroadcast)
+	}
+
 	p.msgQueue <- m
 
 	switch m := m.(type) {
 	case *msg.CDemoPacket:
-		p.handleDemoPacket(m)
+		if !p.handleDemoPacket(m) {
+			return false
+		}
 
 	case *msg.CDemoFullPacket:
 		p.msgQueue <- m.StringTable
 
-		if m.Packet.GetData() != nil {
-			p.handleDemoPacket(m.Packet)
+		if m.Packet.GetData() != nil && !p.handleDemoPacket(m.Packet) {
+			return false
 		}
 	}
 
@@ -0,0 +1,870 @@
+package demoinfocs
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	common "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/common"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/demowriter"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/sendtables/sendtablescs2"
+)
+
+// Pseudonym is the replacement for the identity of a player in redacted demos, see Redact().
+type Pseudonym struct {
+	Name      string
+	SteamID64 uint64
+	ClanTag   string
+}
+
+// pseudonymAccountIDBase is above the account IDs that are in use, so pseudonyms don't belong to real accounts.
+// It's below math.MaxInt32 since some messages (e.g. CCSUsrMsg_ServerRankUpdate) contain account IDs as int32.
+const pseudonymAccountIDBase = 2_100_000_000
+
+// PseudonymMapping maps SteamIDs to pseudonyms, it's safe for concurrent use.
+// Using the same mapping for a set of demos (e.g. all scrims of a week) replaces each player with the same pseudonym in all of them.
+type PseudonymMapping struct {
+	mu         sync.Mutex
+	pseudonyms map[uint64]Pseudonym
+	generate   func(steamID64 uint64, n int) Pseudonym
+}
+
+// NewPseudonymMapping returns a mapping that assigns the pseudonyms 'Player 1', 'Player 2' etc. in order of appearance.
+// Their SteamIDs don't belong to real accounts and clan tags are removed.
+func NewPseudonymMapping() *PseudonymMapping {
+	return NewPseudonymMappingFunc(func(_ uint64, n int) Pseudonym {
+		return Pseudonym{
+			Name:      fmt.Sprintf("Player %d", n),
+			SteamID64: common.ConvertSteamID32To64(uint32(pseudonymAccountIDBase + n)), //nolint:gosec
+		}
+	})
+}
+
+// NewPseudonymMappingFunc returns a mapping that calls generate for each new SteamID.
+// n is the number of the player in order of appearance, starting at 1.
+func NewPseudonymMappingFunc(generate func(steamID64 uint64, n int) Pseudonym) *PseudonymMapping {
+	return &PseudonymMapping{
+		pseudonyms: make(map[uint64]Pseudonym),
+		generate:   generate,
+	}
+}
+
+// Set assigns a fixed pseudonym to a player, e.g. to restore a mapping from a previous run (see Pseudonyms()).
+func (m *PseudonymMapping) Set(steamID64 uint64, pseudonym Pseudonym) {
+	m.mu.Lock()
+	m.pseudonyms[steamID64] = pseudonym
+	m.mu.Unlock()
+}
+
+// Pseudonym returns the pseudonym of a player, a new one is generated if the player hasn't been seen before.
+func (m *PseudonymMapping) Pseudonym(steamID64 uint64) Pseudonym {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pseudonym, ok := m.pseudonyms[steamID64]
+	if !ok {
+		pseudonym = m.generate(steamID64, len(m.pseudonyms)+1)
+		m.pseudonyms[steamID64] = pseudonym
+	}
+
+	return pseudonym
+}
+
+// Pseudonyms returns a copy of all assigned pseudonyms by SteamID64.
+func (m *PseudonymMapping) Pseudonyms() map[uint64]Pseudonym {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := make(map[uint64]Pseudonym, len(m.pseudonyms))
+
+	for k, v := range m.pseudonyms {
+		res[k] = v
+	}
+
+	return res
+}
+
+// RedactConfig contains the configuration for Redact().
+type RedactConfig struct {
+	// Pseudonyms used to replace players, use the same mapping for a set of demos to get consistent pseudonyms.
+	// nil uses a new NewPseudonymMapping() for each demo.
+	Pseudonyms *PseudonymMapping
+
+	// KeepChat keeps chat messages (with pseudonyms as sender names), by default they are removed.
+	KeepChat bool
+
+	// KeepVoice keeps voice data (CSVCMsg_VoiceData), by default it's removed.
+	KeepVoice bool
+}
+
+// redactedControllerProps are the properties of CCSPlayerController that identify a player.
+var redactedControllerProps = []string{"m_iszPlayerName", "m_sSanitizedPlayerName", "m_steamID", "m_szClan"}
+
+// redactedTeamProps are the properties of CCSTeam that identify a team, e.g. of a scrim partner.
+var redactedTeamProps = []string{"m_szClanTeamname"}
+
+/*
+Redact writes an anonymised copy of a '.dem' file to w, so it can be shared without revealing the identity of the players.
+
+Players (except bots and GOTV) are replaced with pseudonyms from config.Pseudonyms based on their SteamID:
+
+  - The name and SteamID of the 'userinfo' string table (CMsgPlayerInfo) in CSVCMsg_CreateStringTable, CSVCMsg_UpdateStringTable and CDemoStringTables.
+  - The name, SteamID and clan tag of CCSPlayerController entities in CSVCMsg_PacketEntities.
+  - Names and SteamIDs in game events (keys 'name', 'networkid' and 'xuid'), CCSUsrMsg_ServerRankUpdate and CCSUsrMsg_EndOfMatchAllPlayersData.
+  - Names in chat & text messages (CUserMessageSayText2, CUserMessageTextMsg, CCSUsrMsg_RadioText).
+  - The client name of POV demos in the CDemoFileHeader, so the recording player is still detected.
+
+Chat messages and voice data are removed unless config.KeepChat or config.KeepVoice are set.
+Team names (m_szClanTeamname of CCSTeam entities) are removed as well.
+The entities and string tables are decoded the same way as when parsing, all other data is copied as-is.
+Values of instance baselines are not rewritten.
+
+The result is a valid demo that can be parsed and played like the original, it's written via demowriter.Writer.
+Returns ErrInvalidFileType if the demo isn't a CS2 demo.
+*/
+func Redact(w io.Writer, demo io.Reader, config RedactConfig) error {
+	if config.Pseudonyms == nil {
+		config.Pseudonyms = NewPseudonymMapping()
+	}
+
+	fr, err := newDemoFrameReader(demo)
+	if err != nil {
+		return err
+	}
+
+	header := new(msg.CDemoFileHeader)
+
+	err = fr.readMessage(msg.EDemoCommands_DEM_FileHeader, header)
+	if err != nil {
+		return errors.Wrap(err, "failed to read CDemoFileHeader")
+	}
+
+	r := newRedactor(w, header, config)
+
+	for {
+		h, err := fr.next()
+		if errors.Is(err, io.EOF) {
+			// truncated demo
+			return r.close(nil)
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "failed to read frame")
+		}
+
+		if h.cmd == msg.EDemoCommands_DEM_Stop {
+			return r.close(readFileInfoAfterStop(fr, h))
+		}
+
+		payload, err := fr.payload(h)
+		if err != nil {
+			return err
+		}
+
+		err = r.redactFrame(h, payload)
+		if err != nil {
+			return errors.Wrapf(err, "failed to redact %v at offset %d", h.cmd, h.offset)
+		}
+	}
+}
+
+// readFileInfoAfterStop returns the CDemoFileInfo that follows DEM_Stop or nil if it's missing.
+func readFileInfoAfterStop(fr *frameReader, stop frameHeader) *msg.CDemoFileInfo {
+	err := fr.skip(stop)
+	if err != nil {
+		return nil
+	}
+
+	info := new(msg.CDemoFileInfo)
+
+	err = fr.readMessage(msg.EDemoCommands_DEM_FileInfo, info)
+	if err != nil {
+		return nil
+	}
+
+	return info
+}
+
+// redactor contains the state of a Redact() pass.
+// It decodes entities and string tables the same way as the parser so their values can be rewritten.
+type redactor struct {
+	w      io.Writer
+	header *msg.CDemoFileHeader
+	config RedactConfig
+
+	dw     *demowriter.Writer
+	signon []signonFrame // Frames before DEM_SyncTick, they are written once the POV recorder's pseudonym is known
+
+	stParser      *sendtablescs2.Parser
+	stringTables  []*msg.CSVCMsg_CreateStringTable
+	gameEventKeys map[int32][]string // Game event IDs to key names, from CMsgSource1LegacyGameEventList
+
+	names        map[string]string // Original player names to pseudonyms
+	nameReplacer *strings.Replacer // Replaces all names, nil if names changed since it was built
+}
+
+type signonFrame struct {
+	cmd     msg.EDemoCommands
+	payload []byte
+}
+
+func newRedactor(w io.Writer, header *msg.CDemoFileHeader, config RedactConfig) *redactor {
+	return &redactor{
+		w:        w,
+		header:   header,
+		config:   config,
+		stParser: sendtablescs2.NewParser(nil),
+		names:    make(map[string]string),
+	}
+}
+
+// writeFrame writes a frame to the output, frames before DEM_SyncTick are buffered until the signon data is complete.
+func (r *redactor) writeFrame(cmd msg.EDemoCommands, tick int, payload []byte) error {
+	if r.dw == nil {
+		r.signon = append(r.signon, signonFrame{cmd: cmd, payload: payload})
+
+		return nil
+	}
+
+	return r.dw.WriteRawFrame(cmd, int32(tick), payload) //nolint:gosec
+}
+
+func (r *redactor) writeMessage(cmd msg.EDemoCommands, tick int, m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal %v", cmd)
+	}
+
+	return r.writeFrame(cmd, tick, b)
+}
+
+// writeSignon writes the CDemoFileHeader and all buffered signon frames.
+func (r *redactor) writeSignon() error {
+	header := proto.Clone(r.header).(*msg.CDemoFileHeader)
+
+	// POV demos are recorded by a player, see parser.parseUserInfo()
+	if pseudonym, ok := r.names[header.GetClientName()]; ok {
+		header.ClientName = proto.String(pseudonym)
+	}
+
+	dw, err := demowriter.NewWriter(r.w, header)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range r.signon {
+		err = dw.WriteRawFrame(f.cmd, demowriter.SignonTick, f.payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.dw = dw
+	r.signon = nil
+
+	return nil
+}
+
+func (r *redactor) close(info *msg.CDemoFileInfo) error {
+	if r.dw == nil {
+		err := r.writeSignon()
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.dw.Close(info)
+}
+
+func (r *redactor) redactFrame(h frameHeader, payload []byte) (err error) {
+	// entity and string table decoding panics on corrupt data
+	defer func() {
+		if x := recover(); x != nil {
+			err = errors.Errorf("failed to decode frame: %v", x)
+		}
+	}()
+
+	switch h.cmd {
+	case msg.EDemoCommands_DEM_SendTables:
+		m := new(msg.CDemoSendTables)
+
+		err = proto.Unmarshal(payload, m)
+		if err != nil {
+			return err
+		}
+
+		err = r.stParser.ParsePacket(m.GetData())
+		if err != nil {
+			return errors.Wrap(err, "failed to unmarshal flattened serializer")
+		}
+
+	case msg.EDemoCommands_DEM_ClassInfo:
+		m := new(msg.CDemoClassInfo)
+
+		err = proto.Unmarshal(payload, m)
+		if err != nil {
+			return err
+		}
+
+		err = r.stParser.OnDemoClassInfo(m)
+		if err != nil {
+			return err
+		}
+
+		err = r.stParser.RewriteProperties("CCSPlayerController", redactedControllerProps, r.redactControllerProperty)
+		if err != nil {
+			return err
+		}
+
+		err = r.stParser.RewriteProperties("CCSTeam", redactedTeamProps, redactTeamProperty)
+		if err != nil {
+			return err
+		}
+
+	case msg.EDemoCommands_DEM_Packet, msg.EDemoCommands_DEM_SignonPacket:
+		m := new(msg.CDemoPacket)
+
+		err = proto.Unmarshal(payload, m)
+		if err != nil {
+			return err
+		}
+
+		err = r.redactPacket(m)
+		if err != nil {
+			return err
+		}
+
+		return r.writeMessage(h.cmd, h.tick, m)
+
+	case msg.EDemoCommands_DEM_FullPacket:
+		m := new(msg.CDemoFullPacket)
+
+		err = proto.Unmarshal(payload, m)
+		if err != nil {
+			return err
+		}
+
+		err = r.redactStringTables(m.GetStringTable())
+		if err != nil {
+			return err
+		}
+
+		if m.Packet != nil {
+			err = r.redactPacket(m.Packet)
+			if err != nil {
+				return err
+			}
+		}
+
+		return r.writeMessage(h.cmd, h.tick, m)
+
+	case msg.EDemoCommands_DEM_StringTables:
+		m := new(msg.CDemoStringTables)
+
+		err = proto.Unmarshal(payload, m)
+		if err != nil {
+			return err
+		}
+
+		err = r.redactStringTables(m)
+		if err != nil {
+			return err
+		}
+
+		return r.writeMessage(h.cmd, h.tick, m)
+
+	case msg.EDemoCommands_DEM_SyncTick:
+		err = r.writeFrame(h.cmd, h.tick, payload)
+		if err != nil {
+			return err
+		}
+
+		return r.writeSignon()
+	}
+
+	return r.writeFrame(h.cmd, h.tick, payload)
+}
+
+// redactPacket rewrites the net-messages of a packet.
+// Like in handleDemoPacket() string tables are processed before entities, but the order of the messages is kept.
+func (r *redactor) redactPacket(packet *msg.CDemoPacket) error {
+	msgs, err := decodePacketMessages(packet.GetData())
+	if err != nil {
+		return err
+	}
+
+	order := make([]int, len(msgs))
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortStableFunc(order, func(a, b int) int {
+		return (&pendingMessage{t: msgs[a].Type}).priority() - (&pendingMessage{t: msgs[b].Type}).priority()
+	})
+
+	keep := make([]bool, len(msgs))
+
+	for _, i := range order {
+		msgs[i].Data, keep[i], err = r.redactNetMessage(msgs[i])
+		if err != nil {
+			return errors.Wrapf(err, "failed to redact net-message of type %d", msgs[i].Type)
+		}
+	}
+
+	kept := msgs[:0]
+
+	for i, m := range msgs {
+		if keep[i] {
+			kept = append(kept, m)
+		}
+	}
+
+	packet.Data, err = demowriter.EncodeRawPacketData(kept...)
+
+	return err
+}
+
+// redactMessage unmarshals a net-message into m, redacts it via f and returns the marshalled result.
+func redactMessage[T proto.Message](data []byte, m T, f func(T) error) ([]byte, error) {
+	err := proto.Unmarshal(data, m)
+	if err != nil {
+		return nil, err
+	}
+
+	err = f(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(m)
+}
+
+// redactNetMessage returns the redacted data of a net-message and whether it should be kept.
+//
+//nolint:funlen,cyclop
+func (r *redactor) redactNetMessage(m demowriter.RawNetMessage) ([]byte, bool, error) {
+	var (
+		data = m.Data
+		err  error
+	)
+
+	switch m.Type {
+	case int32(msg.SVC_Messages_svc_ServerInfo):
+		_, err = redactMessage(m.Data, new(msg.CSVCMsg_ServerInfo), r.stParser.OnServerInfo)
+
+	case int32(msg.SVC_Messages_svc_ClearAllStringTables):
+		r.stringTables = nil
+
+	case int32(msg.SVC_Messages_svc_CreateStringTable):
+		data, err = redactMessage(m.Data, new(msg.CSVCMsg_CreateStringTable), r.redactCreateStringTable)
+
+	case int32(msg.SVC_Messages_svc_UpdateStringTable):
+		data, err = redactMessage(m.Data, new(msg.CSVCMsg_UpdateStringTable), r.redactUpdateStringTable)
+
+	case int32(msg.SVC_Messages_svc_PacketEntities):
+		data, err = redactMessage(m.Data, new(msg.CSVCMsg_PacketEntities), r.redactPacketEntities)
+
+	case int32(msg.SVC_Messages_svc_VoiceData):
+		if !r.config.KeepVoice {
+			return nil, false, nil
+		}
+
+		data, err = redactMessage(m.Data, new(msg.CSVCMsg_VoiceData), func(voice *msg.CSVCMsg_VoiceData) error {
+			if voice.GetXuid() != 0 {
+				voice.Xuid = proto.Uint64(r.config.Pseudonyms.Pseudonym(voice.GetXuid()).SteamID64)
+			}
+
+			return nil
+		})
+
+	case int32(msg.EBaseUserMessages_UM_SayText):
+		if !r.config.KeepChat {
+			return nil, false, nil
+		}
+
+		data, err = redactMessage(m.Data, new(msg.CUserMessageSayText), func(say *msg.CUserMessageSayText) error {
+			say.Text = r.replaceNames(say.Text)
+
+			return nil
+		})
+
+	case int32(msg.EBaseUserMessages_UM_SayText2):
+		if !r.config.KeepChat {
+			return nil, false, nil
+		}
+
+		data, err = redactMessage(m.Data, new(msg.CUserMessageSayText2), func(say *msg.CUserMessageSayText2) error {
+			say.Param1 = r.replaceNames(say.Param1)
+			say.Param2 = r.replaceNames(say.Param2)
+			say.Param3 = r.replaceNames(say.Param3)
+			say.Param4 = r.replaceNames(say.Param4)
+
+			return nil
+		})
+
+	case int32(msg.EBaseUserMessages_UM_TextMsg):
+		data, err = redactMessage(m.Data, new(msg.CUserMessageTextMsg), func(text *msg.CUserMessageTextMsg) error {
+			r.replaceNamesInSlice(text.Param)
+
+			return nil
+		})
+
+	case int32(msg.ECstrike15UserMessages_CS_UM_RadioText):
+		data, err = redactMessage(m.Data, new(msg.CCSUsrMsg_RadioText), func(text *msg.CCSUsrMsg_RadioText) error {
+			r.replaceNamesInSlice(text.Params)
+
+			return nil
+		})
+
+	case int32(msg.ECstrike15UserMessages_CS_UM_ServerRankUpdate):
+		data, err = redactMessage(m.Data, new(msg.CCSUsrMsg_ServerRankUpdate), r.redactServerRankUpdate)
+
+	case int32(msg.ECstrike15UserMessages_CS_UM_EndOfMatchAllPlayersData):
+		data, err = redactMessage(m.Data, new(msg.CCSUsrMsg_EndOfMatchAllPlayersData), r.redactEndOfMatchAllPlayersData)
+
+	case int32(msg.EBaseGameEvents_GE_Source1LegacyGameEventList):
+		_, err = redactMessage(m.Data, new(msg.CMsgSource1LegacyGameEventList), func(list *msg.CMsgSource1LegacyGameEventList) error {
+			r.handleGameEventList(list)
+
+			return nil
+		})
+
+	case int32(msg.EBaseGameEvents_GE_Source1LegacyGameEvent):
+		data, err = redactMessage(m.Data, new(msg.CMsgSource1LegacyGameEvent), r.redactGameEvent)
+	}
+
+	return data, true, err
+}
+
+// addName registers the pseudonym of a player name for replacing it in texts.
+func (r *redactor) addName(name, pseudonym string) {
+	if name == "" || r.names[name] == pseudonym {
+		return
+	}
+
+	r.names[name] = pseudonym
+	r.nameReplacer = nil
+}
+
+// replaceNames replaces all known player names in s with their pseudonyms.
+func (r *redactor) replaceNames(s *string) *string {
+	if s == nil || len(r.names) == 0 {
+		return s
+	}
+
+	if r.nameReplacer == nil {
+		names := slices.Collect(maps.Keys(r.names))
+
+		// longer names first so names that contain other names are replaced as a whole
+		slices.SortFunc(names, func(a, b string) int {
+			return len(b) - len(a)
+		})
+
+		oldnew := make([]string, 0, 2*len(names))
+
+		for _, name := range names {
+			oldnew = append(oldnew, name, r.names[name])
+		}
+
+		r.nameReplacer = strings.NewReplacer(oldnew...)
+	}
+
+	return proto.String(r.nameReplacer.Replace(*s))
+}
+
+func (r *redactor) replaceNamesInSlice(s []string) {
+	for i := range s {
+		s[i] = *r.replaceNames(&s[i])
+	}
+}
+
+// redactPlayerInfo redacts a CMsgPlayerInfo of the userinfo string table, bots and GOTV are kept.
+func (r *redactor) redactPlayerInfo(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	return redactMessage(data, new(msg.CMsgPlayerInfo), func(info *msg.CMsgPlayerInfo) error {
+		if info.GetXuid() == 0 || info.GetFakeplayer() || info.GetIshltv() {
+			return nil
+		}
+
+		pseudonym := r.config.Pseudonyms.Pseudonym(info.GetXuid())
+		r.addName(info.GetName(), pseudonym.Name)
+
+		info.Name = proto.String(pseudonym.Name)
+		info.Xuid = proto.Uint64(pseudonym.SteamID64)
+
+		if info.Steamid != nil {
+			info.Steamid = proto.Uint64(pseudonym.SteamID64)
+		}
+
+		return nil
+	})
+}
+
+// setInstanceBaseline passes an item of the instancebaseline string table to the entity decoder, see processStringTable().
+func (r *redactor) setInstanceBaseline(key string, data []byte) error {
+	if key == "" || instanceBaselineKeyRegex.MatchString(key) {
+		return nil
+	}
+
+	classID, err := strconv.Atoi(key)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse serverClassID")
+	}
+
+	r.stParser.SetInstanceBaseline(classID, data)
+
+	return nil
+}
+
+// redactStringTableItems decodes string table data, redacts userinfo items and returns the re-encoded data.
+// Returns nil if the data doesn't need to be rewritten.
+func (r *redactor) redactStringTableItems(tab *msg.CSVCMsg_CreateStringTable, data []byte, numEntries int32) ([]byte, error) {
+	if tab.GetName() != stNameUserInfo && tab.GetName() != stNameInstanceBaseline {
+		return nil, nil
+	}
+
+	items, err := decodeStringTable(data, numEntries, tab.GetName(), tab.GetUserDataFixedSize(), tab.GetUserDataSize(), tab.GetFlags(), tab.GetUsingVarintBitcounts())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if tab.GetName() == stNameInstanceBaseline {
+			err = r.setInstanceBaseline(item.Key, item.Value)
+		} else {
+			item.Value, err = r.redactPlayerInfo(item.Value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tab.GetName() == stNameInstanceBaseline {
+		return nil, nil
+	}
+
+	return encodeStringTable(items, tab.GetUserDataFixedSize(), tab.GetUserDataSize(), tab.GetFlags(), tab.GetUsingVarintBitcounts()), nil
+}
+
+func (r *redactor) redactCreateStringTable(tab *msg.CSVCMsg_CreateStringTable) error {
+	data := tab.GetStringData()
+
+	if tab.GetDataCompressed() {
+		var err error
+
+		data, err = snappy.Decode(nil, data)
+		if err != nil {
+			return errors.Wrapf(err, "failed to decompress string table %q", tab.GetName())
+		}
+	}
+
+	r.stringTables = append(r.stringTables, tab)
+
+	redacted, err := r.redactStringTableItems(tab, data, tab.GetNumEntries())
+	if err != nil || redacted == nil {
+		return err
+	}
+
+	tab.StringData = redacted
+	tab.DataCompressed = proto.Bool(false)
+	tab.UncompressedSize = proto.Int32(int32(len(redacted))) //nolint:gosec
+
+	return nil
+}
+
+func (r *redactor) redactUpdateStringTable(tab *msg.CSVCMsg_UpdateStringTable) error {
+	if len(r.stringTables) <= int(tab.GetTableId()) {
+		return nil // same as handleUpdateStringTable()
+	}
+
+	redacted, err := r.redactStringTableItems(r.stringTables[tab.GetTableId()], tab.GetStringData(), tab.GetNumChangedEntries())
+	if err != nil || redacted == nil {
+		return err
+	}
+
+	tab.StringData = redacted
+
+	return nil
+}
+
+// redactStringTables redacts the string table snapshots of DEM_StringTables and DEM_FullPacket, see handleStringTables().
+func (r *redactor) redactStringTables(tabs *msg.CDemoStringTables) error {
+	for _, tab := range tabs.GetTables() {
+		for _, item := range tab.GetItems() {
+			var err error
+
+			switch tab.GetTableName() {
+			case stNameInstanceBaseline:
+				err = r.setInstanceBaseline(item.GetStr(), item.GetData())
+
+			case stNameUserInfo:
+				item.Data, err = r.redactPlayerInfo(item.GetData())
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *redactor) redactPacketEntities(m *msg.CSVCMsg_PacketEntities) error {
+	// full updates (e.g. from DEM_FullPacket) recreate all entities, see Parser.SeekToTick()
+	if !m.GetLegacyIsDelta() {
+		r.stParser.ResetEntities()
+	}
+
+	return r.stParser.OnPacketEntities(m)
+}
+
+// redactControllerProperty is the sendtablescs2.PropertyRewriter for redactedControllerProps.
+func (r *redactor) redactControllerProperty(controller *sendtablescs2.Entity, prop string, value any) (any, bool) {
+	steamID, _ := controller.GetUint64("m_steamID")
+	if steamID == 0 {
+		return nil, false // bot
+	}
+
+	pseudonym := r.config.Pseudonyms.Pseudonym(steamID)
+
+	switch prop {
+	case "m_steamID":
+		return pseudonym.SteamID64, true
+
+	case "m_szClan":
+		return pseudonym.ClanTag, true
+	}
+
+	if name, ok := value.(string); ok {
+		r.addName(name, pseudonym.Name)
+	}
+
+	return pseudonym.Name, true
+}
+
+// redactTeamProperty is the sendtablescs2.PropertyRewriter for redactedTeamProps.
+func redactTeamProperty(_ *sendtablescs2.Entity, _ string, value any) (any, bool) {
+	return "", value != ""
+}
+
+func (r *redactor) redactServerRankUpdate(m *msg.CCSUsrMsg_ServerRankUpdate) error {
+	for _, update := range m.GetRankUpdate() {
+		if update.GetAccountId() == 0 {
+			continue
+		}
+
+		pseudonym := r.config.Pseudonyms.Pseudonym(common.ConvertSteamID32To64(uint32(update.GetAccountId()))) //nolint:gosec
+		update.AccountId = proto.Int32(int32(common.ConvertSteamID64To32(pseudonym.SteamID64)))                //nolint:gosec
+	}
+
+	return nil
+}
+
+func (r *redactor) redactEndOfMatchAllPlayersData(m *msg.CCSUsrMsg_EndOfMatchAllPlayersData) error {
+	for _, player := range m.GetAllplayerdata() {
+		if player.GetIsbot() || player.GetXuid() == 0 {
+			continue
+		}
+
+		pseudonym := r.config.Pseudonyms.Pseudonym(player.GetXuid())
+		r.addName(player.GetName(), pseudonym.Name)
+
+		player.Xuid = proto.Uint64(pseudonym.SteamID64)
+		player.Name = proto.String(pseudonym.Name)
+	}
+
+	return nil
+}
+
+func (r *redactor) handleGameEventList(list *msg.CMsgSource1LegacyGameEventList) {
+	r.gameEventKeys = make(map[int32][]string)
+
+	for _, d := range list.GetDescriptors() {
+		keys := make([]string, 0, len(d.GetKeys()))
+
+		for _, k := range d.GetKeys() {
+			keys = append(keys, k.GetName())
+		}
+
+		r.gameEventKeys[d.GetEventid()] = keys
+	}
+}
+
+// redactedGameEventNameKeys are game event keys that contain player names.
+var redactedGameEventNameKeys = []string{"name", "oldname", "newname"}
+
+// redactGameEvent replaces the name and SteamID of the player of a game event (e.g. 'player_connect').
+func (r *redactor) redactGameEvent(ge *msg.CMsgSource1LegacyGameEvent) error {
+	if r.gameEventKeys == nil {
+		// same fallback as handleGameEvent() for demos without game event list
+		bin, err := getGameEventListBinForProtocol(int(r.header.GetPatchVersion()))
+		if err != nil {
+			return errors.Wrap(err, "failed to load fallback game event list")
+		}
+
+		list := new(msg.CMsgSource1LegacyGameEventList)
+
+		err = proto.Unmarshal(bin, list)
+		if err != nil {
+			return errors.Wrap(err, "failed to unmarshal fallback game event list")
+		}
+
+		r.handleGameEventList(list)
+	}
+
+	data := make(map[string]*msg.CMsgSource1LegacyGameEventKeyT)
+
+	for i, key := range r.gameEventKeys[ge.GetEventid()] {
+		if i < len(ge.GetKeys()) {
+			data[key] = ge.GetKeys()[i]
+		}
+	}
+
+	steamID := data["xuid"].GetValUint64()
+	if steamID == 0 && data["networkid"] != nil {
+		steamID, _ = guidToSteamID64(data["networkid"].GetValString())
+	}
+
+	if steamID != 0 && !data["bot"].GetValBool() {
+		pseudonym := r.config.Pseudonyms.Pseudonym(steamID)
+
+		if key := data["name"]; key != nil {
+			r.addName(key.GetValString(), pseudonym.Name)
+		}
+
+		if key := data["xuid"]; key.GetValUint64() != 0 {
+			key.ValUint64 = proto.Uint64(pseudonym.SteamID64)
+		}
+
+		if key := data["networkid"]; key.GetValString() != "" {
+			key.ValString = proto.String(fmt.Sprintf("[U:1:%d]", common.ConvertSteamID64To32(pseudonym.SteamID64)))
+		}
+	}
+
+	for _, name := range redactedGameEventNameKeys {
+		key := data[name]
+		if key == nil {
+			continue
+		}
+
+		if pseudonym, ok := r.names[key.GetValString()]; ok {
+			key.ValString = proto.String(pseudonym)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/demowriter"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+const testRedactSteamID = 76561198000000001
+
+func testRedactUserInfo(t *testing.T) demowriter.NetMessage {
+	t.Helper()
+
+	player, err := proto.Marshal(&msg.CMsgPlayerInfo{
+		Name:   proto.String("alice"),
+		Xuid:   proto.Uint64(testRedactSteamID),
+		Userid: proto.Int32(1),
+	})
+	assert.NoError(t, err)
+
+	bot, err := proto.Marshal(&msg.CMsgPlayerInfo{
+		Name:       proto.String("BOT Gabe"),
+		Userid:     proto.Int32(2),
+		Fakeplayer: proto.Bool(true),
+	})
+	assert.NoError(t, err)
+
+	items := []*stringTableItem{{Index: 0, Key: "0", Value: player}, {Index: 1, Key: "1", Value: bot}}
+
+	return demowriter.NetMessage{
+		Type: int32(msg.SVC_Messages_svc_CreateStringTable),
+		Msg: &msg.CSVCMsg_CreateStringTable{
+			Name:                 proto.String(stNameUserInfo),
+			NumEntries:           proto.Int32(int32(len(items))),
+			StringData:           encodeStringTable(items, false, 0, 0, true),
+			UsingVarintBitcounts: proto.Bool(true),
+		},
+	}
+}
+
+func testRedactDemoData(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w, err := demowriter.NewWriter(&buf, &msg.CDemoFileHeader{
+		DemoFileStamp: proto.String("PBDEMS_2"),
+		MapName:       proto.String("de_test"),
+		ClientName:    proto.String("alice"),
+	})
+	assert.NoError(t, err)
+
+	err = w.WritePacket(demowriter.SignonTick, testRedactUserInfo(t))
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.WriteSyncTick())
+
+	err = w.WritePacket(1,
+		demowriter.NetMessage{
+			Type: int32(msg.EBaseUserMessages_UM_SayText2),
+			Msg: &msg.CUserMessageSayText2{
+				Entityindex: proto.Int32(1),
+				Chat:        proto.Bool(true),
+				Messagename: proto.String("Cstrike_Chat_All"),
+				Param1:      proto.String("alice"),
+				Param2:      proto.String("gg alice"),
+			},
+		},
+		demowriter.NetMessage{
+			Type: int32(msg.SVC_Messages_svc_VoiceData),
+			Msg:  &msg.CSVCMsg_VoiceData{Client: proto.Int32(0), Xuid: proto.Uint64(testRedactSteamID)},
+		},
+		demowriter.NetMessage{
+			Type: int32(msg.EBaseUserMessages_UM_TextMsg),
+			Msg:  &msg.CUserMessageTextMsg{Param: []string{"#Player_Cash_Award", "alice"}},
+		},
+	)
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.Close(nil))
+
+	return buf.Bytes()
+}
+
+// testRedactedMessages returns all net-messages of a demo by type.
+func testRedactedMessages(t *testing.T, demo []byte) map[int32][][]byte {
+	t.Helper()
+
+	fr, err := newDemoFrameReader(bytes.NewReader(demo))
+	assert.NoError(t, err)
+
+	res := make(map[int32][][]byte)
+
+	for {
+		h, err := fr.next()
+		if !assert.NoError(t, err) || h.cmd == msg.EDemoCommands_DEM_Stop {
+			return res
+		}
+
+		payload, err := fr.payload(h)
+		assert.NoError(t, err)
+
+		switch h.cmd {
+		case msg.EDemoCommands_DEM_FileHeader:
+			res[-1] = append(res[-1], payload)
+
+		case msg.EDemoCommands_DEM_Packet, msg.EDemoCommands_DEM_SignonPacket:
+			packet := new(msg.CDemoPacket)
+			assert.NoError(t, proto.Unmarshal(payload, packet))
+
+			msgs, err := decodePacketMessages(packet.GetData())
+			assert.NoError(t, err)
+
+			for _, m := range msgs {
+				res[m.Type] = append(res[m.Type], m.Data)
+			}
+		}
+	}
+}
+
+func testRedactedPlayerInfos(t *testing.T, data []byte) []*msg.CMsgPlayerInfo {
+	t.Helper()
+
+	tab := new(msg.CSVCMsg_CreateStringTable)
+	assert.NoError(t, proto.Unmarshal(data, tab))
+
+	items, err := decodeStringTable(tab.GetStringData(), tab.GetNumEntries(), tab.GetName(), false, 0, tab.GetFlags(), tab.GetUsingVarintBitcounts())
+	assert.NoError(t, err)
+
+	var res []*msg.CMsgPlayerInfo
+
+	for _, item := range items {
+		info := new(msg.CMsgPlayerInfo)
+		assert.NoError(t, proto.Unmarshal(item.Value, info))
+
+		res = append(res, info)
+	}
+
+	return res
+}
+
+func TestRedact(t *testing.T) {
+	pseudonyms := NewPseudonymMapping()
+	pseudonyms.Set(1, Pseudonym{Name: "Player 1"}) // the real player gets 'Player 2'
+
+	var redacted bytes.Buffer
+
+	err := Redact(&redacted, bytes.NewReader(testRedactDemoData(t)), RedactConfig{Pseudonyms: pseudonyms})
+	assert.NoError(t, err)
+
+	expected := pseudonyms.Pseudonym(testRedactSteamID)
+	assert.Equal(t, "Player 2", expected.Name)
+	assert.NotEqual(t, uint64(testRedactSteamID), expected.SteamID64)
+
+	msgs := testRedactedMessages(t, redacted.Bytes())
+
+	header := new(msg.CDemoFileHeader)
+	assert.NoError(t, proto.Unmarshal(msgs[-1][0], header))
+	assert.Equal(t, "Player 2", header.GetClientName())
+
+	if assert.Len(t, msgs[int32(msg.SVC_Messages_svc_CreateStringTable)], 1) {
+		infos := testRedactedPlayerInfos(t, msgs[int32(msg.SVC_Messages_svc_CreateStringTable)][0])
+
+		if assert.Len(t, infos, 2) {
+			assert.Equal(t, "Player 2", infos[0].GetName())
+			assert.Equal(t, expected.SteamID64, infos[0].GetXuid())
+			assert.Equal(t, int32(1), infos[0].GetUserid())
+			assert.Equal(t, "BOT Gabe", infos[1].GetName())
+		}
+	}
+
+	assert.Empty(t, msgs[int32(msg.EBaseUserMessages_UM_SayText2)])
+	assert.Empty(t, msgs[int32(msg.SVC_Messages_svc_VoiceData)])
+
+	if assert.Len(t, msgs[int32(msg.EBaseUserMessages_UM_TextMsg)], 1) {
+		text := new(msg.CUserMessageTextMsg)
+		assert.NoError(t, proto.Unmarshal(msgs[int32(msg.EBaseUserMessages_UM_TextMsg)][0], text))
+		assert.Equal(t, []string{"#Player_Cash_Award", "Player 2"}, text.GetParam())
+	}
+
+	// the result can be parsed
+	p := NewParser(bytes.NewReader(redacted.Bytes()))
+
+	assert.NoError(t, p.ParseToEnd())
+	assert.Equal(t, 0, p.(*parser).recordingPlayerSlot) // POV recorder is still detected
+}
+
+func TestRedact_KeepChatAndVoice(t *testing.T) {
+	var redacted bytes.Buffer
+
+	err := Redact(&redacted, bytes.NewReader(testRedactDemoData(t)), RedactConfig{KeepChat: true, KeepVoice: true})
+	assert.NoError(t, err)
+
+	msgs := testRedactedMessages(t, redacted.Bytes())
+
+	if assert.Len(t, msgs[int32(msg.EBaseUserMessages_UM_SayText2)], 1) {
+		say := new(msg.CUserMessageSayText2)
+		assert.NoError(t, proto.Unmarshal(msgs[int32(msg.EBaseUserMessages_UM_SayText2)][0], say))
+		assert.Equal(t, "Player 1", say.GetParam1())
+		assert.Equal(t, "gg Player 1", say.GetParam2())
+	}
+
+	if assert.Len(t, msgs[int32(msg.SVC_Messages_svc_VoiceData)], 1) {
+		voice := new(msg.CSVCMsg_VoiceData)
+		assert.NoError(t, proto.Unmarshal(msgs[int32(msg.SVC_Messages_svc_VoiceData)][0], voice))
+		assert.NotEqual(t, uint64(testRedactSteamID), voice.GetXuid())
+	}
+}
+
+func TestRedact_FileInfo(t *testing.T) {
+	demo := newTestDemoFileBuilder().signon().packet(1).packet(2).bytesWithFileInfo(&msg.CDemoFileInfo{
+		PlaybackTicks: proto.Int32(2),
+		GameInfo: &msg.CGameInfo{
+			Cs: &msg.CGameInfo_CCSGameInfo{RoundStartTicks: []int32{2, 3}},
+		},
+	})
+
+	redacted := new(testWriteSeeker)
+
+	err := Redact(redacted, bytes.NewReader(demo), RedactConfig{})
+	assert.NoError(t, err)
+
+	info, err := ReadDemoInfo(bytes.NewReader(redacted.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, info.PlaybackTicks)
+	assert.Equal(t, []int{2, 3}, info.RoundStartTicks)
+}
+
+func TestRedact_InvalidFileType(t *testing.T) {
+	err := Redact(new(bytes.Buffer), bytes.NewReader([]byte("HL2DEMO\x00 some data")), RedactConfig{})
+	assert.ErrorIs(t, err, ErrInvalidFileType)
+}
+
+func TestRedactTeamProperty(t *testing.T) {
+	value, ok := redactTeamProperty(nil, "m_szClanTeamname", "Team Alice")
+	assert.True(t, ok)
+	assert.Equal(t, "", value)
+
+	_, ok = redactTeamProperty(nil, "m_szClanTeamname", "")
+	assert.False(t, ok)
+}
+
+func TestPseudonymMapping(t *testing.T) {
+	m := NewPseudonymMapping()
+
+	a := m.Pseudonym(1)
+	b := m.Pseudonym(2)
+
+	assert.Equal(t, "Player 1", a.Name)
+	assert.Equal(t, "Player 2", b.Name)
+	assert.Equal(t, a, m.Pseudonym(1))
+	assert.NotEqual(t, a.SteamID64, b.SteamID64)
+	assert.Equal(t, map[uint64]Pseudonym{1: a, 2: b}, m.Pseudonyms())
+}
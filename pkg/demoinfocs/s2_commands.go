@@ -298,11 +298,13 @@ func (m *pendingMessage) priority() int {
 	return 0
 }
 
-func (p *parser) handleDemoPacket(pack *msg.CDemoPacket) {
+// handleDemoPacket queues the net-messages of a packet.
+// Returns false if a net-message couldn't be decoded, see FrameDecodeError.
+func (p *parser) handleDemoPacket(pack *msg.CDemoPacket) bool {
 	b := pack.GetData()
 
 	if len(b) == 0 {
-		return
+		return true
 	}
 
 	r := bitread.NewSmallBitReader(bytes.NewReader(b))
@@ -349,23 +351,40 @@ func (p *parser) handleDemoPacket(pack *msg.CDemoPacket) {
 		}
 
 		if msgCreator == nil {
-			p.msgDispatcher.Dispatch(events.ParserWarn{
-				Message: fmt.Sprintf("unknown message type: %d", m.t),
-				Type:    events.WarnTypeUnknownProtobufMessage,
-			})
+			p.msgQueue <- queuedWarn{
+				warn: events.ParserWarn{
+					Message: fmt.Sprintf("unknown message type: %d", m.t),
+					Type:    events.WarnTypeUnknownProtobufMessage,
+				},
+				netMessage: true,
+			}
 
 			continue
 		}
 
+		var start time.Time
+
+		if p.config.Metrics != nil {
+			start = time.Now()
+		}
+
 		msg := msgCreator()
 
 		err := proto.Unmarshal(m.buf, msg)
 		if err != nil {
-			panic(err) // FIXME: avoid panic
+			p.setFrameDecodeError(m.t, errors.Wrap(err, "failed to unmarshal net-message"))
+
+			return false
+		}
+
+		if p.config.Metrics != nil {
+			p.config.Metrics.Observe(MetricNetMessage, string(msg.ProtoReflect().Descriptor().Name()), len(m.buf), time.Since(start))
 		}
 
 		p.msgQueue <- msg
 	}
+
+	return true
 }
 
 func (p *parser) handleFullPacket(msg *msg.CDemoFullPacket) {
@@ -385,6 +404,10 @@ func (p *parser) handleFileInfo(msg *msg.CDemoFileInfo) {
 //go:embed event-list-dump/*.bin
 var eventListFolder embed.FS
 
+// latestGameEventListProtocol is the network protocol of the newest game-event list in event-list-dump.
+// Demos of newer protocols that are missing their game-event list may not be parsed correctly.
+const latestGameEventListProtocol = 14113
+
 func getGameEventListBinForProtocol(networkProtocol int) ([]byte, error) {
 	switch {
 	case networkProtocol < 13992:
@@ -408,6 +431,7 @@ func getGameEventListBinForProtocol(networkProtocol int) ([]byte, error) {
 }
 
 func (p *parser) handleDemoFileHeader(msg *msg.CDemoFileHeader) {
+	p.demoFileHeader = msg
 	p.header.ClientName = msg.GetClientName()
 	p.header.ServerName = msg.GetServerName()
 	p.header.GameDirectory = msg.GetGameDirectory()
@@ -0,0 +1,398 @@
+package demoinfocs
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	dp "github.com/markus-wa/godispatch"
+	"github.com/pkg/errors"
+
+	bit "github.com/markus-wa/demoinfocs-golang/v5/internal/bitread"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/common"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+// Seeking errors
+var (
+	// ErrSeekNotSupported signals that the demo stream can't be seeked,
+	// either because it doesn't implement io.ReadSeeker or because it's a CSTV broadcast.
+	ErrSeekNotSupported = errors.New("seeking requires a '.dem' file stream that implements io.ReadSeeker (ErrSeekNotSupported)")
+
+	// ErrSeekOutOfRange signals that the requested tick or frame lies beyond the end of the demo.
+	ErrSeekOutOfRange = errors.New("seek target is beyond the end of the demo (ErrSeekOutOfRange)")
+)
+
+// keyframe is a position in the demo from which the parser state can be restored.
+type keyframe struct {
+	offset int64 // Offset of the frame in the demo file in bytes
+	frame  int
+	tick   int
+	full   bool // true for CDemoFullPacket frames, false for the end of the signon data
+}
+
+// keyframeIndex contains all keyframes of a demo.
+type keyframeIndex struct {
+	signon    keyframe   // First frame after DEM_SyncTick, everything before is signon data
+	keyframes []keyframe // CDemoFullPacket frames, ordered by offset
+	lastFrame int
+	lastTick  int
+}
+
+// scanKeyframes does a pass over all frame headers of a demo file and collects all keyframes.
+// Frame payloads are skipped, so this is cheap compared to parsing the demo or BuildDemoIndex().
+//
+// Truncated demos are indexed up to the last complete frame header.
+func scanKeyframes(r io.ReadSeeker) (*keyframeIndex, error) {
+	_, err := r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to seek to start of demo")
+	}
+
+	idx, err := buildDemoIndex(r, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return idx.keyframeIndex(), nil
+}
+
+// offsetReadSeeker reports Seek() positions relative to base.
+// This keeps BitReader positions consistent after re-opening the demo stream at an offset.
+type offsetReadSeeker struct {
+	io.ReadSeeker
+	base int64
+}
+
+func (r offsetReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		offset += r.base
+	}
+
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+
+	return pos - r.base, err
+}
+
+// Close closes the underlying stream if it's an io.Closer, same as BitReader.Close() would without the wrapper.
+func (r offsetReadSeeker) Close() error {
+	if c, ok := r.ReadSeeker.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// streamOffset returns the current position in the demo stream in bytes.
+func (p *parser) streamOffset() int64 {
+	return p.streamBase + int64(p.bitReader.ActualPosition()>>3)
+}
+
+// repositionStream moves the demo stream to the given offset and re-opens the BitReader there.
+// The old BitReader is not closed as that would close the underlying stream.
+func (p *parser) repositionStream(rs io.ReadSeeker, offset int64) error {
+	_, err := rs.Seek(offset, io.SeekStart)
+	if err != nil {
+		return errors.Wrap(err, "failed to seek demo stream")
+	}
+
+	p.bitReader = bit.NewLargeBitReader(offsetReadSeeker{ReadSeeker: rs, base: offset})
+	p.streamBase = offset
+
+	return nil
+}
+
+func (p *parser) handleSyncTick(*msg.CDemoSyncTick) {
+	// Player infos from the signon data, used when restoring from the signon keyframe
+	p.signonRawPlayers = maps.Clone(p.rawPlayers)
+}
+
+/*
+SeekToTick moves the parser to the given ingame tick.
+
+The entities, string tables and game-state are restored from the closest preceding CDemoFullPacket (keyframe),
+afterwards the frames between the keyframe and the target are replayed.
+If the target lies ahead of the current position and there is no keyframe in-between, the parser simply parses forward.
+After seeking GameState().IngameTick() is the first tick >= the requested tick.
+
+Game events are not dispatched for replayed frames. Net-message handlers and entity handlers
+(e.g. registered via ServerClass.OnEntityCreated()) are still called as the state is rebuilt.
+
+Returns ErrSeekNotSupported if the demo stream doesn't implement io.ReadSeeker (e.g. CSTV broadcasts)
+and ErrSeekOutOfRange if the tick lies beyond the end of the demo.
+Must not be called after ParseToEnd() or after ParseNextFrame() returned false.
+*/
+func (p *parser) SeekToTick(tick int) error {
+	return p.seek(
+		func(kf keyframe) bool { return kf.tick <= tick },
+		func() bool { return p.gameState.ingameTick >= tick },
+		func(idx *keyframeIndex) bool { return tick > idx.lastTick },
+	)
+}
+
+// SeekToFrame moves the parser to the given frame / demo-tick (not ingame tick).
+// After seeking CurrentFrame() returns the requested frame (or the first frame after the signon data if the requested frame is part of it).
+//
+// See SeekToTick() for details and possible errors.
+func (p *parser) SeekToFrame(frame int) error {
+	return p.seek(
+		func(kf keyframe) bool { return kf.frame < frame },
+		func() bool { return p.currentFrame >= frame },
+		func(idx *keyframeIndex) bool { return frame > idx.lastFrame },
+	)
+}
+
+func (p *parser) seek(usable func(keyframe) bool, reached func() bool, outOfRange func(*keyframeIndex) bool) (err error) {
+	rs, ok := p.demoStream.(io.ReadSeeker)
+	if !ok || p.config.Format != DemoFormatFile {
+		return ErrSeekNotSupported
+	}
+
+	defer func() {
+		if err == nil {
+			err = recoverFromUnexpectedEOF(recover())
+		}
+	}()
+
+	if p.header == nil {
+		_, err = p.parseHeader()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = p.ensureKeyframeIndex(rs)
+	if err != nil {
+		return err
+	}
+
+	if outOfRange(p.keyframeIndex) {
+		return ErrSeekOutOfRange
+	}
+
+	err = p.parseSignon()
+	if err != nil {
+		return err
+	}
+
+	kf := p.keyframeIndex.signon
+
+	for _, k := range p.keyframeIndex.keyframes {
+		if !usable(k) {
+			break
+		}
+
+		kf = k
+	}
+
+	restore := reached() || p.streamOffset() < kf.offset
+	if restore {
+		err = p.restoreKeyframe(rs, kf)
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.replayUntil(reached, restore)
+}
+
+// parseSignon parses the signon data (send-tables, class-info, string-tables etc.) if that hasn't happened yet.
+// The signon data isn't repeated in keyframes, so it's required before restoring one.
+func (p *parser) parseSignon() error {
+	for p.streamOffset() < p.keyframeIndex.signon.offset {
+		if !p.parseFrame() {
+			return ErrUnexpectedEndOfDemo
+		}
+	}
+
+	p.msgDispatcher.SyncAllQueues()
+
+	return p.error()
+}
+
+// ensureKeyframeIndex loads the keyframes from ParserConfig.DemoIndex or scans the demo for them if that hasn't happened yet.
+func (p *parser) ensureKeyframeIndex(rs io.ReadSeeker) error {
+	if p.keyframeIndex != nil {
+		return nil
+	}
+
+	if p.config.DemoIndex != nil {
+		err := p.checkDemoIndex(rs, p.config.DemoIndex)
+		if err != nil {
+			return err
+		}
+
+		p.keyframeIndex = p.config.DemoIndex.keyframeIndex()
+
+		return nil
+	}
+
+	current := p.streamOffset()
+
+	idx, err := scanKeyframes(rs)
+	if err != nil {
+		return errors.Wrap(err, "failed to scan demo for keyframes")
+	}
+
+	p.keyframeIndex = idx
+
+	// scanning moved the underlying stream
+	return p.repositionStream(rs, current)
+}
+
+// checkDemoIndex makes sure the index belongs to the demo that's being parsed.
+func (p *parser) checkDemoIndex(rs io.ReadSeeker, idx *DemoIndex) error {
+	if p.demoFileHeader == nil {
+		// CDemoFileHeader is always the first frame
+		if !p.parseFrame() {
+			return ErrUnexpectedEndOfDemo
+		}
+
+		p.msgDispatcher.SyncAllQueues()
+
+		if err := p.error(); err != nil {
+			return err
+		}
+	}
+
+	current := p.streamOffset()
+
+	size, checksum, err := readDemoIdentity(rs)
+	if err != nil {
+		return err
+	}
+
+	// reading the identity moved the underlying stream
+	err = p.repositionStream(rs, current)
+	if err != nil {
+		return err
+	}
+
+	if !idx.matches(p.demoFileHeader, size, checksum) {
+		return ErrDemoIndexMismatch
+	}
+
+	return nil
+}
+
+// restoreKeyframe drops all entity-derived state and moves the stream to the given keyframe.
+func (p *parser) restoreKeyframe(rs io.ReadSeeker, kf keyframe) error {
+	p.stParser.ResetEntities()
+	p.gameState.reset()
+
+	if kf.full {
+		// the CDemoStringTables of the full packet contain all current player infos
+		p.rawPlayers = make(map[int]*common.PlayerInfo)
+	} else {
+		p.rawPlayers = maps.Clone(p.signonRawPlayers)
+	}
+
+	p.triggers = make(map[int]*boundingBoxInformation)
+	p.delayedEventHandlers = p.delayedEventHandlers[:0]
+	clear(p.queuedGameEvents)
+	p.queuedGameEvents = p.queuedGameEvents[:0]
+	p.gameEventsInTick = 0
+	clear(p.gameEventHandler.frameToBombExploded)
+	clear(p.gameEventHandler.userIDToFallDamageFrame)
+	clear(p.gameEventHandler.frameToRoundEndReason)
+
+	err := p.repositionStream(rs, kf.offset)
+	if err != nil {
+		return err
+	}
+
+	p.currentFrame = kf.frame
+
+	return nil
+}
+
+// replayUntil parses frames without dispatching game events until reached() returns true or the demo ends.
+// If force is true at least one frame is parsed (the keyframe itself after restoring).
+func (p *parser) replayUntil(reached func() bool, force bool) error {
+	eventDispatcher := p.eventDispatcher
+	p.eventDispatcher = new(dp.Dispatcher)
+
+	defer func() {
+		p.msgDispatcher.SyncAllQueues()
+		p.eventDispatcher = eventDispatcher
+	}()
+
+	for ; force || !reached(); force = false {
+		if !p.parseFrame() {
+			break
+		}
+
+		// reached() depends on state that's updated by the message handlers
+		p.msgDispatcher.SyncAllQueues()
+
+		if err := p.error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+recoverFromCorruptFrame restores the parser state from the first CDemoFullPacket after a FrameDecodeError
+and dispatches a ParserWarn describing the skipped frames, if ParserConfig.RecoverFromCorruptFrames is set.
+
+Returns false if parsing can't continue, the error is kept in that case.
+*/
+func (p *parser) recoverFromCorruptFrame() bool {
+	if !p.config.RecoverFromCorruptFrames {
+		return false
+	}
+
+	rs, ok := p.demoStream.(io.ReadSeeker)
+	if !ok || p.config.Format != DemoFormatFile {
+		return false
+	}
+
+	// errors from message handlers may belong to an earlier frame, they must all be handled before restoring
+	p.msgDispatcher.SyncAllQueues()
+
+	var decodeErr *FrameDecodeError
+	if !errors.As(p.error(), &decodeErr) {
+		return false
+	}
+
+	if p.ensureKeyframeIndex(rs) != nil {
+		return false
+	}
+
+	i := slices.IndexFunc(p.keyframeIndex.keyframes, func(kf keyframe) bool {
+		return kf.offset > decodeErr.Offset
+	})
+	if i < 0 {
+		return false
+	}
+
+	kf := p.keyframeIndex.keyframes[i]
+	roundBefore := p.gameState.totalRoundsPlayed + 1
+
+	p.clearError()
+
+	err := p.restoreKeyframe(rs, kf)
+	if err == nil {
+		// only the keyframe itself, the following frames are parsed normally
+		err = p.replayUntil(func() bool { return true }, true)
+	}
+
+	if err != nil {
+		p.setError(err)
+
+		return false
+	}
+
+	p.warn(events.ParserWarn{
+		Message: fmt.Sprintf("skipped %d frames / %d ingame ticks (%d to %d) after corrupt frame at offset %d, round %d to %d may be incomplete: %v",
+			kf.frame-decodeErr.Frame, kf.tick-decodeErr.Tick, decodeErr.Tick, kf.tick, decodeErr.Offset,
+			roundBefore, p.gameState.totalRoundsPlayed+1, decodeErr.Err),
+		Type: events.WarnTypeCorruptFramesSkipped,
+	})
+
+	return true
+}
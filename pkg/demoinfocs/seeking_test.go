@@ -0,0 +1,118 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+func testFileHeader() []byte {
+	b, err := proto.Marshal(testDemoFileHeader())
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+func testDemoData() []byte {
+	// incompressible, so the compressed frame is larger than the buffer of the frameReader and is skipped by seeking
+	large := make([]byte, 1<<17)
+	rand.New(rand.NewSource(1)).Read(large)
+
+	return newTestDemoBuilder().
+		signon().
+		frame(msg.EDemoCommands_DEM_Packet, 1, make([]byte, 100)).
+		frame(msg.EDemoCommands_DEM_FullPacket, 2, make([]byte, 200)).
+		frame(msg.EDemoCommands_DEM_Packet, 2, make([]byte, 100)).
+		frame(msg.EDemoCommands(99), 3, make([]byte, 10)). // unknown command
+		frame(msg.EDemoCommands_DEM_FullPacket, 1000, large).
+		frame(msg.EDemoCommands_DEM_Packet, 1001, make([]byte, 100)).
+		bytes()
+}
+
+func TestScanKeyframes(t *testing.T) {
+	data := testDemoData()
+
+	idx, err := scanKeyframes(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	assert.Equal(t, keyframe{offset: int64(16 + 7 + len(testFileHeader()) + 7 + 7), frame: 3, tick: 0}, idx.signon)
+	assert.Len(t, idx.keyframes, 2)
+	assert.Equal(t, keyframe{offset: idx.signon.offset + 103, frame: 4, tick: 2, full: true}, idx.keyframes[0])
+	assert.Equal(t, 6, idx.keyframes[1].frame) // unknown command doesn't count as frame
+	assert.Equal(t, 1000, idx.keyframes[1].tick)
+	assert.Equal(t, 8, idx.lastFrame)
+	assert.Equal(t, 1001, idx.lastTick)
+}
+
+func TestScanKeyframes_Truncated(t *testing.T) {
+	data := testDemoData()
+
+	full, err := scanKeyframes(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	// ends within the payload of the last keyframe
+	idx, err := scanKeyframes(bytes.NewReader(data[:full.keyframes[1].offset+100]))
+	assert.NoError(t, err)
+
+	assert.Len(t, idx.keyframes, 2)
+	assert.Equal(t, 1000, idx.lastTick)
+}
+
+func TestScanKeyframes_NoSyncTick(t *testing.T) {
+	_, err := scanKeyframes(bytes.NewReader(newTestDemoBuilder().bytes()))
+	assert.Error(t, err)
+}
+
+func TestParser_SeekToTick_NotSeekable(t *testing.T) {
+	p := NewParser(bytes.NewBuffer(testDemoData()))
+
+	assert.ErrorIs(t, p.SeekToTick(100), ErrSeekNotSupported)
+}
+
+func testCorruptDemoData() []byte {
+	return newTestDemoBuilder().
+		signon().
+		frame(msg.EDemoCommands_DEM_Packet, 10, []byte{0, 0, 0}). // corrupt
+		packet(11).
+		fullPacket(20).
+		packet(21).
+		bytes()
+}
+
+func TestParser_RecoverFromCorruptFrames(t *testing.T) {
+	cfg := DefaultParserConfig
+	cfg.RecoverFromCorruptFrames = true
+
+	p := NewParserWithConfig(bytes.NewReader(testCorruptDemoData()), cfg)
+
+	var warns []events.ParserWarn
+
+	p.RegisterEventHandler(func(warn events.ParserWarn) {
+		warns = append(warns, warn)
+	})
+
+	err := p.ParseToEnd()
+	assert.NoError(t, err)
+
+	if assert.Len(t, warns, 1) {
+		assert.Equal(t, events.WarnType(events.WarnTypeCorruptFramesSkipped), warns[0].Type)
+		assert.Contains(t, warns[0].Message, "skipped 2 frames / 10 ingame ticks (10 to 20)")
+	}
+
+	assert.Equal(t, 21, p.GameState().IngameTick())
+}
+
+func TestParser_RecoverFromCorruptFrames_Disabled(t *testing.T) {
+	p := NewParser(bytes.NewReader(testCorruptDemoData()))
+
+	err := p.ParseToEnd()
+	assert.ErrorIs(t, err, ErrUnexpectedEndOfDemo)
+}
@@ -2,6 +2,7 @@ package sendtablescs2
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	st "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/sendtables"
@@ -24,6 +25,33 @@ type class struct {
 	// fpNameCache. Each key packs up to 4 path components (14 bits each)
 	// plus the depth (8 bits) into a uint64.
 	fpFlatCache map[uint64]string
+	rewrites    map[int]*propertyRewrite // Top-level field index -> rewrite, see Parser.RewriteProperties()
+	skipped     bool                     // Entities aren't decoded, see Parser.SetEntityFilter()
+	skipFields  []bool                   // Top-level field index -> not decoded, nil if all fields are decoded
+}
+
+// applyFilter sets which entities and fields of the class are decoded, see Parser.SetEntityFilter().
+func (c *class) applyFilter(filter EntityFilter) {
+	c.skipped = len(filter.ServerClasses) > 0 && !slices.Contains(filter.ServerClasses, c.name)
+	c.skipFields = nil
+
+	if len(filter.PropertyPrefixes) == 0 || c.serializer == nil {
+		return
+	}
+
+	c.skipFields = make([]bool, len(c.serializer.fields))
+
+	for i, f := range c.serializer.fields {
+		c.skipFields[i] = !slices.ContainsFunc(filter.PropertyPrefixes, func(prefix string) bool {
+			// nested properties keep their whole top-level property
+			return strings.HasPrefix(f.varName, prefix) || strings.HasPrefix(prefix, f.varName+".")
+		})
+	}
+}
+
+// skips returns true if the value of the field path isn't decoded, see Parser.SetEntityFilter().
+func (c *class) skips(fp *fieldPath) bool {
+	return c.skipped || (c.skipFields != nil && c.skipFields[fp.path[0]])
 }
 
 func (c *class) ID() int {
@@ -5,6 +5,7 @@ import (
 	"os"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/golang/geo/r3"
 	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/constants"
@@ -64,24 +65,25 @@ func (p property) Name() string {
 }
 
 func (p property) Value() st.PropertyValue {
-	v := p.entity.Get(p.name)
-
-	fs, ok := v.(*fieldState)
-	if ok {
-		v = fs.state
-	}
-
 	return st.PropertyValue{
-		Any: v,
+		Any: p.entity.getValue(p.name).property(),
 	}
 }
 
 func (p property) OnUpdate(handler st.PropertyUpdateHandler) {
-	p.entity.updateHandlers[p.name] = append(p.entity.updateHandlers[p.name], handler)
+	p.entity.addUpdateHandler(p.name, handler)
 	p.entity.addHandlerByFP(p.name, handler)
 	p.entity.hasHandlers = true
 }
 
+func (e *Entity) addUpdateHandler(name string, handler st.PropertyUpdateHandler) {
+	if e.updateHandlers == nil {
+		e.updateHandlers = make(map[string][]st.PropertyUpdateHandler)
+	}
+
+	e.updateHandlers[name] = append(e.updateHandlers[name], handler)
+}
+
 // addHandlerByFP registers handler in the fast field-path-keyed lookup table.
 // Called alongside every updateHandlers insertion so the two maps stay in sync.
 func (e *Entity) addHandlerByFP(name string, handler st.PropertyUpdateHandler) {
@@ -142,7 +144,7 @@ var bindFactoryByType = map[st.PropertyValueType]bindFactory{
 
 func (p property) Bind(variable any, t st.PropertyValueType) {
 	h := bindFactoryByType[t](variable)
-	p.entity.updateHandlers[p.name] = append(p.entity.updateHandlers[p.name], h)
+	p.entity.addUpdateHandler(p.name, h)
 	p.entity.addHandlerByFP(p.name, h)
 	p.entity.hasHandlers = true
 }
@@ -154,6 +156,10 @@ func (e *Entity) Property(name string) st.Property {
 			return nil
 		}
 
+		if e.propCache == nil {
+			e.propCache = make(map[string]st.Property)
+		}
+
 		e.propCache[name] = property{
 			entity: e,
 			name:   name,
@@ -208,24 +214,18 @@ func coordFromCell(cell uint64, offset float32) float64 {
 }
 
 func (e *Entity) Position() r3.Vector {
-	cellXVal := e.Property(propCellX).Value()
-	cellYVal := e.Property(propCellY).Value()
-	cellZVal := e.Property(propCellZ).Value()
-	offsetXVal := e.Property(propVecX).Value()
-	offsetYVal := e.Property(propVecY).Value()
-	offsetZVal := e.Property(propVecZ).Value()
-
-	if cellXVal.Any == nil || cellYVal.Any == nil || cellZVal.Any == nil || offsetXVal.Any == nil || offsetYVal.Any == nil || offsetZVal.Any == nil {
+	// typed getters, so the values aren't boxed
+	cellX, okCellX := e.GetUint64(propCellX)
+	cellY, okCellY := e.GetUint64(propCellY)
+	cellZ, okCellZ := e.GetUint64(propCellZ)
+	offsetX, okVecX := e.GetFloat32(propVecX)
+	offsetY, okVecY := e.GetFloat32(propVecY)
+	offsetZ, okVecZ := e.GetFloat32(propVecZ)
+
+	if !okCellX || !okCellY || !okCellZ || !okVecX || !okVecY || !okVecZ {
 		return r3.Vector{} // CS2 POV demos
 	}
 
-	cellX := cellXVal.UInt64()
-	cellY := cellYVal.UInt64()
-	cellZ := cellZVal.UInt64()
-	offsetX := offsetXVal.Float()
-	offsetY := offsetYVal.Float()
-	offsetZ := offsetZVal.Float()
-
 	return r3.Vector{
 		X: coordFromCell(cellX, offsetX),
 		Y: coordFromCell(cellY, offsetY),
@@ -274,13 +274,9 @@ func newEntity(index, serial int32, class *class) *Entity {
 		serial:           serial,
 		class:            class,
 		active:           true,
-		state:            &fieldState{state: make([]any, 0, 16)},
-		fpCache:          make(map[string]*fieldPath),
-		fpNoop:           make(map[string]bool),
+		state:            &fieldState{state: make([]fieldValue, 0, 16)},
 		onCreateFinished: nil,
 		onDestroy:        nil,
-		updateHandlers:   make(map[string][]st.PropertyUpdateHandler),
-		propCache:        map[string]st.Property{},
 	}
 }
 
@@ -290,7 +286,7 @@ func (e *Entity) String() string {
 	props := make([]string, len(paths))
 
 	for _, fp := range paths {
-		props = append(props, fmt.Sprintf("%s: %v", e.class.getNameForFieldPath(fp), e.state.get(fp)))
+		props = append(props, fmt.Sprintf("%s: %v", e.class.getNameForFieldPath(fp), e.state.get(fp).box()))
 	}
 
 	return fmt.Sprintf("%d <%s>\n %s", e.index, e.class.name, strings.Join(props, "\n "))
@@ -300,25 +296,36 @@ func (e *Entity) String() string {
 func (e *Entity) Map() map[string]interface{} {
 	values := make(map[string]interface{})
 	for _, fp := range e.class.getFieldPaths(newFieldPath(), e.state) {
-		values[e.class.getNameForFieldPath(fp)] = e.state.get(fp)
+		values[e.class.getNameForFieldPath(fp)] = e.state.get(fp).box()
 	}
 	return values
 }
 
 // Get returns the current value of the Entity state for the given key
 func (e *Entity) Get(name string) interface{} {
+	return e.getValue(name).box()
+}
+
+// getValue returns the unboxed value of the Entity state for the given key.
+func (e *Entity) getValue(name string) fieldValue {
 	if fp, ok := e.fpCache[name]; ok {
 		return e.state.get(fp)
 	}
 	if e.fpNoop[name] {
-		return nil
+		return fieldValue{}
+	}
+
+	if e.fpCache == nil {
+		// most entities are never accessed by name
+		e.fpCache = make(map[string]*fieldPath)
+		e.fpNoop = make(map[string]bool)
 	}
 
 	fp := newFieldPath()
 	if !e.class.getFieldPathForName(fp, name) {
 		e.fpNoop[name] = true
 		fp.release()
-		return nil
+		return fieldValue{}
 	}
 	e.fpCache[name] = fp
 
@@ -327,50 +334,58 @@ func (e *Entity) Get(name string) interface{} {
 
 // Exists returns true if the given key exists in the Entity state
 func (e *Entity) Exists(name string) bool {
-	return e.Get(name) != nil
+	return e.getValue(name).kind != kindNone
 }
 
 // GetInt32 gets given key as an int32
 func (e *Entity) GetInt32(name string) (int32, bool) {
-	x, ok := e.Get(name).(int32)
-	return x, ok
+	v := e.getValue(name)
+	if v.kind != kindInt32 {
+		return 0, false
+	}
+	return int32(uint32(v.bits)), true //nolint:gosec
 }
 
 // GetUint32 gets given key as a uint32
 func (e *Entity) GetUint32(name string) (uint32, bool) {
-	if v := e.Get(name); v != nil {
-		switch x := v.(type) {
-		case uint32:
-			return x, true
-		case uint64:
-			return uint32(x), true //nolint:gosec
-		}
+	v := e.getValue(name)
+	if v.kind != kindUint32 && v.kind != kindUint64 {
+		return 0, false
 	}
-	return 0, false
+	return uint32(v.bits), true //nolint:gosec
 }
 
 // GetUint64 gets given key as a uint64
 func (e *Entity) GetUint64(name string) (uint64, bool) {
-	x, ok := e.Get(name).(uint64)
-	return x, ok
+	v := e.getValue(name)
+	if v.kind != kindUint64 {
+		return 0, false
+	}
+	return v.bits, true
 }
 
 // GetFloat32 gets given key as an float32
 func (e *Entity) GetFloat32(name string) (float32, bool) {
-	x, ok := e.Get(name).(float32)
-	return x, ok
+	v := e.getValue(name)
+	if v.kind != kindFloat32 {
+		return 0, false
+	}
+	return v.float32(), true
 }
 
 // GetString gets given key as a string
 func (e *Entity) GetString(name string) (string, bool) {
-	x, ok := e.Get(name).(string)
+	x, ok := e.getValue(name).ref.(string)
 	return x, ok
 }
 
 // GetBool gets given key as a bool
 func (e *Entity) GetBool(name string) (bool, bool) {
-	x, ok := e.Get(name).(bool)
-	return x, ok
+	v := e.getValue(name)
+	if v.kind != kindBool {
+		return false, false
+	}
+	return v.bits != 0, true
 }
 
 // GetSerial return the serial of the class associated with this Entity
@@ -395,7 +410,12 @@ func (e *Entity) GetIndex() int32 {
 
 // FindEntity finds a given Entity by index
 func (p *Parser) FindEntity(index int32) *Entity {
-	return p.entities[index]
+	e := p.entities[index]
+	if e != nil && e.class.skipped {
+		return nil
+	}
+
+	return e
 }
 
 func handle2idx(handle uint64) int32 {
@@ -421,7 +441,7 @@ func (p *Parser) FilterEntity(fb func(*Entity) bool) []*Entity {
 	entities := make([]*Entity, 0)
 
 	for _, et := range p.entities {
-		if fb(et) {
+		if !et.class.skipped && fb(et) {
 			entities = append(entities, et)
 		}
 	}
@@ -433,17 +453,35 @@ func (e *Entity) readFields(r *reader, paths *[]*fieldPath) {
 	n := readFieldPaths(r, paths)
 
 	for _, fp := range (*paths)[:n] {
+		if e.class.skips(fp) {
+			e.class.serializer.skip(r, fp, 0)
+
+			continue
+		}
+
 		decoder, updateCollection := e.class.serializer.getDecoderAndCollection(fp, 0)
 
+		// the position is only needed for rewrites, see Parser.RewriteProperties()
+		rewrite := len(e.class.rewrites) > 0
+
+		var start uint32
+		if rewrite {
+			start = r.bitPos()
+		}
+
 		val := decoder(r)
 
+		if rewrite {
+			e.recordRewrite(r, fp, start, val)
+		}
+
 		if updateCollection { //nolint:nestif
-			newLen := val.(uint64)
+			newLen := val.bits
 
 			// Retrieve the *fieldState pointer stored on the first update.
 			// We store a pointer so we can resize in place on subsequent updates
 			// without allocating a new fieldState each time.
-			fs, _ := e.state.get(fp).(*fieldState)
+			fs := e.state.get(fp).state()
 
 			if fs == nil {
 				// First update: allocate once and store the pointer.
@@ -452,8 +490,8 @@ func (e *Entity) readFields(r *reader, paths *[]*fieldPath) {
 				if initCap < 8 {
 					initCap = 8
 				}
-				fs = &fieldState{state: make([]any, newLen, initCap)}
-				e.state.set(fp, fs)
+				fs = &fieldState{state: make([]fieldValue, newLen, initCap)}
+				e.state.set(fp, stateValue(fs))
 			} else {
 				// Subsequent updates: resize the existing slice in place.
 				curLen := uint64(len(fs.state))
@@ -469,14 +507,14 @@ func (e *Entity) readFields(r *reader, paths *[]*fieldPath) {
 						if newCap < newLen {
 							newCap = newLen
 						}
-						newState := make([]any, newLen, newCap)
+						newState := make([]fieldValue, newLen, newCap)
 						copy(newState, fs.state)
 						fs.state = newState
 					}
 				}
 			}
 
-			val = fs.state
+			val = stateValue(fs)
 		} else {
 			e.state.set(fp, val)
 		}
@@ -489,19 +527,40 @@ func (e *Entity) readFields(r *reader, paths *[]*fieldPath) {
 
 // dispatchUpdate fires any registered update handlers for the given field path.
 // Uses handlersByFP (uint64 key) when available, falling back to the string map.
-func (e *Entity) dispatchUpdate(fp *fieldPath, val any) {
-	if e.handlersByFP != nil {
-		if key, ok := fpFlatKey(fp); ok {
-			for _, h := range e.handlersByFP[key] {
-				h(st.PropertyValue{Any: val})
-			}
-			return
-		}
+// The value is only boxed if there are handlers for the field path.
+func (e *Entity) dispatchUpdate(fp *fieldPath, val fieldValue) {
+	var handlers []st.PropertyUpdateHandler
+
+	if key, ok := fpFlatKey(fp); ok && e.handlersByFP != nil {
+		handlers = e.handlersByFP[key]
+	} else {
+		// Fallback: deep/large path — look up by name
+		handlers = e.updateHandlers[e.class.getNameForFieldPath(fp)]
 	}
-	// Fallback: deep/large path — look up by name
-	name := e.class.getNameForFieldPath(fp)
-	for _, h := range e.updateHandlers[name] {
-		h(st.PropertyValue{Any: val})
+
+	if len(handlers) == 0 {
+		return
+	}
+
+	v := st.PropertyValue{Any: val.property()}
+
+	for _, h := range handlers {
+		h(v)
+	}
+}
+
+// decodeStart returns the start time and bit position of decoding an entity, see SetDecodeMetrics().
+func (p *Parser) decodeStart(r *reader) (time.Time, uint32) {
+	if p.decodeMetrics == nil {
+		return time.Time{}, 0
+	}
+
+	return time.Now(), r.bitPos()
+}
+
+func (p *Parser) observeDecode(c *class, r *reader, start time.Time, startBit uint32) {
+	if p.decodeMetrics != nil {
+		p.decodeMetrics(c.name, int(r.bitPos()-startBit+7)/8, time.Since(start))
 	}
 }
 
@@ -553,6 +612,8 @@ func (p *Parser) OnPacketEntities(m *msg.CSVCMsg_PacketEntities) error {
 		cmd = r.readBits(2)
 
 		if cmd&0x01 == 0 { //nolint:nestif
+			start, startBit := p.decodeStart(r)
+
 			if cmd&0x02 != 0 {
 				classID = int32(r.readBits(p.classIdSize)) //nolint:gosec
 				serial = int32(r.readBits(17))             //nolint:gosec
@@ -577,6 +638,12 @@ func (p *Parser) OnPacketEntities(m *msg.CSVCMsg_PacketEntities) error {
 
 				e.readFields(r, &p.pathCache)
 
+				p.observeDecode(class, r, start, startBit)
+
+				if class.skipped {
+					continue // see SetEntityFilter()
+				}
+
 				// Fire created-handlers so update-handlers can be registered
 				for _, h := range class.createdHandlers {
 					h(e)
@@ -605,6 +672,8 @@ func (p *Parser) OnPacketEntities(m *msg.CSVCMsg_PacketEntities) error {
 				}
 
 				e.readFields(r, &p.pathCache)
+
+				p.observeDecode(e.class, r, start, startBit)
 			}
 		} else {
 			e = p.entities[index]
@@ -624,6 +693,10 @@ func (p *Parser) OnPacketEntities(m *msg.CSVCMsg_PacketEntities) error {
 			}
 		}
 
+		if e.class.skipped {
+			continue // see SetEntityFilter()
+		}
+
 		p.tuplesCache = append(p.tuplesCache, tuple{e, op})
 	}
 
@@ -642,6 +715,9 @@ func (p *Parser) OnPacketEntities(m *msg.CSVCMsg_PacketEntities) error {
 
 			for _, prop := range props {
 				v := e.PropertyValueMust(prop)
+				if v.Any == nil && e.class.skipFields != nil {
+					continue // filtered property, see SetEntityFilter()
+				}
 
 				for _, h := range e.updateHandlers[prop] {
 					h(v)
@@ -654,9 +730,30 @@ func (p *Parser) OnPacketEntities(m *msg.CSVCMsg_PacketEntities) error {
 		// FIXME: maybe we should panic("didn't consume all data")
 	}
 
+	if len(r.rewrites) > 0 {
+		data, err := applyRewrites(m.GetEntityData(), r.rewrites)
+		if err != nil {
+			return err
+		}
+
+		if data != nil {
+			m.EntityData = data
+		}
+	}
+
 	return nil
 }
 
+// ResetEntities drops all entities without calling their destroy handlers.
+// The next non-delta PacketEntities message (e.g. from a CDemoFullPacket) recreates them.
+//
+// Intended for internal use only.
+func (p *Parser) ResetEntities() {
+	clear(p.entities)
+
+	p.entityFullPackets = 0
+}
+
 // OnEntity registers an EntityHandler that will be called when an entity
 // is created, updated, deleted, etc.
 func (p *Parser) OnEntity(h st.EntityHandler) {
@@ -34,6 +34,8 @@ type field struct {
 	decoder      fieldDecoder
 	baseDecoder  fieldDecoder
 	childDecoder fieldDecoder
+	skipper      fieldSkipper
+	childSkipper fieldSkipper
 }
 
 func newField(serializers map[string]*serializer, ser *msg.CSVCMsg_FlattenedSerializer, f *msg.ProtoFlattenedSerializerFieldT) *field {
@@ -79,15 +81,16 @@ func (f *field) setModel(model int) {
 	switch model {
 	case fieldModelFixedArray:
 		f.decoder = findDecoder(f)
+		f.skipper = findSkipper(f)
 
 	case fieldModelFixedTable:
 		if len(f.polyTypes) > 0 {
-			f.baseDecoder = func(r *reader) interface{} {
+			f.baseDecoder = func(r *reader) fieldValue {
 				b := r.readBoolean()
 				polyTypeIndex := r.readUBitVar()
 				f.serializer = f.polyTypes[polyTypeIndex]
 
-				return b
+				return boolValue(b)
 			}
 		} else {
 			f.baseDecoder = booleanDecoder
@@ -99,12 +102,14 @@ func (f *field) setModel(model int) {
 		}
 		f.baseDecoder = unsignedDecoder
 		f.childDecoder = findDecoderByBaseType(f)
+		f.childSkipper = findSkipperByBaseType(f)
 
 	case fieldModelVariableTable:
 		f.baseDecoder = unsignedDecoder
 
 	case fieldModelSimple:
 		f.decoder = findDecoder(f)
+		f.skipper = findSkipper(f)
 	}
 }
 
@@ -173,6 +178,36 @@ func (f *field) getDecoderAndCollection(fp *fieldPath, pos int) (fieldDecoder, b
 	return f.decoder, false
 }
 
+// skip advances the reader past the value of a field path, like getDecoderAndCollection() but without decoding it.
+// Base decoders are still used since they may select the serializer of polymorphic tables.
+func (f *field) skip(r *reader, fp *fieldPath, pos int) {
+	switch f.model {
+	case fieldModelFixedTable:
+		if fp.last == pos-1 {
+			f.baseDecoder(r)
+		} else {
+			f.serializer.skip(r, fp, pos)
+		}
+
+	case fieldModelVariableArray:
+		if fp.last == pos {
+			f.childSkipper(r)
+		} else {
+			f.baseDecoder(r)
+		}
+
+	case fieldModelVariableTable:
+		if fp.last >= pos+1 {
+			f.serializer.skip(r, fp, pos+1)
+		} else {
+			f.baseDecoder(r)
+		}
+
+	default:
+		f.skipper(r)
+	}
+}
+
 func (f *field) getFieldPathForName(fp *fieldPath, name string) bool {
 	switch f.model {
 	case fieldModelFixedArray:
@@ -206,10 +241,10 @@ func (f *field) getFieldPaths(fp *fieldPath, state *fieldState) []*fieldPath {
 
 	switch f.model {
 	case fieldModelFixedArray:
-		if sub, ok := state.get(fp).(*fieldState); ok {
+		if sub := state.get(fp).state(); sub != nil {
 			fp.last++
 			for i, v := range sub.state {
-				if v != nil {
+				if v.kind != kindNone {
 					fp.path[fp.last] = i
 					x = append(x, fp.copy())
 				}
@@ -218,17 +253,17 @@ func (f *field) getFieldPaths(fp *fieldPath, state *fieldState) []*fieldPath {
 		}
 
 	case fieldModelFixedTable:
-		if sub, ok := state.get(fp).(*fieldState); ok {
+		if sub := state.get(fp).state(); sub != nil {
 			fp.last++
 			x = append(x, f.serializer.getFieldPaths(fp, sub)...)
 			fp.last--
 		}
 
 	case fieldModelVariableArray:
-		if sub, ok := state.get(fp).(*fieldState); ok {
+		if sub := state.get(fp).state(); sub != nil {
 			fp.last++
 			for i, v := range sub.state {
-				if v != nil {
+				if v.kind != kindNone {
 					fp.path[fp.last] = i
 					x = append(x, fp.copy())
 				}
@@ -237,10 +272,10 @@ func (f *field) getFieldPaths(fp *fieldPath, state *fieldState) []*fieldPath {
 		}
 
 	case fieldModelVariableTable:
-		if sub, ok := state.get(fp).(*fieldState); ok {
+		if sub := state.get(fp).state(); sub != nil {
 			fp.last += 2
 			for i, v := range sub.state {
-				if vv, ok := v.(*fieldState); ok {
+				if vv := v.state(); vv != nil {
 					fp.path[fp.last-1] = i
 					x = append(x, f.serializer.getFieldPaths(fp, vv)...)
 				}
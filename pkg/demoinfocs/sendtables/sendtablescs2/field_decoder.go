@@ -1,30 +1,7 @@
 package sendtablescs2
 
-import (
-	"math"
-)
-
-// simTimeCache holds pre-boxed interface{} values for simulationTimeDecoder.
-// CS2 runs at 64 tick; a 68-minute match produces ~262143 ticks, so 1<<18 covers all
-// realistic demos without needing a heap allocation for any tick value.
-const simTimeCacheLen = 1 << 18 // 262144
-
-var simTimeCache [simTimeCacheLen]interface{}
-
-// runeTimeCache holds pre-boxed interface{} values for runeTimeDecoder.
-// readBits(4) produces only 16 distinct values.
-var runeTimeCache [16]interface{}
-
-func init() {
-	for i := range simTimeCache {
-		simTimeCache[i] = float32(i) * (1.0 / 64)
-	}
-	for i := range runeTimeCache {
-		runeTimeCache[i] = math.Float32frombits(uint32(i)) //nolint:gosec
-	}
-}
-
-type fieldDecoder func(*reader) interface{}
+// fieldDecoder decodes a value without boxing it, see fieldValue.
+type fieldDecoder func(*reader) fieldValue
 type fieldFactory func(*field) fieldDecoder
 
 var fieldTypeFactories = map[string]fieldFactory{
@@ -287,8 +264,8 @@ func quantizedFactory(f *field) fieldDecoder {
 
 	qfd := newQuantizedFloatDecoder(f.bitCount, f.encodeFlags, f.lowValue, f.highValue)
 
-	return func(r *reader) interface{} {
-		return qfd.decode(r)
+	return func(r *reader) fieldValue {
+		return float32Value(qfd.decode(r))
 	}
 }
 
@@ -300,82 +277,74 @@ func vectorFactory(n int) fieldFactory {
 
 		d := floatFactory(f)
 		if n == 3 {
-			return func(r *reader) interface{} {
-				return [3]float32{d(r).(float32), d(r).(float32), d(r).(float32)}
+			return func(r *reader) fieldValue {
+				return vectorValue([3]float32{d(r).float32(), d(r).float32(), d(r).float32()})
 			}
 		}
-		return func(r *reader) interface{} {
+		return func(r *reader) fieldValue {
 			x := make([]float32, n)
 			for i := 0; i < n; i++ {
-				x[i] = d(r).(float32)
+				x[i] = d(r).float32()
 			}
-			return x
+			return refValue(x)
 		}
 	}
 }
 
-func vectorNormalDecoder(r *reader) interface{} {
-	return r.read3BitNormal()
+func vectorNormalDecoder(r *reader) fieldValue {
+	return vectorValue(r.read3BitNormal())
 }
 
-func fixed64Decoder(r *reader) interface{} {
-	return r.readLeUint64()
+func fixed64Decoder(r *reader) fieldValue {
+	return uint64Value(r.readLeUint64())
 }
 
-func booleanDecoder(r *reader) interface{} {
-	return r.readBoolean()
+func booleanDecoder(r *reader) fieldValue {
+	return boolValue(r.readBoolean())
 }
 
-func stringDecoder(r *reader) interface{} {
-	return r.readString()
+func stringDecoder(r *reader) fieldValue {
+	return refValue(r.readString())
 }
 
-func binaryBlockDecoder(r *reader) interface{} {
+func binaryBlockDecoder(r *reader) fieldValue {
 	n := r.readVarUint32()
-	return r.readBytes(n)
+	return refValue(r.readBytes(n))
 }
 
-func defaultDecoder(r *reader) interface{} {
-	return r.readVarUint32()
+func defaultDecoder(r *reader) fieldValue {
+	return uint32Value(r.readVarUint32())
 }
 
-func signedDecoder(r *reader) interface{} {
-	return r.readVarInt32()
+func signedDecoder(r *reader) fieldValue {
+	return int32Value(r.readVarInt32())
 }
 
-func floatCoordDecoder(r *reader) interface{} {
-	return r.readCoord()
+func floatCoordDecoder(r *reader) fieldValue {
+	return float32Value(r.readCoord())
 }
 
-func ammoDecoder(r *reader) interface{} {
-	return r.readVarUint32() - 1
+func ammoDecoder(r *reader) fieldValue {
+	return uint32Value(r.readVarUint32() - 1)
 }
 
-func noscaleDecoder(r *reader) interface{} {
-	bits := r.readLeUint32()
-	if bits == 0 {
-		return float32(0)
-	}
-	return r.cachedFloat32(bits)
+func noscaleDecoder(r *reader) fieldValue {
+	return fieldValue{kind: kindFloat32, bits: uint64(r.readLeUint32())}
 }
 
-func runeTimeDecoder(r *reader) interface{} {
-	return runeTimeCache[r.readBits(4)]
+func runeTimeDecoder(r *reader) fieldValue {
+	return fieldValue{kind: kindFloat32, bits: uint64(r.readBits(4))}
 }
 
-func simulationTimeDecoder(r *reader) interface{} {
-	t := r.readVarUint32()
-	if t < simTimeCacheLen {
-		return simTimeCache[t]
-	}
-	return float32(t) * (1.0 / 64)
+func simulationTimeDecoder(r *reader) fieldValue {
+	return float32Value(float32(r.readVarUint32()) * (1.0 / 64))
 }
 
 func readBitCoordPres(r *reader) float32 {
 	return r.readAngle(20) - 180.0
 }
 
-func qanglePreciseDecoder(r *reader) interface{} {
+func qanglePreciseDecoder(r *reader) fieldValue {
 	var v [3]float32
 	hasX := r.readBoolean()
 	hasY := r.readBoolean()
@@ -393,7 +362,7 @@ func qanglePreciseDecoder(r *reader) interface{} {
 		v[2] = readBitCoordPres(r)
 	}
 
-	return v
+	return vectorValue(v)
 }
 
 func qangleFactory(f *field) fieldDecoder {
@@ -403,16 +372,16 @@ func qangleFactory(f *field) fieldDecoder {
 
 	if f.bitCount != nil && *f.bitCount != 0 {
 		n := uint32(*f.bitCount) //nolint:gosec
-		return func(r *reader) interface{} {
-			return [3]float32{
+		return func(r *reader) fieldValue {
+			return vectorValue([3]float32{
 				r.readAngle(n),
 				r.readAngle(n),
 				r.readAngle(n),
-			}
+			})
 		}
 	}
 
-	return func(r *reader) interface{} {
+	return func(r *reader) fieldValue {
 		var ret [3]float32
 		rX := r.readBoolean()
 		rY := r.readBoolean()
@@ -426,20 +395,20 @@ func qangleFactory(f *field) fieldDecoder {
 		if rZ {
 			ret[2] = r.readCoord()
 		}
-		return ret
+		return vectorValue(ret)
 	}
 }
 
-func unsignedDecoder(r *reader) interface{} {
-	return uint64(r.readVarUint32())
+func unsignedDecoder(r *reader) fieldValue {
+	return uint64Value(uint64(r.readVarUint32()))
 }
 
-func unsigned64Decoder(r *reader) interface{} {
-	return r.readVarUint64()
+func unsigned64Decoder(r *reader) fieldValue {
+	return uint64Value(r.readVarUint64())
 }
 
-func componentDecoder(r *reader) interface{} {
-	return r.readBits(1)
+func componentDecoder(r *reader) fieldValue {
+	return uint32Value(r.readBits(1))
 }
 
 func findDecoder(f *field) fieldDecoder {
@@ -469,3 +438,193 @@ func findDecoderByBaseType(f *field) fieldDecoder {
 
 	return defaultDecoder
 }
+
+// fieldSkipper advances the reader past a value without materialising it, see Parser.SetEntityFilter().
+type fieldSkipper func(*reader)
+
+var fieldNameSkippers = map[string]fieldSkipper{
+	"m_iClip1": varUint32Skipper,
+}
+
+// fieldTypeSkippers contains the non-varint types of fieldTypeDecoders, all other types are varints.
+var fieldTypeSkippers = map[string]fieldSkipper{
+	"bool": bitSkipper,
+
+	"char":            stringSkipper,
+	"CUtlString":      stringSkipper,
+	"CUtlSymbolLarge": stringSkipper,
+	"CGlobalSymbol":   stringSkipper,
+
+	"GameTime_t": noscaleSkipper,
+
+	"CUtlBinaryBlock": binaryBlockSkipper,
+
+	"CBodyComponent":    bitSkipper,
+	"CPhysicsComponent": bitSkipper,
+	"CLightComponent":   bitSkipper,
+	"CRenderComponent":  bitSkipper,
+
+	"ResourceId_t": varUint64Skipper,
+}
+
+func skipperFactory(f *field, baseType string) fieldSkipper {
+	switch baseType {
+	case "float32":
+		return floatSkipperFactory(f)
+	case "CNetworkedQuantizedFloat":
+		return quantizedSkipperFactory(f)
+	case "uint64", "CStrongHandle":
+		if f.encoder == "fixed64" {
+			return fixed64Skipper
+		}
+		return varUint64Skipper
+	case "Vector", "VectorWS":
+		return vectorSkipperFactory(f, 3)
+	case "Vector2D":
+		return vectorSkipperFactory(f, 2)
+	case "Vector4D", "Quaternion":
+		return vectorSkipperFactory(f, 4)
+	case "CTransform":
+		return vectorSkipperFactory(f, 6)
+	case "QAngle":
+		return qangleSkipperFactory(f)
+	}
+
+	return nil
+}
+
+func floatSkipperFactory(f *field) fieldSkipper {
+	switch f.encoder {
+	case "coord":
+		return coordSkipper
+	case "simtime":
+		return varUint32Skipper
+	case "runetime":
+		return func(r *reader) {
+			r.readBits(4)
+		}
+	}
+
+	return quantizedSkipperFactory(f)
+}
+
+func quantizedSkipperFactory(f *field) fieldSkipper {
+	if f.bitCount == nil || (*f.bitCount <= 0 || *f.bitCount >= 32) {
+		return noscaleSkipper
+	}
+
+	qfd := newQuantizedFloatDecoder(f.bitCount, f.encodeFlags, f.lowValue, f.highValue)
+
+	return func(r *reader) {
+		qfd.decode(r)
+	}
+}
+
+func vectorSkipperFactory(f *field, n int) fieldSkipper {
+	if n == 3 && f.encoder == "normal" {
+		return func(r *reader) {
+			r.read3BitNormal()
+		}
+	}
+
+	s := floatSkipperFactory(f)
+
+	return func(r *reader) {
+		for i := 0; i < n; i++ {
+			s(r)
+		}
+	}
+}
+
+func qangleSkipperFactory(f *field) fieldSkipper {
+	if f.encoder == "qangle_precise" {
+		return func(r *reader) {
+			for _, ok := range [3]bool{r.readBoolean(), r.readBoolean(), r.readBoolean()} {
+				if ok {
+					r.readBits(20)
+				}
+			}
+		}
+	}
+
+	if f.bitCount != nil && *f.bitCount != 0 {
+		n := uint32(*f.bitCount) //nolint:gosec
+		return func(r *reader) {
+			r.readBits(n)
+			r.readBits(n)
+			r.readBits(n)
+		}
+	}
+
+	return func(r *reader) {
+		for _, ok := range [3]bool{r.readBoolean(), r.readBoolean(), r.readBoolean()} {
+			if ok {
+				r.readCoord()
+			}
+		}
+	}
+}
+
+func bitSkipper(r *reader) {
+	r.readBoolean()
+}
+
+func varUint32Skipper(r *reader) {
+	r.readVarUint32()
+}
+
+func varUint64Skipper(r *reader) {
+	r.readVarUint64()
+}
+
+func fixed64Skipper(r *reader) {
+	r.readBytes(8)
+}
+
+func noscaleSkipper(r *reader) {
+	r.readLeUint32()
+}
+
+func coordSkipper(r *reader) {
+	r.readCoord()
+}
+
+func stringSkipper(r *reader) {
+	for r.readByte() != 0 {
+	}
+}
+
+func binaryBlockSkipper(r *reader) {
+	for n := r.readVarUint32(); n > 0; n-- {
+		r.readByte()
+	}
+}
+
+func findSkipper(f *field) fieldSkipper {
+	if s := skipperFactory(f, f.fieldType.baseType); s != nil {
+		return s
+	}
+
+	if s, ok := fieldNameSkippers[f.varName]; ok {
+		return s
+	}
+
+	return findSkipperByType(f.fieldType.baseType)
+}
+
+func findSkipperByBaseType(f *field) fieldSkipper {
+	if s := skipperFactory(f, f.fieldType.genericType.baseType); s != nil {
+		return s
+	}
+
+	return findSkipperByType(f.fieldType.genericType.baseType)
+}
+
+func findSkipperByType(baseType string) fieldSkipper {
+	if s, ok := fieldTypeSkippers[baseType]; ok {
+		return s
+	}
+
+	// the remaining fieldTypeDecoders and defaultDecoder read 32 bit varints
+	return varUint32Skipper
+}
@@ -0,0 +1,74 @@
+package sendtablescs2
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func int32Ptr(v int32) *int32 {
+	return &v
+}
+
+func float32Ptr(v float32) *float32 {
+	return &v
+}
+
+func skipperTestFields() []*field {
+	var fields []*field
+
+	for baseType := range fieldTypeDecoders {
+		fields = append(fields, &field{varName: "m_test", fieldType: &fieldType{baseType: baseType}})
+	}
+
+	for baseType := range fieldTypeFactories {
+		for _, encoder := range []string{"", "coord", "simtime", "runetime", "normal", "fixed64", "qangle_precise"} {
+			for _, bitCount := range []*int32{nil, int32Ptr(10), int32Ptr(32)} {
+				fields = append(fields, &field{
+					varName:     "m_test",
+					fieldType:   &fieldType{baseType: baseType},
+					encoder:     encoder,
+					bitCount:    bitCount,
+					encodeFlags: int32Ptr(int32(qff_rounddown | qff_encode_zero)),
+					lowValue:    float32Ptr(-100),
+					highValue:   float32Ptr(100),
+				})
+			}
+		}
+	}
+
+	return append(fields,
+		&field{varName: "m_iClip1", fieldType: &fieldType{baseType: "int32"}},
+		&field{varName: "m_test", fieldType: &fieldType{baseType: "UnknownType_t"}},
+	)
+}
+
+func TestFindSkipper(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+
+	for _, f := range skipperTestFields() {
+		decoder := findDecoder(f)
+		skipper := findSkipper(f)
+
+		for i := 0; i < 100; i++ {
+			buf := make([]byte, 128)
+			rnd.Read(buf)
+
+			// keep strings and binary blocks within the buffer
+			buf[0] &= 0x3F
+			buf[1+rnd.Intn(8)] = 0
+
+			decoded := newReader(buf)
+			decoder(decoded)
+
+			skipped := newReader(buf)
+			skipper(skipped)
+
+			assert.Equal(t, decoded.bitPos(), skipped.bitPos(), "type %s with encoder %q", f.fieldType.baseType, f.encoder)
+
+			decoded.release()
+			skipped.release()
+		}
+	}
+}
@@ -1,50 +1,183 @@
 package sendtablescs2
 
+import (
+	"math"
+)
+
+type valueKind uint8
+
+const (
+	kindNone    valueKind = iota // No value (yet)
+	kindBool                     // bits != 0
+	kindInt32                    // Lower 32 bits
+	kindUint32                   // Lower 32 bits
+	kindUint64                   // bits
+	kindFloat32                  // IEEE 754 bits in the lower 32 bits
+	kindVector                   // x & y as float32 bits in bits, z in hi
+	kindState                    // *fieldState of a nested table or a variable-length collection in ref
+	kindRef                      // Any other value (strings, byte slices etc.) in ref
+)
+
+// fieldValue is a decoded property value.
+// Primitive values are stored unboxed so decoding and storing them in the per-entity fieldState slabs doesn't allocate,
+// they're only boxed on demand by box(), e.g. for PropertyValue.
+type fieldValue struct {
+	kind valueKind
+	hi   uint32
+	bits uint64
+	ref  any
+}
+
+func boolValue(b bool) fieldValue {
+	if b {
+		return fieldValue{kind: kindBool, bits: 1}
+	}
+
+	return fieldValue{kind: kindBool}
+}
+
+func int32Value(x int32) fieldValue {
+	return fieldValue{kind: kindInt32, bits: uint64(uint32(x))} //nolint:gosec
+}
+
+func uint32Value(x uint32) fieldValue {
+	return fieldValue{kind: kindUint32, bits: uint64(x)}
+}
+
+func uint64Value(x uint64) fieldValue {
+	return fieldValue{kind: kindUint64, bits: x}
+}
+
+func float32Value(x float32) fieldValue {
+	return fieldValue{kind: kindFloat32, bits: uint64(math.Float32bits(x))}
+}
+
+func vectorValue(v [3]float32) fieldValue {
+	return fieldValue{
+		kind: kindVector,
+		bits: uint64(math.Float32bits(v[0])) | uint64(math.Float32bits(v[1]))<<32,
+		hi:   math.Float32bits(v[2]),
+	}
+}
+
+func stateValue(s *fieldState) fieldValue {
+	return fieldValue{kind: kindState, ref: s}
+}
+
+func refValue(x any) fieldValue {
+	return fieldValue{kind: kindRef, ref: x}
+}
+
+func (v fieldValue) float32() float32 {
+	return math.Float32frombits(uint32(v.bits)) //nolint:gosec
+}
+
+func (v fieldValue) vector() [3]float32 {
+	return [3]float32{
+		math.Float32frombits(uint32(v.bits)), //nolint:gosec
+		math.Float32frombits(uint32(v.bits >> 32)),
+		math.Float32frombits(v.hi),
+	}
+}
+
+// state returns the nested fieldState or nil.
+func (v fieldValue) state() *fieldState {
+	if v.kind != kindState {
+		return nil
+	}
+
+	return v.ref.(*fieldState)
+}
+
+// box returns the value as interface, nested fieldStates are returned as *fieldState.
+//
+//nolint:gosec
+func (v fieldValue) box() any {
+	switch v.kind {
+	case kindBool:
+		return v.bits != 0
+	case kindInt32:
+		return int32(uint32(v.bits))
+	case kindUint32:
+		return uint32(v.bits)
+	case kindUint64:
+		return v.bits
+	case kindFloat32:
+		return v.float32()
+	case kindVector:
+		return v.vector()
+	case kindState, kindRef:
+		return v.ref
+	}
+
+	return nil
+}
+
+// property returns the value as PropertyValue.Any, the elements of variable-length collections are returned as []any.
+func (v fieldValue) property() any {
+	if s := v.state(); s != nil {
+		return s.boxed()
+	}
+
+	return v.box()
+}
+
 type fieldState struct {
-	state []any
+	state []fieldValue
 }
 
 func newFieldState() *fieldState {
 	return &fieldState{
-		state: make([]any, 8, 16),
+		state: make([]fieldValue, 8, 16),
 	}
 }
 
-func (s *fieldState) get(fp *fieldPath) any {
+// boxed returns the boxed values of the fieldState.
+func (s *fieldState) boxed() []any {
+	x := make([]any, len(s.state))
+
+	for i, v := range s.state {
+		x[i] = v.box()
+	}
+
+	return x
+}
+
+func (s *fieldState) get(fp *fieldPath) fieldValue {
 	x := s
 	z := 0
 
 	for i := 0; i <= fp.last; i++ {
 		z = fp.path[i]
 		if len(x.state) < z+1 {
-			return nil
+			return fieldValue{}
 		}
 		if i == fp.last {
 			return x.state[z]
 		}
-		if _, ok := x.state[z].(*fieldState); !ok {
-			return nil
+		if x.state[z].kind != kindState {
+			return fieldValue{}
 		}
-		x = x.state[z].(*fieldState)
+		x = x.state[z].ref.(*fieldState)
 	}
 
-	return nil
+	return fieldValue{}
 }
 
-func (s *fieldState) set(fp *fieldPath, v any) {
+func (s *fieldState) set(fp *fieldPath, v fieldValue) {
 	// Fast path for the common single-level case (fp.last == 0)
 	if fp.last == 0 { //nolint:nestif
 		z := fp.path[0]
 		if y := len(s.state); y <= z {
 			if z+2 > cap(s.state) {
-				newSlice := make([]any, z+1, max(z+2, y*2))
+				newSlice := make([]fieldValue, z+1, max(z+2, y*2))
 				copy(newSlice, s.state)
 				s.state = newSlice
 			} else {
 				s.state = s.state[:z+1]
 			}
 		}
-		if _, ok := s.state[z].(*fieldState); !ok {
+		if s.state[z].kind != kindState {
 			s.state[z] = v
 		}
 		return
@@ -59,7 +192,7 @@ func (s *fieldState) set(fp *fieldPath, v any) {
 		if y := len(x.state); y <= z {
 			newCap := max(z+2, y*2)
 			if z+2 > cap(x.state) {
-				newSlice := make([]any, z+1, newCap)
+				newSlice := make([]fieldValue, z+1, newCap)
 				copy(newSlice, x.state)
 				x.state = newSlice
 			} else {
@@ -69,17 +202,17 @@ func (s *fieldState) set(fp *fieldPath, v any) {
 		}
 
 		if i == fp.last {
-			if _, ok := x.state[z].(*fieldState); !ok {
+			if x.state[z].kind != kindState {
 				x.state[z] = v
 			}
 			return
 		}
 
-		if _, ok := x.state[z].(*fieldState); !ok {
-			x.state[z] = newFieldState()
+		if x.state[z].kind != kindState {
+			x.state[z] = stateValue(newFieldState())
 		}
 
-		x = x.state[z].(*fieldState)
+		x = x.state[z].ref.(*fieldState)
 	}
 }
 
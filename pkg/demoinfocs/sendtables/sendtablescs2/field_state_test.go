@@ -0,0 +1,48 @@
+package sendtablescs2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldValue_Box(t *testing.T) {
+	assert.Nil(t, fieldValue{}.box())
+	assert.Equal(t, true, boolValue(true).box())
+	assert.Equal(t, false, boolValue(false).box())
+	assert.Equal(t, int32(-5), int32Value(-5).box())
+	assert.Equal(t, uint32(7), uint32Value(7).box())
+	assert.Equal(t, uint64(1<<40), uint64Value(1<<40).box())
+	assert.Equal(t, float32(-1.5), float32Value(-1.5).box())
+	assert.Equal(t, [3]float32{1, -2, 3.5}, vectorValue([3]float32{1, -2, 3.5}).box())
+	assert.Equal(t, "abc", refValue("abc").box())
+}
+
+func TestFieldState(t *testing.T) {
+	s := &fieldState{}
+
+	fp := newFieldPath()
+	defer fp.release()
+
+	fp.path[0] = 20
+	s.set(fp, int32Value(-1))
+
+	assert.Equal(t, int32Value(-1), s.get(fp))
+
+	fp.path[0], fp.path[1], fp.last = 3, 1, 1
+	s.set(fp, float32Value(2))
+
+	assert.Equal(t, float32Value(2), s.get(fp))
+
+	fp.last = 0
+	nested := s.get(fp).state()
+
+	if assert.NotNil(t, nested) {
+		assert.Equal(t, []any{nil, float32(2)}, nested.boxed()[:2])
+	}
+
+	// nested states aren't overwritten by values
+	s.set(fp, uint64Value(1))
+
+	assert.Equal(t, nested, s.get(fp).state())
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 
@@ -68,6 +69,8 @@ type Parser struct {
 	pathCache                   []*fieldPath
 	tuplesCache                 []tuple
 	packetEntitiesPanicWarnFunc func(error)
+	entityFilter                EntityFilter
+	decodeMetrics               func(className string, bytes int, duration time.Duration)
 }
 
 func (p *Parser) ReadEnterPVS(r *bit.BitReader, index int, entities map[int]st.Entity, slot int) st.Entity { //nolint:revive
@@ -138,6 +141,8 @@ func (p *Parser) OnDemoClassInfo(m *msg.CDemoClassInfo) error {
 			fpNameCache:  &fpNameTreeCache{},
 			fpFlatCache:  make(map[uint64]string),
 		}
+		class.applyFilter(p.entityFilter)
+
 		p.classesById[class.classId] = class
 		p.classesByName[class.name] = class
 	}
@@ -145,6 +150,45 @@ func (p *Parser) OnDemoClassInfo(m *msg.CDemoClassInfo) error {
 	return nil
 }
 
+// EntityFilter limits which entities and properties are decoded by OnPacketEntities(), see Parser.SetEntityFilter().
+type EntityFilter struct {
+	// ServerClasses are the names of the server-classes whose entities are decoded, e.g. "CCSPlayerPawn".
+	// Empty means all server-classes.
+	ServerClasses []string
+
+	// PropertyPrefixes are the prefixes of the properties that are decoded, e.g. "m_iHealth" or "CBodyComponent".
+	// Prefixes are matched against top-level properties, a nested prefix like "m_pGameRules.m_bWarmupPeriod"
+	// keeps all properties of "m_pGameRules".
+	// Empty means all properties.
+	PropertyPrefixes []string
+}
+
+/*
+SetEntityFilter makes OnPacketEntities() skip the values of all other entities and properties
+by their encoded length, without decoding them.
+
+Entities of filtered server-classes are invisible: they aren't passed to entity or created handlers
+and FindEntity() etc. don't return them.
+Filtered properties of the remaining entities don't have a value and their update handlers aren't called.
+
+Intended for internal use only.
+*/
+func (p *Parser) SetEntityFilter(filter EntityFilter) {
+	p.entityFilter = filter
+
+	for _, c := range p.classesById {
+		c.applyFilter(filter)
+	}
+}
+
+// SetDecodeMetrics sets a callback that OnPacketEntities() calls for every entity it creates or updates
+// with the server-class, the size of the entity data and the decoding duration (including property update handlers).
+//
+// Intended for internal use only.
+func (p *Parser) SetDecodeMetrics(observe func(className string, bytes int, duration time.Duration)) {
+	p.decodeMetrics = observe
+}
+
 // SetInstanceBaseline sets the raw instance-baseline data for a serverclass by ID.
 //
 // Intended for internal use only.
@@ -6,42 +6,15 @@ import (
 	"sync"
 )
 
-// f32CacheEntry caches a pre-boxed interface{} for a given float32 bit pattern.
-type f32CacheEntry struct {
-	bits  uint32
-	boxed interface{}
-}
-
 // reader performs read operations against a buffer
 type reader struct {
 	buf      []byte
 	size     uint32
 	pos      uint32
-	bitVal   uint64 // value of the remaining bits in the current byte
-	bitCount uint32 // number of remaining bits in the current byte
-	strBuf   []byte // reusable buffer for readString
-	// f32Cache is a direct-mapped cache of pre-boxed float32 interface{} values.
-	// Indexed by (bits & f32CacheMask); same bits == same float32 value so reuse is safe.
-	// Retained across pool reuse to maximise hit rate; no reset needed on newReader.
-	f32Cache [512]f32CacheEntry
-}
-
-const f32CacheMask = uint32(512 - 1) // must match f32Cache array size
-
-// cachedFloat32 returns a pre-boxed interface{} for the given float32 bit pattern,
-// allocating and caching on miss. Zero is handled by the caller where possible.
-func (r *reader) cachedFloat32(bits uint32) interface{} {
-	// Mix high bits (exponent+sign) into the index to reduce collisions for
-	// clusters of similar values (e.g., nearby positions, velocities).
-	idx := (bits ^ (bits >> 16)) & f32CacheMask
-	e := &r.f32Cache[idx]
-	if e.bits == bits && e.boxed != nil {
-		return e.boxed
-	}
-	v := interface{}(math.Float32frombits(bits))
-	e.bits = bits
-	e.boxed = v
-	return v
+	bitVal   uint64         // value of the remaining bits in the current byte
+	bitCount uint32         // number of remaining bits in the current byte
+	strBuf   []byte         // reusable buffer for readString
+	rewrites []fieldRewrite // see Parser.RewriteProperties()
 }
 
 var readerPool = sync.Pool{
@@ -56,6 +29,7 @@ func newReader(buf []byte) *reader {
 	r.pos = 0
 	r.bitVal = 0
 	r.bitCount = 0
+	r.rewrites = r.rewrites[:0]
 	// strBuf is intentionally kept to reuse its backing array
 	return r
 }
@@ -63,6 +37,7 @@ func newReader(buf []byte) *reader {
 // release returns the reader to the pool for reuse.
 func (r *reader) release() {
 	r.buf = nil
+	clear(r.rewrites)
 	readerPool.Put(r)
 }
 
@@ -71,6 +46,11 @@ func (r *reader) remBytes() uint32 {
 	return r.size - r.pos
 }
 
+// bitPos returns the number of bits read so far
+func (r *reader) bitPos() uint32 {
+	return r.pos*8 - r.bitCount
+}
+
 // nextByte reads the next byte from the buffer.
 // The panic is in a separate noinline function so this hot path can be inlined.
 func (r *reader) nextByte() byte {
@@ -0,0 +1,155 @@
+package sendtablescs2
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/internal/bitwrite"
+)
+
+// PropertyRewriter returns the value a property should be replaced with in CSVCMsg_PacketEntities, see Parser.RewriteProperties().
+// entity contains the state after the update with the original values.
+// Returning false keeps the original value.
+type PropertyRewriter func(entity *Entity, prop string, value any) (replacement any, ok bool)
+
+type propertyRewrite struct {
+	name     string
+	field    *field
+	rewriter PropertyRewriter
+}
+
+// fieldRewrite is a decoded value in the entity data that may be replaced.
+type fieldRewrite struct {
+	start   uint32 // Bit position in the entity data
+	end     uint32
+	entity  *Entity
+	rewrite *propertyRewrite
+	value   any
+}
+
+/*
+RewriteProperties makes OnPacketEntities() replace the values of the given properties of a server-class
+in CSVCMsg_PacketEntities.entity_data, e.g. to anonymise demos.
+Only top-level string and uint64 properties are supported, properties that don't exist in the demo are ignored.
+Values from instance baselines are not rewritten.
+
+Must be called after the CDemoClassInfo has been parsed.
+
+Intended for internal use only.
+*/
+func (p *Parser) RewriteProperties(className string, props []string, rewriter PropertyRewriter) error {
+	c := p.classesByName[className]
+	if c == nil || c.serializer == nil {
+		return fmt.Errorf("server-class %q not found", className)
+	}
+
+	for _, name := range props {
+		fi := c.serializer.fieldIndexes[name]
+		if fi == nil {
+			continue
+		}
+
+		if fi.field.model != fieldModelSimple || fieldEncoding(fi.field) == nil {
+			return fmt.Errorf("property %s.%s of type %s can't be rewritten", className, name, fi.field.varType)
+		}
+
+		if c.rewrites == nil {
+			c.rewrites = make(map[int]*propertyRewrite)
+		}
+
+		c.rewrites[fi.index] = &propertyRewrite{
+			name:     name,
+			field:    fi.field,
+			rewriter: rewriter,
+		}
+	}
+
+	return nil
+}
+
+// fieldEncoding returns the inverse of the decoder of a field for rewritable types or nil.
+func fieldEncoding(f *field) func(w *bitwrite.BitWriter, v any) bool {
+	if f.fieldType.baseType == "uint64" {
+		return func(w *bitwrite.BitWriter, v any) bool {
+			x, ok := v.(uint64)
+			if !ok {
+				return false
+			}
+
+			if f.encoder == "fixed64" {
+				w.WriteBytes(binary.LittleEndian.AppendUint64(nil, x))
+			} else {
+				w.WriteVarInt(x)
+			}
+
+			return true
+		}
+	}
+
+	switch f.fieldType.baseType {
+	case "char", "CUtlString", "CUtlSymbolLarge", "CGlobalSymbol":
+		return func(w *bitwrite.BitWriter, v any) bool {
+			s, ok := v.(string)
+			if ok {
+				w.WriteString(s)
+			}
+
+			return ok
+		}
+	}
+
+	return nil
+}
+
+// recordRewrite records the positions of properties that may be rewritten, see Parser.RewriteProperties().
+func (e *Entity) recordRewrite(r *reader, fp *fieldPath, start uint32, val fieldValue) {
+	if fp.last != 0 {
+		return
+	}
+
+	rw := e.class.rewrites[fp.path[0]]
+	if rw == nil {
+		return
+	}
+
+	r.rewrites = append(r.rewrites, fieldRewrite{
+		start:   start,
+		end:     r.bitPos(),
+		entity:  e,
+		rewrite: rw,
+		value:   val.box(),
+	})
+}
+
+// applyRewrites returns the entity data with all recorded rewrites applied, or nil if nothing changed.
+func applyRewrites(data []byte, rewrites []fieldRewrite) ([]byte, error) {
+	var (
+		w       bitwrite.BitWriter
+		pos     uint32
+		changed bool
+	)
+
+	for _, rw := range rewrites {
+		replacement, ok := rw.rewrite.rewriter(rw.entity, rw.rewrite.name, rw.value)
+		if !ok {
+			continue
+		}
+
+		w.CopyBits(data, int(pos), int(rw.start))
+
+		if !fieldEncoding(rw.rewrite.field)(&w, replacement) {
+			return nil, fmt.Errorf("invalid replacement %T for property %s", replacement, rw.rewrite.name)
+		}
+
+		pos = rw.end
+		changed = true
+	}
+
+	if !changed {
+		return nil, nil
+	}
+
+	w.CopyBits(data, int(pos), len(data)*8)
+
+	return w.Bytes(), nil
+}
@@ -38,6 +38,10 @@ func (s *serializer) getDecoderAndCollection(fp *fieldPath, pos int) (fieldDecod
 	return s.fields[fp.path[pos]].getDecoderAndCollection(fp, pos+1)
 }
 
+func (s *serializer) skip(r *reader, fp *fieldPath, pos int) {
+	s.fields[fp.path[pos]].skip(r, fp, pos+1)
+}
+
 func (s *serializer) getFieldPathForName(fp *fieldPath, name string) bool {
 	if s.fieldIndexes[name] != nil {
 		fp.path[fp.last] = s.fieldIndexes[name].index
@@ -15,6 +15,7 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	bit "github.com/markus-wa/demoinfocs-golang/v5/internal/bitread"
+	"github.com/markus-wa/demoinfocs-golang/v5/internal/bitwrite"
 	common "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/common"
 	events "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
 	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
@@ -156,7 +157,7 @@ func (p *parser) handleUpdateStringTable(tab *msg.CSVCMsg_UpdateStringTable) {
 	case stNameInstanceBaseline:
 		// Only handle updates for the above types
 		// Create fake CreateStringTable and handle it like one of those
-		p.processStringTable(&msg.CSVCMsg_CreateStringTable{
+		err := p.processStringTable(&msg.CSVCMsg_CreateStringTable{
 			Name:                 cTab.Name,
 			NumEntries:           tab.NumChangedEntries,
 			UserDataFixedSize:    cTab.UserDataFixedSize,
@@ -166,6 +167,9 @@ func (p *parser) handleUpdateStringTable(tab *msg.CSVCMsg_UpdateStringTable) {
 			StringData:           tab.StringData,
 			UsingVarintBitcounts: cTab.UsingVarintBitcounts,
 		})
+		if err != nil {
+			p.setError(p.newFrameDecodeError(int32(msg.SVC_Messages_svc_UpdateStringTable), err))
+		}
 	}
 }
 
@@ -180,7 +184,12 @@ func (p *parser) handleCreateStringTable(tab *msg.CSVCMsg_CreateStringTable) {
 	case stNameModelPreCache:
 		fallthrough
 	case stNameInstanceBaseline:
-		p.processStringTable(tab)
+		err := p.processStringTable(tab)
+		if err != nil {
+			p.setError(p.newFrameDecodeError(int32(msg.SVC_Messages_svc_CreateStringTable), err))
+
+			return
+		}
 	}
 
 	p.stringTables = append(p.stringTables, tab)
@@ -200,29 +209,46 @@ const (
 )
 
 // Parse a string table data blob, returning a list of item updates.
+func (p *parser) parseStringTable(
+	buf []byte,
+	numUpdates int32,
+	name string,
+	userDataFixed bool,
+	userDataSize int32,
+	flags int32,
+	variantBitCount bool) []*stringTableItem {
+	items, err := decodeStringTable(buf, numUpdates, name, userDataFixed, userDataSize, flags, variantBitCount)
+	if err != nil {
+		p.warn(events.ParserWarn{
+			Type:    events.WarnTypeStringTableParsingFailure,
+			Message: "failed to parse stringtable properly",
+		})
+	}
+
+	return items
+}
+
+// decodeStringTable decodes a string table data blob.
+// If the data is corrupt, the items decoded so far are returned with an error.
 //
 //nolint:funlen,gocognit
-func (p *parser) parseStringTable(
+func decodeStringTable(
 	buf []byte,
 	numUpdates int32,
 	name string,
 	userDataFixed bool,
 	userDataSize int32,
 	flags int32,
-	variantBitCount bool) (items []*stringTableItem) {
+	variantBitCount bool) (items []*stringTableItem, err error) {
 	items = make([]*stringTableItem, 0)
 	// Some tables have no data
 	if len(buf) == 0 {
-		return items
+		return items, nil
 	}
 
 	defer func() {
-		err := recover()
-		if err != nil {
-			p.eventDispatcher.Dispatch(events.ParserWarn{
-				Type:    events.WarnTypeStringTableParsingFailure,
-				Message: "failed to parse stringtable properly",
-			})
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to decode string table %q: %v", name, r)
 		}
 	}()
 
@@ -328,12 +354,60 @@ func (p *parser) parseStringTable(
 		items = append(items, &stringTableItem{index, key, value})
 	}
 
-	return items
+	return items, nil
+}
+
+// encodeStringTable is the inverse of parseStringTable(), keys are written without using the key history.
+func encodeStringTable(items []*stringTableItem, userDataFixed bool, userDataSize int32, flags int32, variantBitCount bool) []byte {
+	w := new(bitwrite.BitWriter)
+	index := int32(-1)
+
+	for _, item := range items {
+		if item.Index == index+1 {
+			w.WriteBit(true)
+		} else {
+			w.WriteBit(false)
+			w.WriteVarInt(uint64(item.Index - 1)) //nolint:gosec
+		}
+
+		index = item.Index
+
+		w.WriteBit(item.Key != "")
+
+		if item.Key != "" {
+			w.WriteBit(false) // no key history
+			w.WriteString(item.Key)
+		}
+
+		w.WriteBit(item.Value != nil)
+
+		switch {
+		case item.Value == nil:
+
+		case userDataFixed:
+			w.CopyBits(item.Value, 0, int(userDataSize))
+
+		default:
+			if (flags & 0x1) != 0 {
+				w.WriteBit(false) // not compressed
+			}
+
+			if variantBitCount {
+				w.WriteUBitInt(uint(len(item.Value)))
+			} else {
+				w.WriteBits(uint(len(item.Value)), 17)
+			}
+
+			w.WriteBytes(item.Value)
+		}
+	}
+
+	return w.Bytes()
 }
 
 var instanceBaselineKeyRegex = regexp.MustCompile(`^\d+:\d+$`)
 
-func (p *parser) processStringTable(tab *msg.CSVCMsg_CreateStringTable) {
+func (p *parser) processStringTable(tab *msg.CSVCMsg_CreateStringTable) error {
 	if tab.GetName() == stNameModelPreCache {
 		for i := len(p.modelPreCache); i < int(tab.GetNumEntries()); i++ {
 			p.modelPreCache = append(p.modelPreCache, "")
@@ -345,7 +419,7 @@ func (p *parser) processStringTable(tab *msg.CSVCMsg_CreateStringTable) {
 
 		b, err := snappy.Decode(tmp, tab.StringData)
 		if err != nil {
-			panic(err)
+			return errors.Wrapf(err, "failed to decompress string table %q", tab.GetName())
 		}
 
 		tab.StringData = b
@@ -362,18 +436,23 @@ func (p *parser) processStringTable(tab *msg.CSVCMsg_CreateStringTable) {
 
 			classID, err := strconv.Atoi(item.Key)
 			if err != nil {
-				panic(errors.Wrap(err, "failed to parse serverClassID"))
+				return errors.Wrap(err, "failed to parse serverClassID")
 			}
 
 			p.stParser.SetInstanceBaseline(classID, item.Value)
 		case stNameUserInfo:
-			p.parseUserInfo(item.Value, int(item.Index))
+			err := p.parseUserInfo(item.Value, int(item.Index))
+			if err != nil {
+				return err
+			}
 		}
 	}
 
 	if tab.GetName() == stNameModelPreCache {
 		p.processModelPreCacheUpdate()
 	}
+
+	return nil
 }
 
 func parsePlayerInfo(reader io.Reader) common.PlayerInfo {
@@ -445,7 +524,9 @@ func (p *parser) handleStringTables(msg *msg.CDemoStringTables) {
 
 				classID, err := strconv.Atoi(key)
 				if err != nil {
-					panic(errors.Wrap(err, "failed to parse serverClassID"))
+					p.setError(p.newFrameDecodeError(-1, errors.Wrap(err, "failed to parse serverClassID")))
+
+					return
 				}
 
 				p.stParser.SetInstanceBaseline(classID, item.GetData())
@@ -454,24 +535,31 @@ func (p *parser) handleStringTables(msg *msg.CDemoStringTables) {
 			for _, item := range tab.GetItems() {
 				playerIndex, err := strconv.Atoi(item.GetStr())
 				if err != nil {
-					panic(errors.Wrap(err, "failed to parse playerIndex"))
+					p.setError(p.newFrameDecodeError(-1, errors.Wrap(err, "failed to parse playerIndex")))
+
+					return
 				}
 
-				p.parseUserInfo(item.GetData(), playerIndex)
+				err = p.parseUserInfo(item.GetData(), playerIndex)
+				if err != nil {
+					p.setError(p.newFrameDecodeError(-1, err))
+
+					return
+				}
 			}
 		}
 	}
 }
 
-func (p *parser) parseUserInfo(data []byte, playerIndex int) {
+func (p *parser) parseUserInfo(data []byte, playerIndex int) error {
 	if _, exists := p.rawPlayers[playerIndex]; exists {
-		return
+		return nil
 	}
 
 	var userInfo msg.CMsgPlayerInfo
 	err := proto.Unmarshal(data, &userInfo)
 	if err != nil {
-		panic(errors.Wrap(err, "failed to parse CMsgPlayerInfo msg"))
+		return errors.Wrap(err, "failed to parse CMsgPlayerInfo msg")
 	}
 
 	xuid := userInfo.GetXuid() // TODO: what to do with userInfo.GetSteamid()? (seems to be the same, but maybe not in China?)
@@ -479,7 +567,7 @@ func (p *parser) parseUserInfo(data []byte, playerIndex int) {
 	// When the userinfo ST is created its data contains 1 message for each possible player slot (up to 64).
 	// We ignore messages that are empty, i.e. not related to a real player, a BOT or GOTV.
 	if xuid == 0 && name == "" {
-		return
+		return nil
 	}
 
 	playerInfo := common.PlayerInfo{
@@ -506,4 +594,6 @@ func (p *parser) parseUserInfo(data []byte, playerIndex int) {
 		p.recordingPlayerSlot = playerIndex
 		p.eventDispatcher.Dispatch(events.POVRecordingPlayerDetected{PlayerSlot: playerIndex, PlayerInfo: playerInfo})
 	}
+
+	return nil
 }
@@ -0,0 +1,153 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/demowriter"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+func testDemoFileHeader() *msg.CDemoFileHeader {
+	return &msg.CDemoFileHeader{
+		DemoFileStamp:   proto.String("PBDEMS_2"),
+		PatchVersion:    proto.Int32(14113),
+		MapName:         proto.String("de_test"),
+		ServerStartTick: proto.Int32(123),
+	}
+}
+
+// testWriteSeeker is an in-memory io.WriteSeeker.
+type testWriteSeeker struct {
+	b   []byte
+	pos int
+}
+
+func (ws *testWriteSeeker) Write(p []byte) (int, error) {
+	if end := ws.pos + len(p); end > len(ws.b) {
+		ws.b = append(ws.b, make([]byte, end-len(ws.b))...)
+	}
+
+	n := copy(ws.b[ws.pos:], p)
+	ws.pos += n
+
+	return n, nil
+}
+
+func (ws *testWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += int64(ws.pos)
+	case io.SeekEnd:
+		offset += int64(len(ws.b))
+	}
+
+	ws.pos = int(offset)
+
+	return offset, nil
+}
+
+func (ws *testWriteSeeker) Bytes() []byte {
+	return ws.b
+}
+
+// testDemoBuilder writes demos for tests with a demowriter.Writer.
+// It panics on errors, like the other test data helpers.
+type testDemoBuilder struct {
+	out interface{ Bytes() []byte }
+	w   *demowriter.Writer
+}
+
+// newTestDemoBuilder returns a builder for a demo with testDemoFileHeader().
+// The demo is written as a stream, so the file-info offset in the PBDEMS2 header stays 0, like in incomplete demos.
+func newTestDemoBuilder() *testDemoBuilder {
+	return newTestDemoBuilderWithHeader(testDemoFileHeader())
+}
+
+// newTestDemoBuilderWithHeader is like newTestDemoBuilder() with a custom CDemoFileHeader.
+func newTestDemoBuilderWithHeader(header *msg.CDemoFileHeader) *testDemoBuilder {
+	out := new(bytes.Buffer)
+
+	return newTestDemoBuilderTo(out, out, header)
+}
+
+// newTestDemoFileBuilder is like newTestDemoBuilder() but stores the offset of the CDemoFileInfo in the header,
+// like in complete '.dem' files.
+func newTestDemoFileBuilder() *testDemoBuilder {
+	out := new(testWriteSeeker)
+
+	return newTestDemoBuilderTo(out, out, testDemoFileHeader())
+}
+
+func newTestDemoBuilderTo(out interface{ Bytes() []byte }, w io.Writer, header *msg.CDemoFileHeader) *testDemoBuilder {
+	dw, err := demowriter.NewWriter(w, header)
+	if err != nil {
+		panic(err)
+	}
+
+	return &testDemoBuilder{out: out, w: dw}
+}
+
+func (b *testDemoBuilder) check(err error) *testDemoBuilder {
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+// offset returns the offset of the next frame.
+func (b *testDemoBuilder) offset() int64 {
+	return b.w.Offset()
+}
+
+// signon writes a DEM_SignonPacket with the given net-messages and the DEM_SyncTick.
+func (b *testDemoBuilder) signon(msgs ...demowriter.NetMessage) *testDemoBuilder {
+	b.check(b.w.WriteFrame(msg.EDemoCommands_DEM_SignonPacket, demowriter.SignonTick, testDemoPacket(msgs...)))
+
+	return b.check(b.w.WriteSyncTick())
+}
+
+// frame writes a frame with a raw payload, which is compressed if it's at least 1 KiB, see demowriter.DefaultConfig.
+func (b *testDemoBuilder) frame(cmd msg.EDemoCommands, tick int32, payload []byte) *testDemoBuilder {
+	return b.check(b.w.WriteRawFrame(cmd, tick, payload))
+}
+
+// packet writes a DEM_Packet with the given net-messages.
+func (b *testDemoBuilder) packet(tick int32, msgs ...demowriter.NetMessage) *testDemoBuilder {
+	return b.check(b.w.WritePacket(tick, msgs...))
+}
+
+// fullPacket writes a DEM_FullPacket with empty string tables and the given net-messages.
+func (b *testDemoBuilder) fullPacket(tick int32, msgs ...demowriter.NetMessage) *testDemoBuilder {
+	return b.check(b.w.WriteFullPacket(tick, &msg.CDemoStringTables{}, testDemoPacket(msgs...)))
+}
+
+// bytes writes the DEM_Stop at the last tick and a CDemoFileInfo with the playback values of the written frames
+// and returns the demo.
+func (b *testDemoBuilder) bytes() []byte {
+	return b.bytesWithFileInfo(nil)
+}
+
+// bytesWithFileInfo is like bytes() with the given CDemoFileInfo, see demowriter.Writer.Close().
+func (b *testDemoBuilder) bytesWithFileInfo(info *msg.CDemoFileInfo) []byte {
+	b.check(b.w.Close(info))
+
+	return b.out.Bytes()
+}
+
+// incompleteBytes returns the demo without DEM_Stop and CDemoFileInfo, like demos of crashed servers.
+func (b *testDemoBuilder) incompleteBytes() []byte {
+	return bytes.Clone(b.out.Bytes())
+}
+
+func testDemoPacket(msgs ...demowriter.NetMessage) *msg.CDemoPacket {
+	data, err := demowriter.EncodePacketData(msgs...)
+	if err != nil {
+		panic(err)
+	}
+
+	return &msg.CDemoPacket{Data: data}
+}
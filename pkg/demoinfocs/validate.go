@@ -0,0 +1,435 @@
+package demoinfocs
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+// ValidationIssueType identifies a kind of problem found by Validate().
+type ValidationIssueType string
+
+const (
+	IssueInvalidFileType        ValidationIssueType = "invalid_file_type"        // Not a CS2 '.dem' file
+	IssueTruncated              ValidationIssueType = "truncated"                // The demo ends before DEM_Stop, e.g. because the upload was incomplete
+	IssueCorruptFrame           ValidationIssueType = "corrupt_frame"            // A frame or net-message couldn't be decoded, see FrameDecodeError
+	IssueCorruptCompressedFrame ValidationIssueType = "corrupt_compressed_frame" // The snappy compressed payload of a frame is corrupt
+	IssueMissingFileInfo        ValidationIssueType = "missing_file_info"        // The demo has no CDemoFileInfo, e.g. because the server crashed
+	IssueFileInfoMismatch       ValidationIssueType = "file_info_mismatch"       // CDemoFileInfo.playback_ticks doesn't match the last tick of the demo
+	IssueMissingGameEventList   ValidationIssueType = "missing_game_event_list"  // Game events occur before the game-event list (sv_hibernate_when_empty bug)
+	IssueUnknownProtocol        ValidationIssueType = "unknown_protocol"         // The network protocol is newer than the game-event lists known to this library
+	IssueEntityDecodeFailure    ValidationIssueType = "entity_decode_failure"    // CSVCMsg_PacketEntities couldn't be decoded
+	IssueParseError             ValidationIssueType = "parse_error"              // Any other error that stops parsing
+)
+
+// ValidationSeverity is the severity of a ValidationIssue.
+type ValidationSeverity string
+
+const (
+	// SeverityError means the demo can't be parsed completely or its data can't be trusted.
+	SeverityError ValidationSeverity = "error"
+
+	// SeverityWarning means the demo can be parsed, but some data may be missing or inaccurate.
+	SeverityWarning ValidationSeverity = "warning"
+)
+
+// ValidationIssue is a problem found by Validate().
+type ValidationIssue struct {
+	Type     ValidationIssueType `json:"type"`
+	Severity ValidationSeverity  `json:"severity"`
+	Message  string              `json:"message"`
+	Offset   int64               `json:"offset,omitempty"` // Offset in the demo stream in bytes, if known
+	Tick     int                 `json:"tick,omitempty"`   // Ingame tick (of the first occurrence), if known
+	Count    int                 `json:"count,omitempty"`  // Number of occurrences if the issue is aggregated
+}
+
+// Report is the result of Validate(). It can be marshalled to JSON.
+type Report struct {
+	Valid           bool              `json:"valid"` // Whether there are no issues with SeverityError
+	MapName         string            `json:"map_name"`
+	NetworkProtocol int               `json:"network_protocol"`
+	BuildNum        int               `json:"build_num"`
+	Size            int64             `json:"size"`            // Size of the demo stream in bytes
+	Frames          int               `json:"frames"`          // Frames up to DEM_Stop, see Parser.CurrentFrame()
+	LastTick        int               `json:"last_tick"`       // Ingame tick of the last frame before or at DEM_Stop
+	PlaybackTicks   int               `json:"playback_ticks"`  // From CDemoFileInfo, 0 if missing
+	PlaybackFrames  int               `json:"playback_frames"` // From CDemoFileInfo, 0 if missing
+	Issues          []ValidationIssue `json:"issues"`
+	Warnings        []WarningSummary  `json:"warnings"` // ParserWarns that occurred while parsing, see Parser.Warnings()
+}
+
+func (r *Report) addIssue(issue ValidationIssue) {
+	r.Issues = append(r.Issues, issue)
+
+	if issue.Severity == SeverityError {
+		r.Valid = false
+	}
+}
+
+/*
+Validate checks the integrity of a '.dem' file, e.g. to reject or flag bad uploads before running an expensive analysis.
+
+It checks the framing of the demo, truncation, corrupt snappy compressed frames,
+CDemoFileInfo against the last tick, missing game-event lists (sv_hibernate_when_empty bug),
+network protocols that are newer than the bundled game-event lists and entity decode failures.
+
+The demo is parsed without any user handlers. Problems are returned as issues of the Report instead of errors,
+so Validate() never fails. The stream doesn't need to implement io.Seeker.
+*/
+func Validate(r io.Reader) Report {
+	report := Report{Valid: true}
+
+	pr, pw := io.Pipe()
+	parsed := make(chan parseResult)
+
+	go func() {
+		res := validateParse(pr)
+
+		// keep the frame pass going if parsing stopped early
+		_, _ = io.Copy(io.Discard, pr)
+
+		parsed <- res
+	}()
+
+	err := validateFrames(io.TeeReader(r, pw), &report)
+	pw.CloseWithError(err)
+
+	res := <-parsed
+
+	report.Warnings = res.warnings
+
+	addParseIssues(&report, res)
+
+	return report
+}
+
+// validateFrames checks the framing of the demo and reads the header and CDemoFileInfo.
+// Returns a non-nil error only if reading the stream failed.
+func validateFrames(r io.Reader, report *Report) error {
+	fileInfoOffset, err := readFileStamp(r)
+	if err != nil {
+		return checkFrameError(err, report, 0)
+	}
+
+	fr := newFrameReader(r, headerSizeS2)
+
+	defer func() {
+		report.Size = fr.pos
+	}()
+
+	stopped := false
+
+	for !stopped {
+		h, err := fr.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if errors.Is(err, errFrameTooLarge) {
+			// the frames after it can't be found
+			addFrameIssue(report, h, err)
+
+			return discardRemaining(fr)
+		}
+
+		if err != nil {
+			return checkFrameError(err, report, h.offset)
+		}
+
+		report.LastTick = h.tick
+
+		switch {
+		case h.cmd == msg.EDemoCommands_DEM_Stop:
+			stopped = true
+			err = fr.skip(h)
+
+		case h.cmd == msg.EDemoCommands_DEM_FileHeader:
+			header := new(msg.CDemoFileHeader)
+
+			err = unmarshalFrame(fr, h, header)
+			if err == nil {
+				report.MapName = header.GetMapName()
+				report.NetworkProtocol = int(header.GetPatchVersion())
+				report.BuildNum = int(header.GetBuildNum())
+			}
+
+		case h.compressed:
+			_, err = fr.payload(h)
+
+		default:
+			err = fr.skip(h)
+		}
+
+		// parseFrame() doesn't dispatch frameParsedToken for unknown commands
+		if demoCommandMsgsCreators[h.cmd] != nil {
+			report.Frames++
+		}
+
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return checkFrameError(err, report, h.offset)
+		}
+
+		if err != nil {
+			addFrameIssue(report, h, err)
+		}
+	}
+
+	if !stopped {
+		report.addIssue(ValidationIssue{
+			Type:     IssueTruncated,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("demo ends at offset %d (tick %d) without DEM_Stop", fr.pos, report.LastTick),
+			Offset:   fr.pos,
+			Tick:     report.LastTick,
+		})
+	}
+
+	var fileInfo *msg.CDemoFileInfo
+
+	// incomplete demos have a file-info offset of 0, see ReadDemoInfo()
+	if stopped && fileInfoOffset > 0 {
+		fileInfo = new(msg.CDemoFileInfo)
+
+		err = readFileInfo(fr, fileInfoOffset, fileInfo)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return checkFrameError(err, report, fileInfoOffset)
+		}
+
+		if err != nil {
+			addFrameIssue(report, frameHeader{offset: fileInfoOffset, cmd: msg.EDemoCommands_DEM_FileInfo}, err)
+
+			return nil
+		}
+	}
+
+	checkFileInfo(fileInfo, report)
+
+	return discardRemaining(fr)
+}
+
+// discardRemaining reads the rest of the stream, so the size of the demo is known and the parsing side gets all data.
+func discardRemaining(fr *frameReader) error {
+	n, err := io.Copy(io.Discard, fr.br)
+	fr.pos += n
+
+	return err
+}
+
+// readFileInfo reads the CDemoFileInfo that follows DEM_Stop, which must be at the file-info offset of the PBDEMS2 header.
+func readFileInfo(fr *frameReader, offset int64, fileInfo *msg.CDemoFileInfo) error {
+	if offset != fr.pos {
+		return errors.Errorf("file-info offset %d doesn't match the end of DEM_Stop at offset %d", offset, fr.pos)
+	}
+
+	return fr.readMessage(msg.EDemoCommands_DEM_FileInfo, fileInfo)
+}
+
+func addFrameIssue(report *Report, h frameHeader, err error) {
+	issueType := IssueCorruptFrame
+	if errors.Is(err, snappy.ErrCorrupt) {
+		issueType = IssueCorruptCompressedFrame
+	}
+
+	report.addIssue(ValidationIssue{
+		Type:     issueType,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf("%v at offset %d (tick %d): %v", h.cmd, h.offset, h.tick, err),
+		Offset:   h.offset,
+		Tick:     h.tick,
+	})
+}
+
+// unmarshalFrame reads the payload of a frame into m, corrupt payloads are reported via the error of fr.payload().
+func unmarshalFrame(fr *frameReader, h frameHeader, m proto.Message) error {
+	b, err := fr.payload(h)
+	if err != nil {
+		return err
+	}
+
+	err = proto.Unmarshal(b, m)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unmarshal %v", h.cmd)
+	}
+
+	return nil
+}
+
+// checkFrameError adds an issue for a framing error that stops the frame pass.
+// Returns err if it's not caused by the demo itself (i.e. an I/O error).
+func checkFrameError(err error, report *Report, offset int64) error {
+	switch {
+	case errors.Is(err, ErrInvalidFileType):
+		report.addIssue(ValidationIssue{
+			Type:     IssueInvalidFileType,
+			Severity: SeverityError,
+			Message:  "invalid file type, expected a CS2 demo (PBDEMS2)",
+		})
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		report.addIssue(ValidationIssue{
+			Type:     IssueTruncated,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("demo ends within the frame at offset %d (tick %d)", offset, report.LastTick),
+			Offset:   offset,
+			Tick:     report.LastTick,
+		})
+
+	default:
+		report.addIssue(ValidationIssue{
+			Type:     IssueParseError,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("failed to read frame at offset %d: %v", offset, err),
+			Offset:   offset,
+		})
+
+		return err
+	}
+
+	return nil
+}
+
+func checkFileInfo(fileInfo *msg.CDemoFileInfo, report *Report) {
+	if fileInfo == nil {
+		report.addIssue(ValidationIssue{
+			Type:     IssueMissingFileInfo,
+			Severity: SeverityWarning,
+			Message:  "demo has no CDemoFileInfo, playback values are unknown",
+		})
+
+		return
+	}
+
+	report.PlaybackTicks = int(fileInfo.GetPlaybackTicks())
+	report.PlaybackFrames = int(fileInfo.GetPlaybackFrames())
+
+	if report.PlaybackTicks != report.LastTick {
+		report.addIssue(ValidationIssue{
+			Type:     IssueFileInfoMismatch,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("CDemoFileInfo has %d playback ticks but the last tick is %d", report.PlaybackTicks, report.LastTick),
+			Tick:     report.LastTick,
+		})
+	}
+}
+
+type parseResult struct {
+	err      error
+	warnings []WarningSummary
+}
+
+// validateParse parses the demo without user handlers, entity decode failures are collected as warnings.
+func validateParse(r io.Reader) (res parseResult) {
+	defer func() {
+		// NewParserWithConfig() panics if the stream is shorter than the buffer of the BitReader
+		if rec := recover(); rec != nil {
+			res.err = fmt.Errorf("%w: %v", ErrUnexpectedEndOfDemo, rec)
+		}
+	}()
+
+	config := DefaultParserConfig
+	config.IgnorePacketEntitiesPanic = true
+
+	p := NewParserWithConfig(r, config)
+
+	defer func() {
+		res.warnings = p.Warnings()
+
+		_ = p.Close()
+	}()
+
+	return parseResult{err: p.ParseToEnd()}
+}
+
+// addParseIssues adds the issues found by validateParse(), framing problems are already covered by validateFrames().
+func addParseIssues(report *Report, res parseResult) {
+	unknownProtocol := report.NetworkProtocol > latestGameEventListProtocol
+
+	if unknownProtocol {
+		report.addIssue(ValidationIssue{
+			Type:     IssueUnknownProtocol,
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("network protocol %d is newer than the latest known game-event list (%d)",
+				report.NetworkProtocol, latestGameEventListProtocol),
+		})
+	}
+
+	for _, w := range res.warnings {
+		switch w.Type {
+		case events.WarnTypeGameEventBeforeDescriptors:
+			severity := SeverityWarning
+			if unknownProtocol {
+				severity = SeverityError
+			}
+
+			report.addIssue(ValidationIssue{
+				Type:     IssueMissingGameEventList,
+				Severity: severity,
+				Message: fmt.Sprintf("game events occur before the game-event list, the bundled list for protocol %d is used instead",
+					report.NetworkProtocol),
+				Tick:  w.FirstTick,
+				Count: w.Count,
+			})
+
+		case events.WarnTypePacketEntitiesPanic:
+			report.addIssue(ValidationIssue{
+				Type:     IssueEntityDecodeFailure,
+				Severity: SeverityError,
+				Message:  w.FirstMessage,
+				Tick:     w.FirstTick,
+				Count:    w.Count,
+			})
+		}
+	}
+
+	var frameErr *FrameDecodeError
+
+	switch {
+	case res.err == nil:
+
+	case errors.As(res.err, &frameErr) && !report.hasIssueAt(IssueCorruptFrame, frameErr.Offset):
+		report.addIssue(ValidationIssue{
+			Type:     IssueCorruptFrame,
+			Severity: SeverityError,
+			Message:  frameErr.Error(),
+			Offset:   frameErr.Offset,
+			Tick:     frameErr.Tick,
+		})
+
+	case frameErr != nil, errors.Is(res.err, ErrUnexpectedEndOfDemo), errors.Is(res.err, ErrInvalidFileType),
+		report.hasIssue(IssueParseError):
+		// reported by validateFrames()
+
+	default:
+		report.addIssue(ValidationIssue{
+			Type:     IssueParseError,
+			Severity: SeverityError,
+			Message:  res.err.Error(),
+		})
+	}
+}
+
+func (r *Report) hasIssue(t ValidationIssueType) bool {
+	for _, issue := range r.Issues {
+		if issue.Type == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Report) hasIssueAt(t ValidationIssueType, offset int64) bool {
+	for _, issue := range r.Issues {
+		if issue.Type == t && issue.Offset == offset {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,135 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+func issueTypes(report Report) []ValidationIssueType {
+	var types []ValidationIssueType
+
+	for _, issue := range report.Issues {
+		types = append(types, issue.Type)
+	}
+
+	return types
+}
+
+// testCompleteDemoData returns a demo with a CDemoFileInfo at the file-info offset.
+func testCompleteDemoData() []byte {
+	b := newTestDemoFileBuilder().signon()
+
+	for tick := int32(1); tick <= 10; tick++ {
+		b.packet(tick, testConVarMessage("tick", int(tick)))
+	}
+
+	return b.bytes()
+}
+
+func TestValidate(t *testing.T) {
+	data := testCompleteDemoData()
+
+	report := Validate(bytes.NewReader(data))
+
+	assert.True(t, report.Valid)
+	assert.Empty(t, report.Issues)
+	assert.Equal(t, "de_test", report.MapName)
+	assert.Equal(t, int64(len(data)), report.Size)
+	assert.Equal(t, 10, report.LastTick)
+	assert.Equal(t, 10, report.PlaybackTicks)
+	assert.Positive(t, report.Frames)
+
+	b, err := json.Marshal(report)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"valid":true`)
+}
+
+func TestValidate_Truncated(t *testing.T) {
+	demo := newTestDemoFileBuilder().signon().packet(1).packet(2)
+	stopOffset := demo.offset()
+	data := demo.bytes()
+
+	report := Validate(bytes.NewReader(data[:stopOffset]))
+
+	assert.False(t, report.Valid)
+	assert.Contains(t, issueTypes(report), IssueTruncated)
+	assert.Contains(t, issueTypes(report), IssueMissingFileInfo)
+	assert.NotContains(t, issueTypes(report), IssueParseError)
+
+	assert.Equal(t, []ValidationIssueType{IssueTruncated}, issueTypes(Validate(bytes.NewReader(nil))))
+}
+
+func TestValidate_InvalidFileType(t *testing.T) {
+	report := Validate(bytes.NewReader([]byte("HL2DEMO\x00 this is not a CS2 demo")))
+
+	assert.False(t, report.Valid)
+	assert.Equal(t, []ValidationIssueType{IssueInvalidFileType}, issueTypes(report))
+}
+
+func TestValidate_CorruptCompressedFrame(t *testing.T) {
+	demo := newTestDemoBuilder().signon().packet(1)
+
+	corruptOffset := demo.offset()
+
+	b := demo.frame(msg.EDemoCommands_DEM_Packet, 2, make([]byte, 1024)).packet(3).bytes()
+
+	// the compressed data starts after the 3 bytes of the frame header and the 2 bytes of the decompressed size,
+	// 0xFF is a copy from before the start of the data
+	b[corruptOffset+5] = 0xFF
+
+	report := Validate(bytes.NewReader(b))
+
+	assert.False(t, report.Valid)
+	assert.Equal(t, 3, report.LastTick)
+
+	if assert.Len(t, report.Issues, 2) {
+		assert.Equal(t, IssueCorruptCompressedFrame, report.Issues[0].Type)
+		assert.Equal(t, corruptOffset, report.Issues[0].Offset)
+		assert.Equal(t, 2, report.Issues[0].Tick)
+		assert.Equal(t, IssueMissingFileInfo, report.Issues[1].Type)
+		assert.Equal(t, SeverityWarning, report.Issues[1].Severity)
+	}
+}
+
+func TestValidate_FrameTooLarge(t *testing.T) {
+	b := newTestDemoBuilder().signon().incompleteBytes()
+
+	oversizedOffset := int64(len(b))
+
+	// a frame whose size would allocate 4 GiB
+	b = binary.AppendUvarint(b, uint64(msg.EDemoCommands_DEM_Packet|msg.EDemoCommands_DEM_IsCompressed))
+	b = binary.AppendUvarint(b, 1)
+	b = binary.AppendUvarint(b, 0xFFFFFFFF)
+	b = append(b, make([]byte, 100)...)
+
+	report := Validate(bytes.NewReader(b))
+
+	assert.False(t, report.Valid)
+	assert.Equal(t, int64(len(b)), report.Size)
+
+	if assert.Len(t, report.Issues, 1) {
+		assert.Equal(t, IssueCorruptFrame, report.Issues[0].Type)
+		assert.Equal(t, oversizedOffset, report.Issues[0].Offset)
+		assert.Equal(t, 1, report.Issues[0].Tick)
+	}
+}
+
+func TestValidate_UnknownProtocol(t *testing.T) {
+	b := newTestDemoBuilderWithHeader(&msg.CDemoFileHeader{
+		DemoFileStamp: proto.String("PBDEMS_2"),
+		PatchVersion:  proto.Int32(latestGameEventListProtocol + 1),
+	}).signon().bytes()
+
+	report := Validate(bytes.NewReader(b))
+
+	assert.True(t, report.Valid)
+	assert.Equal(t, latestGameEventListProtocol+1, report.NetworkProtocol)
+	assert.Equal(t, []ValidationIssueType{IssueMissingFileInfo, IssueUnknownProtocol}, issueTypes(report))
+}
@@ -0,0 +1,150 @@
+package demoinfocs
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+)
+
+// WarnPolicy defines how the parser handles a ParserWarn, see ParserConfig.Strict.
+type WarnPolicy int
+
+const (
+	// WarnPolicyWarn dispatches the ParserWarn event (default).
+	WarnPolicyWarn WarnPolicy = iota
+
+	// WarnPolicyIgnore doesn't dispatch the ParserWarn event, it's still counted in Parser.Warnings().
+	WarnPolicyIgnore
+
+	// WarnPolicyFail dispatches the ParserWarn event and stops parsing with a *WarningError.
+	WarnPolicyFail
+)
+
+// StrictConfig defines per WarnType how the parser handles ParserWarn events, see ParserConfig.Strict.
+// The zero value dispatches all warnings.
+type StrictConfig struct {
+	Default  WarnPolicy                     // Policy for types that aren't in Policies
+	Policies map[events.WarnType]WarnPolicy // Policies per WarnType, overrides Default
+}
+
+func (c StrictConfig) policy(t events.WarnType) WarnPolicy {
+	if policy, ok := c.Policies[t]; ok {
+		return policy
+	}
+
+	return c.Default
+}
+
+// WarningError is returned by the parser if a ParserWarn occurs whose policy is WarnPolicyFail, see ParserConfig.Strict.
+type WarningError struct {
+	Frame int // Frame / demo-tick, see Parser.CurrentFrame()
+	Tick  int // Ingame tick
+	Warn  events.ParserWarn
+}
+
+func (e *WarningError) Error() string {
+	return fmt.Sprintf("parser warning of type %d at frame %d (ingame tick %d): %s", e.Warn.Type, e.Frame, e.Tick, e.Warn.Message)
+}
+
+// WarningSummary contains the aggregated ParserWarn events of a WarnType, see Parser.Warnings().
+type WarningSummary struct {
+	Type         events.WarnType `json:"type"`
+	Count        int             `json:"count"`
+	FirstFrame   int             `json:"first_frame"`   // Frame / demo-tick of the first occurrence
+	FirstTick    int             `json:"first_tick"`    // Ingame tick of the first occurrence
+	FirstMessage string          `json:"first_message"` // Message of the first occurrence
+}
+
+// Warnings returns the ParserWarn events that occurred so far, aggregated per WarnType and ordered by type.
+// Includes warnings that weren't dispatched because of ParserConfig.Strict.
+func (p *parser) Warnings() []WarningSummary {
+	p.warningsLock.Lock()
+
+	summary := make([]WarningSummary, 0, len(p.warnings))
+
+	for _, w := range p.warnings {
+		summary = append(summary, *w)
+	}
+
+	p.warningsLock.Unlock()
+
+	slices.SortFunc(summary, func(a, b WarningSummary) int {
+		return cmp.Compare(a.Type, b.Type)
+	})
+
+	return summary
+}
+
+// recordWarn adds w to the Warnings() and applies ParserConfig.Strict.
+// Returns false if w shouldn't be dispatched.
+func (p *parser) recordWarn(w events.ParserWarn) bool {
+	frame, tick := p.currentFrame, p.gameState.ingameTick
+
+	p.warningsLock.Lock()
+
+	if p.warnings == nil {
+		p.warnings = make(map[events.WarnType]*WarningSummary)
+	}
+
+	s := p.warnings[w.Type]
+	if s == nil {
+		s = &WarningSummary{
+			Type:         w.Type,
+			FirstFrame:   frame,
+			FirstTick:    tick,
+			FirstMessage: w.Message,
+		}
+		p.warnings[w.Type] = s
+	}
+
+	s.Count++
+
+	p.warningsLock.Unlock()
+
+	switch p.config.Strict.policy(w.Type) {
+	case WarnPolicyIgnore:
+		return false
+
+	case WarnPolicyFail:
+		p.setError(&WarningError{
+			Frame: frame,
+			Tick:  tick,
+			Warn:  w,
+		})
+	}
+
+	return true
+}
+
+// warn dispatches a ParserWarn event according to ParserConfig.Strict.
+func (p *parser) warn(w events.ParserWarn) {
+	if p.recordWarn(w) {
+		p.eventDispatcher.Dispatch(w)
+	}
+}
+
+// queuedWarn is a ParserWarn that occurred while reading a frame.
+// It's queued so it's handled in order with the net-messages of the frame, when the frame and tick are up to date.
+type queuedWarn struct {
+	warn       events.ParserWarn
+	netMessage bool // Whether to dispatch to net-message handlers instead of event handlers
+}
+
+// queueWarn is like warn() for the goroutine that reads frames.
+func (p *parser) queueWarn(w events.ParserWarn) {
+	p.msgQueue <- queuedWarn{warn: w}
+}
+
+func (p *parser) handleQueuedWarn(w queuedWarn) {
+	if !p.recordWarn(w.warn) {
+		return
+	}
+
+	if w.netMessage {
+		p.msgDispatcher.Dispatch(w.warn)
+	} else {
+		p.eventDispatcher.Dispatch(w.warn)
+	}
+}
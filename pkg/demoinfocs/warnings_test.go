@@ -0,0 +1,113 @@
+package demoinfocs
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+	"github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+)
+
+func TestParser_Warnings(t *testing.T) {
+	p := NewParser(bytes.NewReader(testClipDemoData(t))).(*parser)
+
+	var dispatched []events.ParserWarn
+
+	p.RegisterEventHandler(func(w events.ParserWarn) {
+		dispatched = append(dispatched, w)
+	})
+
+	p.currentFrame = 10
+	p.gameState.ingameTick = 100
+	p.warn(events.ParserWarn{Type: events.WarnTypeUnknownGameEvent, Message: "first"})
+
+	p.currentFrame = 20
+	p.gameState.ingameTick = 200
+	p.warn(events.ParserWarn{Type: events.WarnTypeUnknownGameEvent, Message: "second"})
+	p.warn(events.ParserWarn{Type: events.WarnTypeBombsiteUnknown, Message: "bombsite"})
+
+	assert.Len(t, dispatched, 3)
+	assert.Equal(t, []WarningSummary{
+		{Type: events.WarnTypeBombsiteUnknown, Count: 1, FirstFrame: 20, FirstTick: 200, FirstMessage: "bombsite"},
+		{Type: events.WarnTypeUnknownGameEvent, Count: 2, FirstFrame: 10, FirstTick: 100, FirstMessage: "first"},
+	}, p.Warnings())
+	assert.NoError(t, p.error())
+}
+
+func TestParserConfig_Strict(t *testing.T) {
+	config := DefaultParserConfig
+	config.Strict = StrictConfig{
+		Default: WarnPolicyIgnore,
+		Policies: map[events.WarnType]WarnPolicy{
+			events.WarnTypeUnknownProtobufMessage: WarnPolicyFail,
+			events.WarnTypeBombsiteUnknown:        WarnPolicyWarn,
+		},
+	}
+
+	p := NewParserWithConfig(bytes.NewReader(testClipDemoData(t)), config).(*parser)
+
+	var dispatched []events.WarnType
+
+	p.RegisterEventHandler(func(w events.ParserWarn) {
+		dispatched = append(dispatched, w.Type)
+	})
+
+	p.warn(events.ParserWarn{Type: events.WarnTypeUnknownGameEvent})
+	p.warn(events.ParserWarn{Type: events.WarnTypeBombsiteUnknown})
+
+	assert.Equal(t, []events.WarnType{events.WarnTypeBombsiteUnknown}, dispatched)
+	assert.NoError(t, p.error())
+
+	p.currentFrame = 5
+	p.gameState.ingameTick = 50
+	p.warn(events.ParserWarn{Type: events.WarnTypeUnknownProtobufMessage, Message: "unknown message type: 999"})
+
+	assert.Equal(t, []events.WarnType{events.WarnTypeBombsiteUnknown, events.WarnTypeUnknownProtobufMessage}, dispatched)
+
+	var warnErr *WarningError
+
+	if assert.True(t, errors.As(p.error(), &warnErr)) {
+		assert.Equal(t, 5, warnErr.Frame)
+		assert.Equal(t, 50, warnErr.Tick)
+		assert.Equal(t, "unknown message type: 999", warnErr.Warn.Message)
+	}
+
+	assert.Len(t, p.Warnings(), 3)
+}
+
+// Warnings of the goroutine that reads frames are handled on the dispatching goroutine, run with -race.
+func TestParser_Warnings_WhileReadingFrames(t *testing.T) {
+	demo := newTestDemoBuilder().signon()
+
+	for tick := int32(1); tick <= 10; tick++ {
+		demo.packet(tick)
+	}
+
+	b := demo.frame(msg.EDemoCommands(99), 10, make([]byte, 10)).bytes() // unknown command
+
+	config := DefaultParserConfig
+	config.MsgQueueBufferSize = 8
+
+	p := NewParserWithConfig(bytes.NewReader(b), config)
+
+	var dispatched []events.ParserWarn
+
+	p.RegisterEventHandler(func(w events.ParserWarn) {
+		dispatched = append(dispatched, w)
+	})
+
+	assert.NoError(t, p.ParseToEnd())
+
+	if assert.Len(t, dispatched, 1) {
+		assert.Equal(t, events.WarnType(events.WarnTypeUnknownDemoCommandMessageType), dispatched[0].Type)
+	}
+
+	warnings := p.Warnings()
+
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, 10, warnings[0].FirstTick)
+	}
+}